@@ -18,11 +18,14 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/reactiveops/rbac-manager/pkg/apis"
 	"github.com/reactiveops/rbac-manager/pkg/controller"
+	"github.com/reactiveops/rbac-manager/pkg/controller/rbacdefinition"
 	"github.com/reactiveops/rbac-manager/version"
 
 	logrus "github.com/sirupsen/logrus"
@@ -33,6 +36,52 @@ import (
 )
 
 var logLevel = flag.String("log-level", logrus.InfoLevel.String(), "Logrus log level")
+var resyncInterval = flag.Duration("resync-interval", 0, "Default interval to resync RBAC Definitions with namespace selectors, 0 disables")
+var allowSystemGroups = flag.Bool("allow-system-groups", false, "Allow RBAC Definitions to bind to system groups like system:authenticated")
+var allowSystemUsers = flag.Bool("allow-system-users", false, "Allow RBAC Definitions to bind to users with reserved names like system:kube-scheduler")
+var shadowMode = flag.Bool("shadow-mode", false, "Log the changes RBAC Manager would make without applying them")
+var serverValidate = flag.Bool("server-validate", false, "Validate generated resources with dry run requests before applying any changes")
+var openShiftProjects = flag.Bool("openshift-projects", false, "Evaluate namespace selectors against OpenShift Projects")
+var teamNamespaceLabel = flag.String("team-namespace-label", "", "Namespace label naming the team a namespace belongs to, required for Role Bindings with a team")
+var resolveNodeNamespaces = flag.Bool("resolve-node-namespaces", false, "Resolve the namespaces of Role Bindings with a node selector from the pods on matching nodes")
+var resolveResourcePresence = flag.Bool("resolve-resource-presence", false, "Check namespaces for the resources required by Role Bindings with requireResource")
+var protectedNamespaces = flag.String("protected-namespaces", "", "Comma separated list of namespaces that managed resources are never deleted from")
+var useGenerateName = flag.Bool("use-generate-name", false, "Create bindings with generated names instead of fixed names")
+var compactServiceAccountNames = flag.Bool("compact-service-account-names", false, "Use shorter names for Role Bindings with a single Service Account subject in its own namespace")
+var maxNameLengths = flag.String("max-name-lengths", "", "Comma separated list of resource type=length limits for generated names, such as RoleBinding=63")
+var nameSeparator = flag.String("name-separator", rbacdefinition.DefaultNameSeparator, "Separator joining the RBAC Definition, RBAC Binding, and role names of generated names")
+var qualifyClusterRoleBindingNames = flag.Bool("qualify-cluster-role-binding-names", false, "Suffix Cluster Role Binding names with a hash of the RBAC Definition UID to keep them unique")
+var sortSubjects = flag.Bool("sort-subjects", false, "Sort the subjects of generated bindings instead of keeping the order of the RBAC Definition")
+var collapseNamespaceBindings = flag.Bool("collapse-namespace-bindings", false, "Merge Role Bindings to the same role in the same namespace into one")
+var createTokenSecrets = flag.Bool("create-token-secrets", false, "Create a long-lived token Secret for each Service Account")
+var skipTerminatingNamespaces = flag.Bool("skip-terminating-namespaces", false, "Skip explicitly named namespaces that are being deleted instead of creating Role Bindings in them")
+var requireServiceAccountNamespaces = flag.Bool("require-service-account-namespaces", false, "Only create Service Accounts in namespaces that exist, retrying until they do")
+var expandNamespaceGlobs = flag.Bool("expand-namespace-globs", false, "Create Role Bindings in each namespace matching an explicit namespace containing a *")
+var warnOnEmpty = flag.Bool("warn-on-empty", true, "Log a warning for RBAC Definitions without any RBAC Bindings")
+var failOnEmptyBinding = flag.Bool("fail-on-empty-binding", false, "Reject RBAC Definitions with a requested Role Binding that isn't generated in any namespace")
+var sourceRevision = flag.String("source-revision", "", "Revision of the RBAC Definition source, such as a git commit, to annotate generated resources with")
+var roleLabelRules = flag.String("role-label-rules", "", "Semicolon separated list of pattern:key=value,... rules labeling bindings to roles with names matching the pattern")
+var inheritDefinitionLabels = flag.String("inherit-definition-labels", "", "Comma separated list of RBAC Definition labels to copy to the resources generated for it")
+var defaultUserAPIGroup = flag.String("default-user-api-group", "", "API group set on User subjects that don't specify one")
+var defaultGroupAPIGroup = flag.String("default-group-api-group", "", "API group set on Group subjects that don't specify one")
+var maxSubjectsPerBinding = flag.Int("max-subjects-per-binding", 0, "Reject RBAC Definitions generating a binding with more subjects than this, 0 disables the limit")
+var groupMembersConfigMap = flag.String("group-members-configmap", "", "Namespace/name of a ConfigMap listing the members of each group, used to expand Group subjects into Users")
+var checkRequesterAccess = flag.Bool("check-requester-access", false, "Only create Role Bindings in namespaces where the user in the rbac-manager/requester annotation of an RBAC Definition can create them")
+var valuesFile = flag.String("values-file", "", "YAML file with the values referenced by {{ .Values.x }} placeholders in RBAC Definitions")
+var disallowedSubjectKinds = flag.String("disallowed-subject-kinds", "", "Comma separated list of subject kinds, such as User, that RBAC Definitions can't bind")
+var subjectNamePatterns = flag.String("subject-name-patterns", "", "Semicolon separated list of kind=pattern rules that subject names of each kind must match")
+var groupNameMapFile = flag.String("group-name-map-file", "", "YAML file mapping external group names to the group names used in the cluster")
+var roleAliases = flag.String("role-aliases", "", "Comma separated list of alias=clusterRole pairs translating friendly role names in RBAC Definitions")
+var validateRoleAliases = flag.Bool("validate-role-aliases", false, "Reject RBAC Definitions with Cluster Role names that are neither a role alias nor an existing Cluster Role")
+var enabledResourceTypes = flag.String("enabled-resource-types", "", "Comma separated list of resource types to generate, out of ClusterRoleBinding, RoleBinding, and ServiceAccount, all types are generated when empty")
+var propagateNamespaceLabels = flag.String("propagate-namespace-labels", "", "Comma separated list of namespace labels to copy to the Service Accounts generated in each namespace")
+var subjectsURLTimeout = flag.Duration("subjects-url-timeout", rbacdefinition.SubjectsURLTimeout, "Timeout for fetching subjects from subjectsFromURL endpoints")
+var healthAddress = flag.String("health-address", "", "Address to serve the /readyz health check and /metrics on, disabled when empty")
+var reconcileOnLabelTransitions = flag.Bool("reconcile-on-label-transitions", false, "Only reconcile the RBAC Definitions selecting on namespace labels that changed when a namespace changes")
+var resolveAggregatedClusterRoles = flag.Bool("resolve-aggregated-cluster-roles", false, "Log the Cluster Roles aggregated by each bound Cluster Role at debug level")
+var applyQPS = flag.Float64("apply-qps", 0, "Maximum number of changes applied per second, 0 disables throttling")
+var applyBurst = flag.Int("apply-burst", 10, "Maximum burst of changes applied when apply-qps is set")
+var forbiddenRequeueInterval = flag.Duration("forbidden-requeue-interval", rbacdefinition.ForbiddenRequeueInterval, "Interval to retry RBAC Definitions when namespaces can't be listed")
 
 func main() {
 	flag.Parse()
@@ -48,6 +97,128 @@ func main() {
 		logrus.Errorf("log-level flag has invalid value %s", strings.ToUpper(logLevel.String()))
 	}
 
+	rbacdefinition.DefaultResyncInterval = *resyncInterval
+	rbacdefinition.ForbiddenRequeueInterval = *forbiddenRequeueInterval
+	rbacdefinition.SubjectsURLTimeout = *subjectsURLTimeout
+	rbacdefinition.AllowSystemGroups = *allowSystemGroups
+	rbacdefinition.AllowSystemUsers = *allowSystemUsers
+	rbacdefinition.ShadowMode = *shadowMode
+	rbacdefinition.ServerValidate = *serverValidate
+	rbacdefinition.OpenShiftProjects = *openShiftProjects
+	rbacdefinition.TeamNamespaceLabel = *teamNamespaceLabel
+	rbacdefinition.GroupMembersConfigMap = *groupMembersConfigMap
+	rbacdefinition.CheckRequesterAccess = *checkRequesterAccess
+	rbacdefinition.ResolveNodeNamespaces = *resolveNodeNamespaces
+	rbacdefinition.ResolveResourcePresence = *resolveResourcePresence
+	rbacdefinition.UseGenerateName = *useGenerateName
+	rbacdefinition.CompactServiceAccountNames = *compactServiceAccountNames
+	rbacdefinition.NameSeparator = *nameSeparator
+	rbacdefinition.QualifyClusterRoleBindingNames = *qualifyClusterRoleBindingNames
+	rbacdefinition.SortSubjects = *sortSubjects
+	rbacdefinition.CollapseNamespaceBindings = *collapseNamespaceBindings
+	rbacdefinition.CreateTokenSecret = *createTokenSecrets
+	rbacdefinition.SkipTerminatingNamespaces = *skipTerminatingNamespaces
+	rbacdefinition.RequireServiceAccountNamespaces = *requireServiceAccountNamespaces
+	rbacdefinition.ExpandNamespaceGlobs = *expandNamespaceGlobs
+	rbacdefinition.WarnOnEmpty = *warnOnEmpty
+	rbacdefinition.FailOnEmptyBinding = *failOnEmptyBinding
+	rbacdefinition.SourceRevision = *sourceRevision
+	rbacdefinition.DefaultUserAPIGroup = *defaultUserAPIGroup
+	rbacdefinition.DefaultGroupAPIGroup = *defaultGroupAPIGroup
+	rbacdefinition.MaxSubjectsPerBinding = *maxSubjectsPerBinding
+	rbacdefinition.ValidateRoleAliases = *validateRoleAliases
+	rbacdefinition.ResolveAggregatedClusterRoles = *resolveAggregatedClusterRoles
+	rbacdefinition.ReconcileOnLabelTransitions = *reconcileOnLabelTransitions
+
+	if *applyQPS > 0 {
+		rbacdefinition.ApplyRateLimiter = rbacdefinition.NewApplyRateLimiter(*applyQPS, *applyBurst)
+	}
+
+	for _, limit := range strings.Split(*maxNameLengths, ",") {
+		if limit == "" {
+			continue
+		}
+
+		parts := strings.SplitN(limit, "=", 2)
+		length, err := strconv.Atoi(strings.TrimSpace(parts[len(parts)-1]))
+		if len(parts) != 2 || err != nil {
+			logrus.Errorf("max-name-lengths flag has invalid value %v", limit)
+			os.Exit(1)
+		}
+		rbacdefinition.MaxNameLengths[strings.TrimSpace(parts[0])] = length
+	}
+
+	rules, err := rbacdefinition.ParseRoleLabelRules(*roleLabelRules)
+	if err != nil {
+		logrus.Errorf("role-label-rules flag has invalid value: %v", err)
+		os.Exit(1)
+	}
+	rbacdefinition.RoleLabelRules = rules
+
+	kinds, err := rbacdefinition.ParseSubjectKinds(*disallowedSubjectKinds)
+	if err != nil {
+		logrus.Errorf("disallowed-subject-kinds flag has invalid value: %v", err)
+		os.Exit(1)
+	}
+	rbacdefinition.DisallowedSubjectKinds = kinds
+
+	patterns, err := rbacdefinition.ParseSubjectNamePatterns(*subjectNamePatterns)
+	if err != nil {
+		logrus.Errorf("subject-name-patterns flag has invalid value: %v", err)
+		os.Exit(1)
+	}
+	rbacdefinition.SubjectNamePatterns = patterns
+
+	aliases, err := rbacdefinition.ParseRoleAliases(*roleAliases)
+	if err != nil {
+		logrus.Errorf("role-aliases flag has invalid value: %v", err)
+		os.Exit(1)
+	}
+	rbacdefinition.RoleAliases = aliases
+
+	resourceTypes, err := rbacdefinition.ParseResourceTypes(*enabledResourceTypes)
+	if err != nil {
+		logrus.Errorf("enabled-resource-types flag has invalid value: %v", err)
+		os.Exit(1)
+	}
+	rbacdefinition.EnabledResourceTypes = resourceTypes
+
+	if *groupNameMapFile != "" {
+		groupNameMap, err := rbacdefinition.LoadGroupNameMapFile(*groupNameMapFile)
+		if err != nil {
+			logrus.Errorf("group-name-map-file flag has invalid value: %v", err)
+			os.Exit(1)
+		}
+		rbacdefinition.GroupNameMap = groupNameMap
+	}
+
+	if *valuesFile != "" {
+		values, err := rbacdefinition.LoadValuesFile(*valuesFile)
+		if err != nil {
+			logrus.Errorf("values-file flag has invalid value: %v", err)
+			os.Exit(1)
+		}
+		rbacdefinition.Values = values
+	}
+
+	for _, label := range strings.Split(*inheritDefinitionLabels, ",") {
+		if label != "" {
+			rbacdefinition.InheritDefinitionLabels = append(rbacdefinition.InheritDefinitionLabels, strings.TrimSpace(label))
+		}
+	}
+
+	for _, label := range strings.Split(*propagateNamespaceLabels, ",") {
+		if label != "" {
+			rbacdefinition.PropagateNamespaceLabels = append(rbacdefinition.PropagateNamespaceLabels, strings.TrimSpace(label))
+		}
+	}
+
+	for _, namespace := range strings.Split(*protectedNamespaces, ",") {
+		if namespace != "" {
+			rbacdefinition.ProtectedNamespaces[strings.TrimSpace(namespace)] = true
+		}
+	}
+
 	logrus.Info("----------------------------------")
 	logrus.Infof("rbac-manager %v running", version.Version)
 	logrus.Info("----------------------------------")
@@ -84,6 +255,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *healthAddress != "" {
+		logrus.Debugf("Serving health check and metrics on %v", *healthAddress)
+		mux := http.NewServeMux()
+		mux.Handle("/readyz", rbacdefinition.Health)
+		mux.Handle("/metrics", rbacdefinition.Metrics)
+		go func() {
+			logrus.Error(http.ListenAndServe(*healthAddress, mux))
+		}()
+	}
+
 	// Start the Cmd
 	logrus.Debug("Starting the command")
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
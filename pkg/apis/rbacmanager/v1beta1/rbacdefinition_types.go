@@ -17,29 +17,96 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // RBACBinding is a specification for a RBACBinding resource
 type RBACBinding struct {
-	Name                string               `json:"name"`
-	Subjects            []rbacv1.Subject     `json:"subjects"`
-	ClusterRoleBindings []ClusterRoleBinding `json:"clusterRoleBindings"`
-	RoleBindings        []RoleBinding        `json:"roleBindings"`
+	Name                  string               `json:"name"`
+	Subjects              []rbacv1.Subject     `json:"subjects"`
+	ClusterRoleBindings   []ClusterRoleBinding `json:"clusterRoleBindings"`
+	RoleBindings          []RoleBinding        `json:"roleBindings"`
+	Secrets               []string             `json:"secrets,omitempty"`
+	SubjectsFromURL       string               `json:"subjectsFromURL,omitempty"`
+	ExpiresAt             string               `json:"expiresAt,omitempty"`
+	Matrix                bool                 `json:"matrix,omitempty"`
+	DryRun                bool                 `json:"dryRun,omitempty"`
+	CreateServiceAccounts *bool                `json:"createServiceAccounts,omitempty"`
+	SubjectsFromConfigMap *ConfigMapSubjects   `json:"subjectsFromConfigMap,omitempty"`
+	SubjectDisplayNames   map[string]string    `json:"subjectDisplayNames,omitempty"`
+	ApplyOrder            int                  `json:"applyOrder,omitempty"`
+}
+
+// ConfigMapSubjects refers to a ConfigMap key containing subjects as CSV,
+// with kind, name, and namespace columns
+type ConfigMapSubjects struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
 }
 
 // ClusterRoleBinding is a specification for a ClusterRoleBinding resource
 type ClusterRoleBinding struct {
-	ClusterRole string `json:"clusterRole"`
+	ClusterRole              string   `json:"clusterRole,omitempty"`
+	ClusterRoles             []string `json:"clusterRoles,omitempty"`
+	RoleRefAPIGroup          string   `json:"roleRefAPIGroup,omitempty"`
+	RoleRefKind              string   `json:"roleRefKind,omitempty"`
+	ScopeToSubjectNamespaces bool     `json:"scopeToSubjectNamespaces,omitempty"`
 }
 
 // RoleBinding is a specification for a RoleBinding resource
 type RoleBinding struct {
-	ClusterRole       string               `json:"clusterRole,omitempty"`
-	Role              string               `json:"role,omitempty"`
-	Namespace         string               `json:"namespace,omitempty"`
-	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	ClusterRole              string                      `json:"clusterRole,omitempty"`
+	Role                     string                      `json:"role,omitempty"`
+	Namespace                string                      `json:"namespace,omitempty"`
+	Namespaces               []string                    `json:"namespaces,omitempty"`
+	NamespaceSelector        metav1.LabelSelector        `json:"namespaceSelector,omitempty"`
+	NamespaceFieldSelector   string                      `json:"namespaceFieldSelector,omitempty"`
+	NamespaceExcludeSelector metav1.LabelSelector        `json:"namespaceExcludeSelector,omitempty"`
+	RoleRefAPIGroup          string                      `json:"roleRefAPIGroup,omitempty"`
+	RoleRefKind              string                      `json:"roleRefKind,omitempty"`
+	Both                     bool                        `json:"both,omitempty"`
+	SubjectOverrides         map[string][]rbacv1.Subject `json:"subjectOverrides,omitempty"`
+	AnnotationExpression     []AnnotationRequirement     `json:"annotationExpression,omitempty"`
+	CatchAll                 bool                        `json:"catchAll,omitempty"`
+	NamespaceOwner           *NamespaceOwner             `json:"namespaceOwner,omitempty"`
+	RequireRolePresent       bool                        `json:"requireRolePresent,omitempty"`
+	RequireResource          *ResourceRequirement        `json:"requireResource,omitempty"`
+	PerLabelValue            *PerLabelValue              `json:"perLabelValue,omitempty"`
+	Team                     string                      `json:"team,omitempty"`
+	NodeSelector             map[string]string           `json:"nodeSelector,omitempty"`
+}
+
+// PerLabelValue generates a Role Binding in each namespace with a label,
+// binding the Cluster Role mapped to the value of the label
+type PerLabelValue struct {
+	Label        string            `json:"label"`
+	ClusterRoles map[string]string `json:"clusterRoles"`
+}
+
+// NamespaceOwner identifies an owner reference that a namespace must have for
+// a RoleBinding to be created in it
+type NamespaceOwner struct {
+	Kind string `json:"kind,omitempty"`
+	UID  string `json:"uid,omitempty"`
+}
+
+// ResourceRequirement identifies a resource that must exist in a namespace
+// for a RoleBinding to be created in it
+type ResourceRequirement struct {
+	APIGroup      string               `json:"apiGroup,omitempty"`
+	Kind          string               `json:"kind"`
+	LabelSelector metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// AnnotationRequirement is a requirement that a namespace's annotations must
+// meet for a RoleBinding to be created in it
+type AnnotationRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
 }
 
 // +genclient
@@ -48,14 +115,40 @@ type RoleBinding struct {
 // RBACDefinition is the Schema for the rbacdefinitions API
 // +k8s:openapi-gen=true
 type RBACDefinition struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata"`
-	RBACBindings      []RBACBinding        `json:"rbacBindings"`
-	Status            RBACDefinitionStatus `json:"status,omitempty"`
+	metav1.TypeMeta       `json:",inline"`
+	metav1.ObjectMeta     `json:"metadata"`
+	RBACBindings          []RBACBinding        `json:"rbacBindings"`
+	ResyncIntervalSeconds int32                `json:"resyncIntervalSeconds,omitempty"`
+	Priority              int32                `json:"priority,omitempty"`
+	SchemaVersion         string               `json:"schemaVersion,omitempty"`
+	Status                RBACDefinitionStatus `json:"status,omitempty"`
 }
 
 // RBACDefinitionStatus defines the observed state of RBACDefinition
 type RBACDefinitionStatus struct {
+	ObservedGeneration int64                     `json:"observedGeneration,omitempty"`
+	Conditions         []RBACDefinitionCondition `json:"conditions,omitempty"`
+	SelectorMatches    []SelectorMatch           `json:"selectorMatches,omitempty"`
+}
+
+// SelectorMatch records the number of namespaces a Role Binding with a
+// namespace selector was generated in when an RBAC Definition was last
+// reconciled
+type SelectorMatch struct {
+	RBACBinding string `json:"rbacBinding"`
+	Role        string `json:"role"`
+	Selector    string `json:"selector"`
+	Namespaces  int32  `json:"namespaces"`
+}
+
+// RBACDefinitionCondition describes the state of an RBAC Definition at a
+// point in time, following the standard Kubernetes condition pattern
+type RBACDefinitionCondition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
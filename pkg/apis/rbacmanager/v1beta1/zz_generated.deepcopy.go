@@ -9,9 +9,35 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnnotationRequirement) DeepCopyInto(out *AnnotationRequirement) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnnotationRequirement.
+func (in *AnnotationRequirement) DeepCopy() *AnnotationRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(AnnotationRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterRoleBinding) DeepCopyInto(out *ClusterRoleBinding) {
 	*out = *in
+	if in.ClusterRoles != nil {
+		in, out := &in.ClusterRoles, &out.ClusterRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -25,6 +51,61 @@ func (in *ClusterRoleBinding) DeepCopy() *ClusterRoleBinding {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapSubjects) DeepCopyInto(out *ConfigMapSubjects) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapSubjects.
+func (in *ConfigMapSubjects) DeepCopy() *ConfigMapSubjects {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapSubjects)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOwner) DeepCopyInto(out *NamespaceOwner) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOwner.
+func (in *NamespaceOwner) DeepCopy() *NamespaceOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PerLabelValue) DeepCopyInto(out *PerLabelValue) {
+	*out = *in
+	if in.ClusterRoles != nil {
+		in, out := &in.ClusterRoles, &out.ClusterRoles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PerLabelValue.
+func (in *PerLabelValue) DeepCopy() *PerLabelValue {
+	if in == nil {
+		return nil
+	}
+	out := new(PerLabelValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RBACBinding) DeepCopyInto(out *RBACBinding) {
 	*out = *in
@@ -36,13 +117,39 @@ func (in *RBACBinding) DeepCopyInto(out *RBACBinding) {
 	if in.ClusterRoleBindings != nil {
 		in, out := &in.ClusterRoleBindings, &out.ClusterRoleBindings
 		*out = make([]ClusterRoleBinding, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.RoleBindings != nil {
 		in, out := &in.RoleBindings, &out.RoleBindings
 		*out = make([]RoleBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.CreateServiceAccounts != nil {
+		in, out := &in.CreateServiceAccounts, &out.CreateServiceAccounts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SubjectsFromConfigMap != nil {
+		in, out := &in.SubjectsFromConfigMap, &out.SubjectsFromConfigMap
+		*out = new(ConfigMapSubjects)
+		**out = **in
+	}
+	if in.SubjectDisplayNames != nil {
+		in, out := &in.SubjectDisplayNames, &out.SubjectDisplayNames
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -68,7 +175,7 @@ func (in *RBACDefinition) DeepCopyInto(out *RBACDefinition) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -90,6 +197,23 @@ func (in *RBACDefinition) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RBACDefinitionCondition) DeepCopyInto(out *RBACDefinitionCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RBACDefinitionCondition.
+func (in *RBACDefinitionCondition) DeepCopy() *RBACDefinitionCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(RBACDefinitionCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RBACDefinitionList) DeepCopyInto(out *RBACDefinitionList) {
 	*out = *in
@@ -126,6 +250,18 @@ func (in *RBACDefinitionList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RBACDefinitionStatus) DeepCopyInto(out *RBACDefinitionStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]RBACDefinitionCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SelectorMatches != nil {
+		in, out := &in.SelectorMatches, &out.SelectorMatches
+		*out = make([]SelectorMatch, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -139,9 +275,77 @@ func (in *RBACDefinitionStatus) DeepCopy() *RBACDefinitionStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRequirement) DeepCopyInto(out *ResourceRequirement) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRequirement.
+func (in *ResourceRequirement) DeepCopy() *ResourceRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RoleBinding) DeepCopyInto(out *RoleBinding) {
 	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	in.NamespaceExcludeSelector.DeepCopyInto(&out.NamespaceExcludeSelector)
+	if in.SubjectOverrides != nil {
+		in, out := &in.SubjectOverrides, &out.SubjectOverrides
+		*out = make(map[string][]v1.Subject, len(*in))
+		for key, val := range *in {
+			var outVal []v1.Subject
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]v1.Subject, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.AnnotationExpression != nil {
+		in, out := &in.AnnotationExpression, &out.AnnotationExpression
+		*out = make([]AnnotationRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceOwner != nil {
+		in, out := &in.NamespaceOwner, &out.NamespaceOwner
+		*out = new(NamespaceOwner)
+		**out = **in
+	}
+	if in.RequireResource != nil {
+		in, out := &in.RequireResource, &out.RequireResource
+		*out = new(ResourceRequirement)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PerLabelValue != nil {
+		in, out := &in.PerLabelValue, &out.PerLabelValue
+		*out = new(PerLabelValue)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -154,3 +358,19 @@ func (in *RoleBinding) DeepCopy() *RoleBinding {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelectorMatch) DeepCopyInto(out *SelectorMatch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelectorMatch.
+func (in *SelectorMatch) DeepCopy() *SelectorMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(SelectorMatch)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -21,11 +21,11 @@ import (
 
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
 	"github.com/reactiveops/rbac-manager/pkg/controller/rbacdefinition"
+	logrus "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	rest "k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,7 +44,13 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileNamespace{Client: mgr.GetClient(), config: mgr.GetConfig(), scheme: mgr.GetScheme()}
+	rdr, err := rbacdefinition.NewReconciler(mgr)
+	if err != nil {
+		// If we can't get a clientset we can't do anything else
+		panic(err)
+	}
+
+	return &ReconcileNamespace{Client: mgr.GetClient(), config: mgr.GetConfig(), scheme: mgr.GetScheme(), reconciler: rdr}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -67,8 +73,9 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 // ReconcileNamespace reconciles a Namespace object
 type ReconcileNamespace struct {
 	client.Client
-	scheme *runtime.Scheme
-	config *rest.Config
+	scheme     *runtime.Scheme
+	config     *rest.Config
+	reconciler rbacdefinition.Reconciler
 }
 
 // Reconcile makes changes in response to Namespace changes
@@ -80,7 +87,12 @@ func (r *ReconcileNamespace) Reconcile(request reconcile.Request) (reconcile.Res
 	err = r.Get(context.TODO(), request.NamespacedName, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			err = reconcileNamespace(r.config, namespace)
+			if rbacdefinition.ReconcileOnLabelTransitions {
+				rbacdefinition.Selectors.ForgetNamespace(request.Name)
+				return reconcile.Result{}, nil
+			}
+
+			err = r.reconcileNamespace(namespace)
 			if err != nil {
 				return reconcile.Result{}, err
 			}
@@ -90,7 +102,7 @@ func (r *ReconcileNamespace) Reconcile(request reconcile.Request) (reconcile.Res
 		return reconcile.Result{}, err
 	}
 
-	err = reconcileNamespace(r.config, namespace)
+	err = r.reconcileNamespace(namespace)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -98,29 +110,77 @@ func (r *ReconcileNamespace) Reconcile(request reconcile.Request) (reconcile.Res
 	return reconcile.Result{}, nil
 }
 
-func reconcileNamespace(config *rest.Config, namespace *v1.Namespace) error {
-	var err error
-	var rbacDefList rbacmanagerv1beta1.RBACDefinitionList
-	rdr := rbacdefinition.Reconciler{}
+func (r *ReconcileNamespace) reconcileNamespace(namespace *v1.Namespace) error {
+	rbacDefList, err := getRbacDefinitions(r.config)
+	if err != nil {
+		return err
+	}
+	rbacdefinition.SortByPriority(rbacDefList.Items)
+
+	if rbacdefinition.ReconcileOnLabelTransitions {
+		return r.reconcileTransition(rbacDefList.Items, namespace)
+	}
 
-	// Full Kubernetes ClientSet is required because RBAC types don't
-	//   implement methods required for Kubebuilder methods to work
-	rdr.Clientset, err = kubernetes.NewForConfig(config)
+	return r.reconcileDefinitions(rbacDefList.Items, namespace)
+}
 
+// reconcileTransition reconciles the RBAC Definitions affected by a
+// transition of the namespace. The transition is rolled back if any of them
+// fail, so that they're all retried along with the namespace.
+func (r *ReconcileNamespace) reconcileTransition(rbacDefs []rbacmanagerv1beta1.RBACDefinition, namespace *v1.Namespace) error {
+	err := r.reconcileDefinitions(labelTransitionDefinitions(rbacDefs, namespace), namespace)
 	if err != nil {
-		return err
+		rbacdefinition.Selectors.Rollback(namespace.Name)
 	}
+	return err
+}
 
-	rbacDefList, err = getRbacDefinitions(config)
+// reconcileDefinitions reconciles the changes to a namespace for each RBAC
+// Definition
+func (r *ReconcileNamespace) reconcileDefinitions(rbacDefs []rbacmanagerv1beta1.RBACDefinition, namespace *v1.Namespace) error {
+	rdr := r.reconciler
+	var applyErr error
 
-	for _, rbacDef := range rbacDefList.Items {
-		err = rdr.ReconcileNamespaceChange(&rbacDef, namespace)
+	for _, rbacDef := range rbacDefs {
+		err := rdr.ReconcileNamespaceChange(&rbacDef, namespace)
 		if err != nil {
+			// Failed changes for one RBAC Definition shouldn't prevent
+			// changes for the others from being applied
+			if rbacdefinition.IsApplyError(err) {
+				logrus.Errorf("Error reconciling %v for namespace %v: %v", rbacDef.Name, namespace.Name, err)
+				applyErr = err
+				continue
+			}
 			return err
 		}
 	}
 
-	return nil
+	return applyErr
+}
+
+// labelTransitionDefinitions indexes the label keys each RBAC Definition
+// selects on and returns the RBAC Definitions affected by a transition of the
+// namespace's labels or selected metadata, keeping their order
+func labelTransitionDefinitions(rbacDefs []rbacmanagerv1beta1.RBACDefinition, namespace *v1.Namespace) []rbacmanagerv1beta1.RBACDefinition {
+	p := rbacdefinition.Parser{}
+	for _, rbacDef := range rbacDefs {
+		rbacdefinition.Selectors.Record(rbacDef.Name, p.SelectorKeys(&rbacDef), p.SelectsOnNamespaceMetadata(&rbacDef))
+	}
+
+	affected := map[string]bool{}
+	for _, name := range rbacdefinition.Selectors.Transition(namespace) {
+		affected[name] = true
+	}
+
+	filtered := []rbacmanagerv1beta1.RBACDefinition{}
+	for _, rbacDef := range rbacDefs {
+		if affected[rbacDef.Name] {
+			filtered = append(filtered, rbacDef)
+		}
+	}
+
+	logrus.Debugf("Namespace %v changes affect %v of %v RBAC Definitions", namespace.Name, len(filtered), len(rbacDefs))
+	return filtered
 }
 
 func getRbacDefinitions(config *rest.Config) (rbacmanagerv1beta1.RBACDefinitionList, error) {
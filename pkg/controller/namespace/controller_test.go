@@ -0,0 +1,80 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"github.com/reactiveops/rbac-manager/pkg/controller/rbacdefinition"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReconcileTransitionFailure(t *testing.T) {
+	selectors := rbacdefinition.Selectors
+	rbacdefinition.Selectors = rbacdefinition.NewSelectorIndex()
+	defer func() { rbacdefinition.Selectors = selectors }()
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"team": "dev"}}}
+	client := fake.NewSimpleClientset(namespace)
+
+	failing := true
+	creates := 0
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		creates++
+		if failing {
+			return true, nil, errors.New("admission webhook denied the request")
+		}
+		return false, nil, nil
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:       "edit",
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "dev"}},
+		}},
+	}}
+	rbacDefs := []rbacmanagerv1beta1.RBACDefinition{rbacDef}
+
+	r := ReconcileNamespace{reconciler: rbacdefinition.Reconciler{Clientset: client}}
+
+	// a failed reconcile leaves the transition to be retried
+	assert.Error(t, r.reconcileTransition(rbacDefs, namespace))
+	assert.Equal(t, 1, creates)
+
+	failing = false
+	assert.NoError(t, r.reconcileTransition(rbacDefs, namespace))
+	assert.Equal(t, 2, creates)
+
+	rbs, err := client.RbacV1().RoleBindings("web").List(rbacdefinition.ListOptions)
+	assert.NoError(t, err)
+	assert.Len(t, rbs.Items, 1)
+
+	// once it succeeds the transition is recorded
+	assert.NoError(t, r.reconcileTransition(rbacDefs, namespace))
+	assert.Equal(t, 2, creates)
+}
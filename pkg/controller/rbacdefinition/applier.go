@@ -0,0 +1,195 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"context"
+	"fmt"
+
+	logrus "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Applier makes the changes determined by the Reconciler. Implementations
+// can apply changes directly to the cluster or hand them off elsewhere, such
+// as by opening a pull request against a GitOps repository.
+type Applier interface {
+	// Apply creates each object, or updates it if it already exists
+	Apply(ctx context.Context, objs []runtime.Object) error
+
+	// Delete deletes each object
+	Delete(ctx context.Context, objs []runtime.Object) error
+}
+
+// RateLimiter blocks until an operation is allowed, it is satisfied by
+// rate.Limiter
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// NewApplyRateLimiter returns a token bucket RateLimiter allowing qps
+// operations per second with bursts of up to burst operations. It is separate
+// from the client-go rate limiter, so that changes can be throttled without
+// slowing down the requests made to read state.
+func NewApplyRateLimiter(qps float64, burst int) RateLimiter {
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// RateLimitedApplier consults a RateLimiter before each object is applied or
+// deleted by the wrapped Applier
+type RateLimitedApplier struct {
+	Applier Applier
+	Limiter RateLimiter
+}
+
+// Apply waits for the Limiter before applying each object
+func (a *RateLimitedApplier) Apply(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		err := a.Limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = a.Applier.Apply(ctx, []runtime.Object{obj})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete waits for the Limiter before deleting each object
+func (a *RateLimitedApplier) Delete(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		err := a.Limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = a.Applier.Delete(ctx, []runtime.Object{obj})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClientsetApplier is the default Applier, it applies changes directly to
+// the cluster with a Kubernetes clientset
+type ClientsetApplier struct {
+	Clientset kubernetes.Interface
+}
+
+// Apply creates or updates Cluster Role Bindings, Role Bindings, and Service
+// Accounts. Service Accounts can be shared by RBAC Definitions, so existing
+// Service Accounts owned by another RBAC Definition are left unchanged.
+// Owners are compared by kind and name, so Service Accounts owned by a
+// previous instance of the same RBAC Definition are still updated. Token
+// Secrets are only created, since their data is filled in by the token
+// controller.
+func (a *ClientsetApplier) Apply(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		switch o := obj.(type) {
+		case *rbacv1.ClusterRoleBinding:
+			_, err = a.Clientset.RbacV1().ClusterRoleBindings().Create(o)
+			if apierrors.IsAlreadyExists(err) {
+				_, err = a.Clientset.RbacV1().ClusterRoleBindings().Update(o)
+			}
+		case *rbacv1.RoleBinding:
+			_, err = a.Clientset.RbacV1().RoleBindings(o.Namespace).Create(o)
+			if apierrors.IsAlreadyExists(err) {
+				_, err = a.Clientset.RbacV1().RoleBindings(o.Namespace).Update(o)
+			}
+		case *v1.ServiceAccount:
+			err = a.applyServiceAccount(o)
+		case *v1.Secret:
+			_, err = a.Clientset.CoreV1().Secrets(o.Namespace).Create(o)
+			if apierrors.IsAlreadyExists(err) {
+				err = nil
+			}
+		default:
+			err = fmt.Errorf("Unable to apply %T, only Cluster Role Bindings, Role Bindings, Service Accounts, and Secrets are supported", obj)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *ClientsetApplier) applyServiceAccount(sa *v1.ServiceAccount) error {
+	_, err := a.Clientset.CoreV1().ServiceAccounts(sa.Namespace).Create(sa)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, err := a.Clientset.CoreV1().ServiceAccounts(sa.Namespace).Get(sa.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if !ownerRefsMatch(&existing.OwnerReferences, &sa.OwnerReferences) {
+		logrus.Debugf("Service Account %v is owned by another RBAC Definition", sa.Name)
+		return nil
+	}
+
+	_, err = a.Clientset.CoreV1().ServiceAccounts(sa.Namespace).Update(sa)
+	return err
+}
+
+// Delete deletes Cluster Role Bindings, Role Bindings, Service Accounts, and
+// Secrets
+func (a *ClientsetApplier) Delete(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		switch o := obj.(type) {
+		case *rbacv1.ClusterRoleBinding:
+			err = a.Clientset.RbacV1().ClusterRoleBindings().Delete(o.Name, &metav1.DeleteOptions{})
+		case *rbacv1.RoleBinding:
+			err = a.Clientset.RbacV1().RoleBindings(o.Namespace).Delete(o.Name, &metav1.DeleteOptions{})
+		case *v1.ServiceAccount:
+			err = a.Clientset.CoreV1().ServiceAccounts(o.Namespace).Delete(o.Name, &metav1.DeleteOptions{})
+		case *v1.Secret:
+			err = a.Clientset.CoreV1().Secrets(o.Namespace).Delete(o.Name, &metav1.DeleteOptions{})
+		default:
+			err = fmt.Errorf("Unable to delete %T, only Cluster Role Bindings, Role Bindings, Service Accounts, and Secrets are supported", obj)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
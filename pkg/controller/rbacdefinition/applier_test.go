@@ -0,0 +1,244 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// recordingApplier records the keys of the objects it is asked to apply or
+// delete without changing anything
+type recordingApplier struct {
+	applied []string
+	deleted []string
+	err     error
+}
+
+func (a *recordingApplier) Apply(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		key, _ := pruneKey(obj)
+		a.applied = append(a.applied, key)
+	}
+	return a.err
+}
+
+func (a *recordingApplier) Delete(ctx context.Context, objs []runtime.Object) error {
+	for _, obj := range objs {
+		key, _ := pruneKey(obj)
+		a.deleted = append(a.deleted, key)
+	}
+	return a.err
+}
+
+// countingRateLimiter counts how often it is consulted, returning err once
+// limit operations have been allowed
+type countingRateLimiter struct {
+	waits int
+	limit int
+	err   error
+}
+
+func (l *countingRateLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	if l.limit > 0 && l.waits > l.limit {
+		return l.err
+	}
+	return nil
+}
+
+func TestRateLimitedApplier(t *testing.T) {
+	objs := []runtime.Object{
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "one"}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "web"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "three", Namespace: "bots"}},
+	}
+
+	inner := &recordingApplier{}
+	limiter := &countingRateLimiter{}
+	applier := RateLimitedApplier{Applier: inner, Limiter: limiter}
+
+	assert.NoError(t, applier.Apply(context.TODO(), objs))
+	assert.Equal(t, 3, limiter.waits)
+	assert.Len(t, inner.applied, 3)
+
+	assert.NoError(t, applier.Delete(context.TODO(), objs[:2]))
+	assert.Equal(t, 5, limiter.waits)
+	assert.Len(t, inner.deleted, 2)
+
+	// objects aren't applied once the limiter returns an error
+	inner = &recordingApplier{}
+	limiter = &countingRateLimiter{limit: 1, err: context.DeadlineExceeded}
+	applier = RateLimitedApplier{Applier: inner, Limiter: limiter}
+
+	assert.Equal(t, context.DeadlineExceeded, applier.Apply(context.TODO(), objs))
+	assert.Len(t, inner.applied, 1)
+}
+
+func TestReconcileApplyRateLimiter(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rate-limit-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{Namespace: "web", ClusterRole: "edit"},
+			{Namespace: "api", ClusterRole: "edit"},
+		},
+	}}
+
+	limiter := &countingRateLimiter{}
+	ApplyRateLimiter = limiter
+	defer func() { ApplyRateLimiter = nil }()
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	assert.Equal(t, 2, limiter.waits)
+
+	rbacDef.RBACBindings[0].RoleBindings = rbacDef.RBACBindings[0].RoleBindings[:1]
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	assert.Equal(t, 3, limiter.waits)
+
+	// the limiter is a real token bucket
+	bucket := NewApplyRateLimiter(1000, 1)
+	assert.NoError(t, bucket.Wait(context.TODO()))
+}
+
+func TestReconcileApplier(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "applier-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	// existing resources that will no longer be requested
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbacDef.RBACBindings[0].Subjects[0].Name = "other-bot"
+	rbacDef.RBACBindings[0].ClusterRoleBindings[0].ClusterRole = "admin"
+	rbacDef.RBACBindings[0].RoleBindings[0].ClusterRole = "admin"
+
+	applier := &recordingApplier{}
+	r = Reconciler{Clientset: client, Applier: applier}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	assert.ElementsMatch(t, []string{
+		"ServiceAccount/bots/other-bot",
+		"ClusterRoleBinding//applier-example-ci-bot-admin",
+		"RoleBinding/bots/applier-example-ci-bot-admin",
+	}, applier.applied)
+	assert.ElementsMatch(t, []string{
+		"ServiceAccount/bots/ci-bot",
+		"ClusterRoleBinding//applier-example-ci-bot-view",
+		"RoleBinding/bots/applier-example-ci-bot-edit",
+	}, applier.deleted)
+
+	// the cluster is left unchanged
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"},
+	}})
+
+	// Applier failures are reported like any other failure
+	applier = &recordingApplier{err: errors.New("pull request failed")}
+	r = Reconciler{Clientset: client, Applier: applier}
+	err := r.Reconcile(&rbacDef)
+	assert.True(t, IsApplyError(err), "Expected apply error, got %v", err)
+	assert.Len(t, err.(*ApplyError).Failures, 6)
+}
+
+func TestClientsetApplier(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	applier := &ClientsetApplier{Clientset: client}
+	ctx := context.Background()
+
+	ownerRefs := []metav1.OwnerReference{{Kind: "RBACDefinition", Name: "first", UID: "first-uid"}}
+	otherOwnerRefs := []metav1.OwnerReference{{Kind: "RBACDefinition", Name: "second", UID: "second-uid"}}
+
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "web"},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+	}
+	crb := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "devs"},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+	}
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots", OwnerReferences: ownerRefs},
+	}
+	assert.NoError(t, applier.Apply(ctx, []runtime.Object{rb, crb, sa}))
+
+	// existing resources are updated
+	rb.RoleRef.Name = "edit"
+	sa.Secrets = []corev1.ObjectReference{{Name: "ci-token"}}
+	assert.NoError(t, applier.Apply(ctx, []runtime.Object{rb, sa}))
+
+	actualRB, err := client.RbacV1().RoleBindings("web").Get("devs", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "edit", actualRB.RoleRef.Name)
+	expectServiceAccountSecrets(t, client, "bots", "ci-bot", []string{"ci-token"})
+
+	// Service Accounts owned by another RBAC Definition are left unchanged
+	shared := sa.DeepCopy()
+	shared.OwnerReferences = otherOwnerRefs
+	shared.Secrets = nil
+	assert.NoError(t, applier.Apply(ctx, []runtime.Object{shared}))
+	expectServiceAccountSecrets(t, client, "bots", "ci-bot", []string{"ci-token"})
+
+	assert.NoError(t, applier.Delete(ctx, []runtime.Object{rb, crb, sa}))
+
+	rbs, _ := client.RbacV1().RoleBindings("").List(metav1.ListOptions{})
+	crbs, _ := client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	sas, _ := client.CoreV1().ServiceAccounts("").List(metav1.ListOptions{})
+	assert.Empty(t, rbs.Items)
+	assert.Empty(t, crbs.Items)
+	assert.Empty(t, sas.Items)
+
+	assert.Error(t, applier.Apply(ctx, []runtime.Object{&corev1.Namespace{}}))
+	assert.Error(t, applier.Delete(ctx, []runtime.Object{&corev1.Namespace{}}))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, applier.Apply(cancelled, []runtime.Object{rb}))
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"sort"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// AuditScopeCluster is the scope of access granted by a Cluster Role Binding
+const AuditScopeCluster = "cluster"
+
+// AuditScopeNamespace is the scope of access granted by Role Bindings
+const AuditScopeNamespace = "namespace"
+
+// AuditEntry describes the access a single subject is granted to a single role
+type AuditEntry struct {
+	Subject    rbacv1.Subject
+	RoleKind   string
+	RoleName   string
+	Scope      string
+	Namespaces []string
+}
+
+// AuditReport flattens the resources an RBAC Definition refers to into a
+// list of subject, role, and scope tuples
+func (p *Parser) AuditReport(rbacDef rbacmanagerv1beta1.RBACDefinition) ([]AuditEntry, error) {
+	err := p.Parse(rbacDef)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []AuditEntry{}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		for _, subject := range crb.Subjects {
+			entries = append(entries, AuditEntry{
+				Subject:  subject,
+				RoleKind: crb.RoleRef.Kind,
+				RoleName: crb.RoleRef.Name,
+				Scope:    AuditScopeCluster,
+			})
+		}
+	}
+
+	namespaced := map[int]bool{}
+
+	for _, rb := range p.parsedRoleBindings {
+		for _, subject := range rb.Subjects {
+			index := findAuditEntry(entries, subject, rb.RoleRef, namespaced)
+			if index < 0 {
+				entries = append(entries, AuditEntry{
+					Subject:  subject,
+					RoleKind: rb.RoleRef.Kind,
+					RoleName: rb.RoleRef.Name,
+					Scope:    AuditScopeNamespace,
+				})
+				index = len(entries) - 1
+				namespaced[index] = true
+			}
+			entries[index].Namespaces = appendUnique(entries[index].Namespaces, rb.Namespace)
+		}
+	}
+
+	for index := range namespaced {
+		sort.Strings(entries[index].Namespaces)
+	}
+
+	return entries, nil
+}
+
+func findAuditEntry(entries []AuditEntry, subject rbacv1.Subject, roleRef rbacv1.RoleRef, namespaced map[int]bool) int {
+	for index, entry := range entries {
+		if namespaced[index] &&
+			subjectMatches(&entry.Subject, &subject) &&
+			entry.RoleKind == roleRef.Kind &&
+			entry.RoleName == roleRef.Name {
+			return index
+		}
+	}
+	return -1
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// BindingRef identifies a generated binding and the role it references
+type BindingRef struct {
+	Kind      string
+	Name      string
+	Namespace string
+	RoleRef   rbacv1.RoleRef
+}
+
+// EffectiveBindingsForSubject returns every binding generated for an RBAC
+// Definition that grants access to the given subject
+func (p *Parser) EffectiveBindingsForSubject(rbacDef rbacmanagerv1beta1.RBACDefinition, subject rbacv1.Subject) ([]BindingRef, error) {
+	err := p.Parse(rbacDef)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []BindingRef{}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		if hasSubject(crb.Subjects, subject) {
+			refs = append(refs, BindingRef{
+				Kind:    ResourceTypeClusterRoleBinding,
+				Name:    crb.Name,
+				RoleRef: crb.RoleRef,
+			})
+		}
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		if hasSubject(rb.Subjects, subject) {
+			refs = append(refs, BindingRef{
+				Kind:      ResourceTypeRoleBinding,
+				Name:      rb.Name,
+				Namespace: rb.Namespace,
+				RoleRef:   rb.RoleRef,
+			})
+		}
+	}
+
+	return refs, nil
+}
+
+func hasSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) bool {
+	for _, s := range subjects {
+		if subjectMatches(&s, &subject) {
+			return true
+		}
+	}
+	return false
+}
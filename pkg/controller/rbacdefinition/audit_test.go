@@ -0,0 +1,180 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAuditReport(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+	createNamespace(t, client, "db", map[string]string{"team": "db"})
+
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+	ci := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{joe, ci},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+		}, {
+			Namespace:   "db",
+			ClusterRole: "edit",
+		}},
+	}, {
+		Name:     "ci",
+		Subjects: []rbacv1.Subject{ci},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace: "bots",
+			Role:      "deployer",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	report, err := p.AuditReport(rbacDef)
+	if err != nil {
+		t.Fatalf("Error generating audit report: %v", err)
+	}
+
+	assert.ElementsMatch(t, []AuditEntry{{
+		Subject:  joe,
+		RoleKind: "ClusterRole",
+		RoleName: "view",
+		Scope:    AuditScopeCluster,
+	}, {
+		Subject:  ci,
+		RoleKind: "ClusterRole",
+		RoleName: "view",
+		Scope:    AuditScopeCluster,
+	}, {
+		Subject:    joe,
+		RoleKind:   "ClusterRole",
+		RoleName:   "edit",
+		Scope:      AuditScopeNamespace,
+		Namespaces: []string{"api", "db", "web"},
+	}, {
+		Subject:    ci,
+		RoleKind:   "ClusterRole",
+		RoleName:   "edit",
+		Scope:      AuditScopeNamespace,
+		Namespaces: []string{"api", "db", "web"},
+	}, {
+		Subject:    ci,
+		RoleKind:   "Role",
+		RoleName:   "deployer",
+		Scope:      AuditScopeNamespace,
+		Namespaces: []string{"bots"},
+	}}, report)
+}
+
+func TestAuditReportReusedParser(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+
+	for i := 0; i < 2; i++ {
+		report, err := p.AuditReport(rbacDef)
+		if err != nil {
+			t.Fatalf("Error generating audit report: %v", err)
+		}
+		assert.Len(t, report, 1, "expected one entry on report %v", i+1)
+	}
+}
+
+func TestEffectiveBindingsForSubject(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+	sue := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "sue"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{joe, sue},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+		}},
+	}, {
+		Name:     "admins",
+		Subjects: []rbacv1.Subject{joe},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "admin",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	refs, err := p.EffectiveBindingsForSubject(rbacDef, joe)
+	if err != nil {
+		t.Fatalf("Error finding effective bindings: %v", err)
+	}
+
+	assert.ElementsMatch(t, []BindingRef{{
+		Kind:    ResourceTypeClusterRoleBinding,
+		Name:    "rbac-config-admins-admin",
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"},
+	}, {
+		Kind:      ResourceTypeRoleBinding,
+		Name:      "rbac-config-devs-edit",
+		Namespace: "web",
+		RoleRef:   rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	}, {
+		Kind:      ResourceTypeRoleBinding,
+		Name:      "rbac-config-devs-edit",
+		Namespace: "api",
+		RoleRef:   rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	}}, refs)
+
+	refs, err = p.EffectiveBindingsForSubject(rbacDef, sue)
+	assert.NoError(t, err)
+	assert.Len(t, refs, 2)
+
+	refs, err = p.EffectiveBindingsForSubject(rbacDef, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "joe"})
+	assert.NoError(t, err)
+	assert.Len(t, refs, 0)
+
+	refs, err = p.EffectiveBindingsForSubject(rbacDef, joe)
+	assert.NoError(t, err)
+	assert.Len(t, refs, 3)
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"sync"
+	"time"
+)
+
+// BackoffRegistry counts the consecutive parse failures of each RBAC
+// Definition to determine how long to wait before retrying it, so that
+// definitions that always fail aren't retried in a hot loop. It is safe for
+// concurrent use.
+type BackoffRegistry struct {
+	// Initial is the delay after the first failure, it doubles with each
+	// consecutive failure after that
+	Initial time.Duration
+
+	// Max caps the delay between retries
+	Max time.Duration
+
+	mutex    sync.Mutex
+	failures map[string]int
+}
+
+// Backoff is the registry parse failures are reported to by the controllers
+var Backoff = NewBackoffRegistry(5*time.Second, 5*time.Minute)
+
+// NewBackoffRegistry returns an empty BackoffRegistry
+func NewBackoffRegistry(initial time.Duration, max time.Duration) *BackoffRegistry {
+	return &BackoffRegistry{Initial: initial, Max: max, failures: map[string]int{}}
+}
+
+// Record counts a failed parse of an RBAC Definition, a successful parse
+// resets its count
+func (b *BackoffRegistry) Record(name string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err == nil {
+		delete(b.failures, name)
+		return
+	}
+	b.failures[name]++
+}
+
+// Remove forgets an RBAC Definition, used once it has been deleted
+func (b *BackoffRegistry) Remove(name string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.failures, name)
+}
+
+// Delay returns how long to wait before retrying an RBAC Definition, which is
+// zero unless its last parse failed
+func (b *BackoffRegistry) Delay(name string) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	failures := b.failures[name]
+	if failures < 1 {
+		return 0
+	}
+
+	delay := b.Initial
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+
+	if delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
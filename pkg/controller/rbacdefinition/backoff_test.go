@@ -0,0 +1,93 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestBackoffRegistry(t *testing.T) {
+	registry := NewBackoffRegistry(time.Second, 10*time.Second)
+	failure := errors.New("forbidden")
+
+	assert.Equal(t, time.Duration(0), registry.Delay("rbac-config"))
+
+	expected := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+	for _, delay := range expected {
+		registry.Record("rbac-config", failure)
+		assert.Equal(t, delay, registry.Delay("rbac-config"))
+	}
+
+	// other RBAC Definitions are tracked separately
+	assert.Equal(t, time.Duration(0), registry.Delay("other"))
+
+	// a success resets the delay
+	registry.Record("rbac-config", nil)
+	assert.Equal(t, time.Duration(0), registry.Delay("rbac-config"))
+
+	registry.Record("rbac-config", failure)
+	assert.Equal(t, time.Second, registry.Delay("rbac-config"))
+
+	registry.Remove("rbac-config")
+	assert.Equal(t, time.Duration(0), registry.Delay("rbac-config"))
+}
+
+func TestParseBackoff(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "backoff-example"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "system:masters",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	Backoff = NewBackoffRegistry(time.Second, time.Minute)
+	defer func() { Backoff = NewBackoffRegistry(5*time.Second, 5*time.Minute) }()
+
+	r := Reconciler{Clientset: fake.NewSimpleClientset(), BackoffRegistry: Backoff}
+	recorder := record.NewFakeRecorder(1)
+
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		err := r.Reconcile(&rbacDef)
+		assert.Error(t, err)
+		assert.Equal(t, delay, handleReconcileError(&rbacDef, err, recorder).RequeueAfter)
+	}
+
+	// once fixed the RBAC Definition is no longer backed off
+	rbacDef.RBACBindings[0].Subjects[0].Name = "devs"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	assert.Equal(t, time.Duration(0), Backoff.Delay(rbacDef.Name))
+}
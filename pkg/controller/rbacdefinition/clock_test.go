@@ -0,0 +1,63 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeClock always tells the same time
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestParserClock(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	clock := &fakeClock{now: time.Date(2019, time.January, 2, 15, 4, 5, 0, time.UTC)}
+	registry := NewHealthRegistry()
+
+	p := Parser{Clientset: fake.NewSimpleClientset(), HealthRegistry: registry, Clock: clock}
+	assert.NoError(t, p.Parse(rbacDef))
+
+	status, ok := registry.Status("rbac-config")
+	assert.True(t, ok)
+	assert.Equal(t, clock.now, status.Time)
+
+	// the real clock is used by default
+	before := time.Now()
+	p = Parser{Clientset: fake.NewSimpleClientset(), HealthRegistry: registry}
+	assert.NoError(t, p.Parse(rbacDef))
+
+	status, _ = registry.Status("rbac-config")
+	assert.False(t, status.Time.Before(before))
+}
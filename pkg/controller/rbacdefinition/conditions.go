@@ -0,0 +1,128 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionReady is true once every resource an RBAC Definition refers to
+// has been reconciled
+const ConditionReady = "Ready"
+
+// ConditionReconciling is true while changes to an RBAC Definition are
+// being reconciled
+const ConditionReconciling = "Reconciling"
+
+// ReasonReconciling indicates that an RBAC Definition has changed since it
+// was last reconciled
+const ReasonReconciling = "Reconciling"
+
+// ReasonReconciled indicates that an RBAC Definition was reconciled
+const ReasonReconciled = "Reconciled"
+
+// ReasonApplyFailed indicates that changes to one or more resources failed
+const ReasonApplyFailed = "ApplyFailed"
+
+// ReasonNamespaceListForbidden indicates that RBAC Manager is missing the
+// RBAC required to list namespaces
+const ReasonNamespaceListForbidden = "NamespaceListForbidden"
+
+// ReasonReconcileFailed indicates that an RBAC Definition could not be
+// reconciled, such as when it is invalid
+const ReasonReconcileFailed = "ReconcileFailed"
+
+// SetCondition adds a condition to an RBAC Definition status or replaces the
+// condition of the same type. The last transition time is only updated when
+// the status of the condition changes.
+func SetCondition(status *rbacmanagerv1beta1.RBACDefinitionStatus, condition rbacmanagerv1beta1.RBACDefinitionCondition) {
+	for i, existing := range status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+
+		condition.LastTransitionTime = existing.LastTransitionTime
+		if existing.Status != condition.Status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		status.Conditions[i] = condition
+		return
+	}
+
+	condition.LastTransitionTime = metav1.Now()
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// GetCondition returns the condition of a type from an RBAC Definition
+// status, or nil if it has not been set
+func GetCondition(status *rbacmanagerv1beta1.RBACDefinitionStatus, conditionType string) *rbacmanagerv1beta1.RBACDefinitionCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// MarkReconciling sets the conditions of an RBAC Definition that has changed
+// and is about to be reconciled
+func MarkReconciling(rbacDef *rbacmanagerv1beta1.RBACDefinition) {
+	SetCondition(&rbacDef.Status, rbacmanagerv1beta1.RBACDefinitionCondition{
+		Type:   ConditionReconciling,
+		Status: v1.ConditionTrue,
+		Reason: ReasonReconciling,
+	})
+
+	if GetCondition(&rbacDef.Status, ConditionReady) == nil {
+		SetCondition(&rbacDef.Status, rbacmanagerv1beta1.RBACDefinitionCondition{
+			Type:   ConditionReady,
+			Status: v1.ConditionUnknown,
+			Reason: ReasonReconciling,
+		})
+	}
+}
+
+// MarkReconciled sets the conditions of an RBAC Definition from the result
+// of reconciling it
+func MarkReconciled(rbacDef *rbacmanagerv1beta1.RBACDefinition, err error) {
+	rbacDef.Status.ObservedGeneration = rbacDef.Generation
+
+	ready := rbacmanagerv1beta1.RBACDefinitionCondition{
+		Type:   ConditionReady,
+		Status: v1.ConditionTrue,
+		Reason: ReasonReconciled,
+	}
+
+	if err != nil {
+		ready.Status = v1.ConditionFalse
+		ready.Reason = ReasonReconcileFailed
+		ready.Message = err.Error()
+
+		if IsApplyError(err) {
+			ready.Reason = ReasonApplyFailed
+		} else if IsNamespaceListForbidden(err) {
+			ready.Reason = ReasonNamespaceListForbidden
+		}
+	}
+
+	SetCondition(&rbacDef.Status, ready)
+	SetCondition(&rbacDef.Status, rbacmanagerv1beta1.RBACDefinitionCondition{
+		Type:   ConditionReconciling,
+		Status: v1.ConditionFalse,
+		Reason: ready.Reason,
+	})
+}
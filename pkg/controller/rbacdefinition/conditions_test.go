@@ -0,0 +1,122 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestConditionsReconcilingToReady(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "conditions-example"
+	rbacDef.Generation = 1
+
+	MarkReconciling(&rbacDef)
+
+	expectCondition(t, &rbacDef, ConditionReconciling, corev1.ConditionTrue, ReasonReconciling)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionUnknown, ReasonReconciling)
+	assert.Equal(t, int64(0), rbacDef.Status.ObservedGeneration)
+
+	MarkReconciled(&rbacDef, nil)
+
+	expectCondition(t, &rbacDef, ConditionReconciling, corev1.ConditionFalse, ReasonReconciled)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionTrue, ReasonReconciled)
+	assert.Equal(t, int64(1), rbacDef.Status.ObservedGeneration)
+	assert.Len(t, rbacDef.Status.Conditions, 2)
+
+	// a later change doesn't reset Ready while it is reconciled
+	readySince := GetCondition(&rbacDef.Status, ConditionReady).LastTransitionTime
+	rbacDef.Generation = 2
+
+	MarkReconciling(&rbacDef)
+	expectCondition(t, &rbacDef, ConditionReconciling, corev1.ConditionTrue, ReasonReconciling)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionTrue, ReasonReconciled)
+
+	MarkReconciled(&rbacDef, nil)
+	assert.Equal(t, readySince, GetCondition(&rbacDef.Status, ConditionReady).LastTransitionTime)
+	assert.Equal(t, int64(2), rbacDef.Status.ObservedGeneration)
+
+	// reconciling again without changes leaves the status as is
+	before := rbacDef.Status.DeepCopy()
+	MarkReconciled(&rbacDef, nil)
+	assert.Equal(t, before, &rbacDef.Status)
+}
+
+func TestConditionsFailed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "conditions-example"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	MarkReconciling(&rbacDef)
+
+	r := Reconciler{Clientset: client}
+	err := r.Reconcile(&rbacDef)
+	MarkReconciled(&rbacDef, err)
+
+	expectCondition(t, &rbacDef, ConditionReconciling, corev1.ConditionFalse, ReasonApplyFailed)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionFalse, ReasonApplyFailed)
+	assert.Contains(t, GetCondition(&rbacDef.Status, ConditionReady).Message, "admission webhook denied the request")
+
+	// invalid definitions fail before any changes are applied
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespace = ""
+
+	err = r.Reconcile(&rbacDef)
+	MarkReconciled(&rbacDef, err)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionFalse, ReasonReconcileFailed)
+
+	MarkReconciled(&rbacDef, &NamespaceListForbiddenError{Err: errors.New("forbidden")})
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionFalse, ReasonNamespaceListForbidden)
+
+	// recovering from a failure marks the definition as ready again
+	MarkReconciled(&rbacDef, nil)
+	expectCondition(t, &rbacDef, ConditionReady, corev1.ConditionTrue, ReasonReconciled)
+	assert.Empty(t, GetCondition(&rbacDef.Status, ConditionReady).Message)
+}
+
+func expectCondition(t *testing.T, rbacDef *rbacmanagerv1beta1.RBACDefinition, conditionType string, status corev1.ConditionStatus, reason string) {
+	condition := GetCondition(&rbacDef.Status, conditionType)
+	if condition == nil {
+		t.Fatalf("Expected %v condition to be set", conditionType)
+	}
+
+	assert.Equal(t, status, condition.Status, "Expected %v condition status to match", conditionType)
+	assert.Equal(t, reason, condition.Reason, "Expected %v condition reason to match", conditionType)
+	assert.False(t, condition.LastTransitionTime.IsZero(), "Expected %v condition transition time to be set", conditionType)
+}
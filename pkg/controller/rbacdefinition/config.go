@@ -17,6 +17,8 @@ limitations under the License.
 package rbacdefinition
 
 import (
+	"regexp"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,3 +33,209 @@ var Labels = map[string]string{LabelKey: LabelValue}
 
 // ListOptions is the default set of options to find resources managed by RBAC Manager
 var ListOptions = metav1.ListOptions{LabelSelector: LabelKey + "=" + LabelValue}
+
+// ResourceTypeClusterRoleBinding identifies Cluster Role Bindings generated by RBAC Manager
+const ResourceTypeClusterRoleBinding = "ClusterRoleBinding"
+
+// ResourceTypeRoleBinding identifies Role Bindings generated by RBAC Manager
+const ResourceTypeRoleBinding = "RoleBinding"
+
+// ResourceTypeServiceAccount identifies Service Accounts generated by RBAC Manager
+const ResourceTypeServiceAccount = "ServiceAccount"
+
+// ResourceTypeSecret identifies Service Account token Secrets generated by RBAC Manager
+const ResourceTypeSecret = "Secret"
+
+// AllowSystemGroups allows RBAC Definitions reconciled by RBAC Manager to bind to SystemGroups
+var AllowSystemGroups = false
+
+// SystemGroups are built in groups that are rejected as subjects unless explicitly allowed
+var SystemGroups = []string{"system:authenticated", "system:unauthenticated", "system:masters"}
+
+// AllowSystemUsers allows RBAC Definitions reconciled by RBAC Manager to bind to Users with SystemUserPrefixes
+var AllowSystemUsers = false
+
+// SystemUserPrefixes are reserved User name prefixes, Users with these prefixes
+// are rejected as subjects unless explicitly allowed since they can be
+// impersonated to reach built in identities like system:kube-controller-manager
+var SystemUserPrefixes = []string{"system:"}
+
+// WarnOnEmpty logs a warning for RBAC Definitions without any RBAC Bindings
+var WarnOnEmpty = true
+
+// FailOnEmptyBinding rejects RBAC Definitions with a requested Role Binding
+// that isn't generated in any namespace
+var FailOnEmptyBinding = false
+
+// UseGenerateName creates bindings with a generated name instead of a fixed name
+var UseGenerateName = false
+
+// CompactServiceAccountNames gives Role Bindings for a single Service Account in
+// its own namespace a shorter name
+var CompactServiceAccountNames = false
+
+// MaxNameLengths lowers the longest name allowed for generated resources of
+// each type, keyed by resource type such as ResourceTypeRoleBinding
+var MaxNameLengths = map[string]int{}
+
+// SortSubjects sorts the subjects of generated bindings
+var SortSubjects = false
+
+// DefaultNameSeparator joins the parts of generated names unless another
+// NameSeparator is configured
+const DefaultNameSeparator = "-"
+
+// NameSeparator joins the parts of generated names, RBAC Definition and RBAC
+// Binding names can not contain it unless it is the DefaultNameSeparator
+var NameSeparator = DefaultNameSeparator
+
+// QualifyClusterRoleBindingNames suffixes Cluster Role Binding names with a
+// hash of the RBAC Definition UID to keep them unique across RBAC Definitions
+var QualifyClusterRoleBindingNames = false
+
+// CollapseNamespaceBindings merges Role Bindings to the same role in the same
+// namespace into one
+var CollapseNamespaceBindings = false
+
+// ExpandNamespaceGlobs creates Role Bindings in each namespace matching an
+// explicit namespace containing a *
+var ExpandNamespaceGlobs = false
+
+// CreateTokenSecret creates a long-lived token Secret for each Service Account
+var CreateTokenSecret = false
+
+// SkipTerminatingNamespaces skips explicitly named namespaces that are being
+// deleted instead of creating Role Bindings in them
+var SkipTerminatingNamespaces = false
+
+// RequireServiceAccountNamespaces only creates Service Accounts in namespaces
+// that exist, RBAC Definitions are retried until the namespaces are created
+var RequireServiceAccountNamespaces = false
+
+// SourceRevision identifies the revision of the RBAC Definition source, such
+// as a git commit, and is added to each generated resource when set
+var SourceRevision = ""
+
+// RoleLabelRules add labels to generated bindings referencing roles with
+// matching names
+var RoleLabelRules []RoleLabelRule
+
+// InheritDefinitionLabels lists labels that are copied from each RBAC
+// Definition to the resources generated for it
+var InheritDefinitionLabels []string
+
+// MaxSubjectsPerBinding rejects RBAC Definitions that would generate a
+// binding with more subjects, 0 disables the limit
+var MaxSubjectsPerBinding = 0
+
+// DefaultUserAPIGroup and DefaultGroupAPIGroup are set on User and Group
+// subjects that don't specify an API group
+var DefaultUserAPIGroup = ""
+var DefaultGroupAPIGroup = ""
+
+// Values are referenced by {{ .Values.x }} placeholders in RBAC Definitions,
+// see LoadValuesFile
+var Values map[string]interface{}
+
+// DisallowedSubjectKinds rejects RBAC Definitions with subjects of these
+// kinds, see ParseSubjectKinds
+var DisallowedSubjectKinds = map[string]bool{}
+
+// SubjectNamePatterns rejects RBAC Definitions with subject names that don't
+// match the pattern for their kind, see ParseSubjectNamePatterns
+var SubjectNamePatterns map[string]*regexp.Regexp
+
+// GroupNameMap translates external group names to the group names used in
+// the cluster, see LoadGroupNameMapFile
+var GroupNameMap map[string]string
+
+// RoleAliases translates friendly Cluster Role names used in RBAC
+// Definitions to the Cluster Roles they refer to, see ParseRoleAliases
+var RoleAliases map[string]string
+
+// ValidateRoleAliases rejects RBAC Definitions with Cluster Role names that
+// are neither RoleAliases nor existing Cluster Roles
+var ValidateRoleAliases = false
+
+// EnabledResourceTypes restricts the types of resources generated and
+// reconciled, see ParseResourceTypes. All types are enabled when empty.
+var EnabledResourceTypes map[string]bool
+
+// PropagateNamespaceLabels lists namespace labels that are copied to the
+// Service Accounts generated in each namespace
+var PropagateNamespaceLabels []string
+
+// DefaultApplier makes the changes determined by the controllers, changes are
+// applied directly to the cluster when it is nil
+var DefaultApplier Applier
+
+// ApplyRateLimiter throttles the changes made by the Applier, changes are not
+// throttled when it is nil
+var ApplyRateLimiter RateLimiter
+
+// ReconcileOnLabelTransitions only reconciles the RBAC Definitions selecting
+// on namespace labels that changed, rather than every RBAC Definition with a
+// namespace selector on each namespace change, see SelectorIndex
+var ReconcileOnLabelTransitions = false
+
+// ResolveAggregatedClusterRoles logs the Cluster Roles aggregated by bound Cluster Roles
+var ResolveAggregatedClusterRoles = false
+
+// CheckRequesterAccess only generates Role Bindings in namespaces where the
+// user named by the RequesterAnnotation of an RBAC Definition can create Role
+// Bindings, RBAC Definitions without the annotation are rejected
+var CheckRequesterAccess = false
+
+// ProtectedNamespaces are namespaces that RBAC Manager will never delete managed resources from
+var ProtectedNamespaces = map[string]bool{}
+
+// ManagedAnnotationPrefix prefixes the annotations RBAC Manager adds to the
+// resources it generates
+const ManagedAnnotationPrefix = "rbac-manager/"
+
+// RequesterAnnotation is set on an RBAC Definition to the user it was
+// requested by, such as by an admission webhook, for CheckRequesterAccess
+const RequesterAnnotation = "rbac-manager/requester"
+
+// RequesterGroupsAnnotation is set on an RBAC Definition to the comma
+// separated groups of the user named by RequesterAnnotation
+const RequesterGroupsAnnotation = "rbac-manager/requester-groups"
+
+// ExpiresAtAnnotation is added to resources generated for RBAC Bindings with
+// an expiry, for enforcement by an external process
+const ExpiresAtAnnotation = "rbac-manager/expires-at"
+
+// SpecHashAnnotation is added to each generated resource with the SpecHash
+// of its requested spec, so that out of band changes can be detected
+const SpecHashAnnotation = "rbac-manager/spec-hash"
+
+// SourceRevisionAnnotation is added to each generated resource with the
+// revision of the RBAC Definition source when the Parser has one
+const SourceRevisionAnnotation = "rbac-manager/source-revision"
+
+// SubjectDisplayAnnotationPrefix is followed by a subject name in the
+// annotations added to resources generated for RBAC Bindings with
+// subjectDisplayNames, the value is the display name of the subject
+const SubjectDisplayAnnotationPrefix = "rbac-manager/subject-display-"
+
+// ApplyOrderAnnotation is added to resources generated for RBAC Bindings with
+// an applyOrder, for downstream tooling that applies bindings in order.
+// Resources without it have an apply order of 0.
+const ApplyOrderAnnotation = "rbac-manager/apply-order"
+
+// DryRunAnnotation is added to resources generated for RBAC Bindings with
+// dryRun set, the reconciler logs these resources instead of applying them
+const DryRunAnnotation = "rbac-manager/dry-run"
+
+// FreezeAnnotation can be set to "true" on a managed resource to stop RBAC
+// Manager from updating or deleting it, such as during a migration
+const FreezeAnnotation = "rbac-manager/freeze"
+
+// AnnotationOperatorIn requires a namespace annotation to have one of the listed values
+const AnnotationOperatorIn = "In"
+
+// AnnotationOperatorNotIn requires a namespace annotation to be missing or have none of the listed values
+const AnnotationOperatorNotIn = "NotIn"
+
+// AnnotationOperatorExists requires a namespace annotation to be set
+const AnnotationOperatorExists = "Exists"
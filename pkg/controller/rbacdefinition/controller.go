@@ -18,11 +18,21 @@ package rbacdefinition
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
 
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	logrus "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -31,6 +41,47 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// DefaultResyncInterval is how often RBAC Definitions with namespace selectors
+// are requeued when they don't specify their own resync interval. A zero
+// value disables periodic resyncs.
+var DefaultResyncInterval time.Duration
+
+// ShadowMode logs the changes RBAC Manager would make without applying them
+var ShadowMode = false
+
+// ServerValidate validates each generated resource with a dry run request
+// before any changes are applied
+var ServerValidate = false
+
+// OpenShiftProjects evaluates namespace selectors against OpenShift Projects
+// instead of core Kubernetes namespaces
+var OpenShiftProjects = false
+
+// TeamNamespaceLabel is the namespace label whose value names the team a
+// namespace belongs to, Role Bindings with a team are rejected when empty
+var TeamNamespaceLabel = ""
+
+// ResolveNodeNamespaces resolves the namespaces of Role Bindings with a node
+// selector from the pods scheduled on matching nodes, Role Bindings with a
+// node selector are rejected when unset
+var ResolveNodeNamespaces = false
+
+// ResolveResourcePresence checks namespaces for the resources required by
+// Role Bindings, Role Bindings requiring a resource are rejected when unset
+var ResolveResourcePresence = false
+
+// GroupMembersConfigMap is the namespace/name of a ConfigMap listing the
+// members of groups, Group subjects are passed through as is when empty
+var GroupMembersConfigMap = ""
+
+// ForbiddenRequeueInterval is how long to wait before retrying an RBAC
+// Definition that failed because RBAC Manager is not allowed to list namespaces
+var ForbiddenRequeueInterval = 5 * time.Minute
+
+// MissingNamespaceRequeueInterval is how long to wait before retrying an RBAC
+// Definition with Service Accounts in namespaces that don't exist yet
+var MissingNamespaceRequeueInterval = 30 * time.Second
+
 // Add creates a new RBACDefinition Controller and adds it to the Manager.
 // The Manager will set fields on the Controller and Start it.
 func Add(mgr manager.Manager) error {
@@ -39,14 +90,88 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
-
+	rdr, err := NewReconciler(mgr)
 	if err != nil {
 		// If we can't get a clientset we can't do anything else
 		panic(err)
 	}
 
-	return &ReconcileRBACDefinition{Client: mgr.GetClient(), clientset: clientset, scheme: mgr.GetScheme()}
+	return &ReconcileRBACDefinition{
+		Client:     mgr.GetClient(),
+		scheme:     mgr.GetScheme(),
+		recorder:   rdr.Recorder,
+		reconciler: rdr,
+	}
+}
+
+// NewReconciler returns a Reconciler configured from the package options,
+// shared by every controller that reconciles RBAC Definitions so that they
+// all generate and apply resources the same way
+func NewReconciler(mgr manager.Manager) (Reconciler, error) {
+	// Full Kubernetes ClientSet is required because RBAC types don't
+	//   implement methods required for Kubebuilder methods to work
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return Reconciler{}, err
+	}
+
+	var namespaceLister NamespaceLister
+	if OpenShiftProjects {
+		namespaceLister, err = NewProjectNamespaceLister(mgr.GetConfig())
+		if err != nil {
+			return Reconciler{}, err
+		}
+	}
+
+	var teamNamespaceLister TeamNamespaceLister
+	if TeamNamespaceLabel != "" {
+		lister := namespaceLister
+		if lister == nil {
+			lister = &ClientsetNamespaceLister{Clientset: clientset}
+		}
+		teamNamespaceLister = &LabelTeamNamespaceLister{NamespaceLister: lister, Label: TeamNamespaceLabel}
+	}
+
+	var nodeNamespaceResolver NodeNamespaceResolver
+	if ResolveNodeNamespaces {
+		nodeNamespaceResolver = &ClientsetNodeNamespaceResolver{Clientset: clientset}
+	}
+
+	var resourcePresenceResolver ResourcePresenceResolver
+	if ResolveResourcePresence {
+		client, err := dynamic.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return Reconciler{}, err
+		}
+		resourcePresenceResolver = &DynamicResourcePresenceResolver{Client: client, Mapper: mgr.GetRESTMapper()}
+	}
+
+	var groupMembershipLister GroupMembershipLister
+	if GroupMembersConfigMap != "" {
+		parts := strings.Split(GroupMembersConfigMap, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return Reconciler{}, fmt.Errorf("Invalid group members ConfigMap %v, expected namespace/name", GroupMembersConfigMap)
+		}
+		groupMembershipLister = &ConfigMapGroupMembershipLister{Clientset: clientset, Namespace: parts[0], Name: parts[1]}
+	}
+
+	return Reconciler{
+		Clientset:                clientset,
+		NamespaceLister:          namespaceLister,
+		GroupMembershipLister:    groupMembershipLister,
+		HTTPClient:               &http.Client{Timeout: SubjectsURLTimeout},
+		TeamNamespaceLister:      teamNamespaceLister,
+		NodeNamespaceResolver:    nodeNamespaceResolver,
+		ResourcePresenceResolver: resourcePresenceResolver,
+		ShadowMode:               ShadowMode,
+		ServerValidate:           ServerValidate,
+		Recorder:                 mgr.GetRecorder("rbac-manager"),
+		HealthRegistry:           Health,
+		BackoffRegistry:          Backoff,
+		RoleIndex:                Roles,
+		ResolverMetrics:          Metrics,
+		Applier:                  DefaultApplier,
+	}, nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -63,20 +188,33 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to the Cluster Roles and Roles referenced by
+	// generated bindings, requeueing the RBAC Definitions that depend on them
+	err = c.Watch(&source.Kind{Type: &rbacv1.ClusterRole{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: Roles.Requests("ClusterRole")})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &rbacv1.Role{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: Roles.Requests("Role")})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // ReconcileRBACDefinition reconciles a RBACDefinition object
 type ReconcileRBACDefinition struct {
 	client.Client
-	scheme    *runtime.Scheme
-	clientset kubernetes.Interface
+	scheme     *runtime.Scheme
+	recorder   record.EventRecorder
+	reconciler Reconciler
 }
 
 // Reconcile makes changes in response to RBACDefinition changes
 func (r *ReconcileRBACDefinition) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	var err error
-	rdr := Reconciler{Clientset: r.clientset}
+	rdr := r.reconciler
 
 	// Fetch the RBACDefinition instance
 	rbacDef := &rbacmanagerv1beta1.RBACDefinition{}
@@ -85,13 +223,91 @@ func (r *ReconcileRBACDefinition) Reconcile(request reconcile.Request) (reconcil
 		if errors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			Health.Remove(request.Name)
+			Backoff.Remove(request.Name)
+			Roles.Remove(request.Name)
+			Selectors.Remove(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	rdr.Reconcile(rbacDef)
+	// Status updates don't change the generation, so Reconciling is only
+	// set once per change to avoid requeueing in response to itself
+	if rbacDef.Generation != rbacDef.Status.ObservedGeneration {
+		MarkReconciling(rbacDef)
+		r.updateStatus(rbacDef)
+	}
+
+	previousStatus := rbacDef.Status.DeepCopy()
+
+	err = rdr.Reconcile(rbacDef)
+	MarkReconciled(rbacDef, err)
+
+	if !reflect.DeepEqual(previousStatus, &rbacDef.Status) {
+		r.updateStatus(rbacDef)
+	}
+
+	if err != nil {
+		return handleReconcileError(rbacDef, err, r.recorder), nil
+	}
+
+	return reconcile.Result{RequeueAfter: resyncInterval(rbacDef, DefaultResyncInterval)}, nil
+}
+
+// updateStatus persists the status of an RBAC Definition. Failures are only
+// logged since the status is informational.
+func (r *ReconcileRBACDefinition) updateStatus(rbacDef *rbacmanagerv1beta1.RBACDefinition) {
+	err := r.Status().Update(context.TODO(), rbacDef)
+	if err != nil {
+		logrus.Warnf("Error updating status of RBACDefinition %v: %v", rbacDef.Name, err)
+	}
+}
+
+// handleReconcileError reports a failure to reconcile an RBAC Definition.
+// Missing namespace list RBAC won't resolve itself quickly, so it is reported
+// with an event and retried after ForbiddenRequeueInterval. Service Accounts
+// waiting for their namespaces are retried after
+// MissingNamespaceRequeueInterval. Changes that
+// failed to apply are retried with backoff, changes that succeeded will match
+// on the next attempt and are left alone. RBAC Definitions that keep failing
+// to parse are retried after the increasing delay tracked by Backoff.
+func handleReconcileError(rbacDef *rbacmanagerv1beta1.RBACDefinition, err error, recorder record.EventRecorder) reconcile.Result {
+	logrus.Errorf("Error reconciling RBACDefinition %v: %v", rbacDef.Name, err)
+
+	if IsNamespaceListForbidden(err) {
+		recorder.Event(rbacDef, v1.EventTypeWarning, "MissingNamespaceListRBAC", err.Error())
+		return reconcile.Result{RequeueAfter: ForbiddenRequeueInterval}
+	}
+
+	if IsMissingNamespace(err) {
+		return reconcile.Result{RequeueAfter: MissingNamespaceRequeueInterval}
+	}
+
+	if delay := Backoff.Delay(rbacDef.Name); delay > 0 {
+		return reconcile.Result{RequeueAfter: delay}
+	}
+
+	if IsApplyError(err) {
+		return reconcile.Result{Requeue: true}
+	}
+
+	return reconcile.Result{RequeueAfter: resyncInterval(rbacDef, DefaultResyncInterval)}
+}
+
+// resyncInterval determines how long to wait before requeueing an RBAC
+// Definition. Only definitions with namespace selectors need periodic
+// resyncs to catch label changes that don't trigger namespace events.
+func resyncInterval(rbacDef *rbacmanagerv1beta1.RBACDefinition, defaultInterval time.Duration) time.Duration {
+	p := Parser{ExpandNamespaceGlobs: ExpandNamespaceGlobs}
+	if !p.HasNamespaceSelectors(rbacDef) {
+		return 0
+	}
+
+	if rbacDef.ResyncIntervalSeconds > 0 {
+		return time.Duration(rbacDef.ResyncIntervalSeconds) * time.Second
+	}
 
-	return reconcile.Result{}, nil
+	return defaultInterval
 }
@@ -0,0 +1,248 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+func TestResyncInterval(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "resync-example"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	// no namespace selectors, nothing to resync
+	assert.Equal(t, time.Duration(0), resyncInterval(&rbacDef, time.Minute))
+
+	rbacDef.ResyncIntervalSeconds = 30
+	assert.Equal(t, time.Duration(0), resyncInterval(&rbacDef, time.Minute))
+
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+		ClusterRole:       "edit",
+	}}
+
+	// definition interval overrides the default
+	assert.Equal(t, 30*time.Second, resyncInterval(&rbacDef, time.Minute))
+
+	// falls back to the default when unset
+	rbacDef.ResyncIntervalSeconds = 0
+	assert.Equal(t, time.Minute, resyncInterval(&rbacDef, time.Minute))
+	assert.Equal(t, time.Duration(0), resyncInterval(&rbacDef, 0))
+
+	// namespace globs are only resynced when they are expanded
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		Namespace:   "team-*",
+		ClusterRole: "edit",
+	}}
+	assert.Equal(t, time.Duration(0), resyncInterval(&rbacDef, time.Minute))
+
+	ExpandNamespaceGlobs = true
+	defer func() { ExpandNamespaceGlobs = false }()
+	assert.Equal(t, time.Minute, resyncInterval(&rbacDef, time.Minute))
+}
+
+func TestNamespaceListForbidden(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("not allowed"))
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "forbidden-example"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	err := r.Reconcile(&rbacDef)
+	assert.True(t, IsNamespaceListForbidden(err), "Expected namespace list forbidden error, got %v", err)
+
+	recorder := record.NewFakeRecorder(1)
+	result := handleReconcileError(&rbacDef, err, recorder)
+	assert.Equal(t, ForbiddenRequeueInterval, result.RequeueAfter)
+
+	select {
+	case event := <-recorder.Events:
+		assert.Contains(t, event, "MissingNamespaceListRBAC")
+	default:
+		t.Fatal("Expected an event to be recorded")
+	}
+
+	// other errors don't record events
+	result = handleReconcileError(&rbacDef, errors.New("something else"), recorder)
+	assert.Equal(t, time.Duration(0), result.RequeueAfter)
+	assert.Len(t, recorder.Events, 0)
+}
+
+func TestMissingServiceAccountNamespace(t *testing.T) {
+	defer func(require bool) { RequireServiceAccountNamespaces = require }(RequireServiceAccountNamespaces)
+	RequireServiceAccountNamespaces = true
+
+	client := fake.NewSimpleClientset()
+	createNamespace(t, client, "bots", map[string]string{})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "missing-namespace-example"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "bots",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"},
+			{Kind: rbacv1.ServiceAccountKind, Name: "deploy-bot", Namespace: "deploy"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	reconcileErr := r.Reconcile(&rbacDef)
+	assert.True(t, IsMissingNamespace(reconcileErr), "Expected missing namespace error, got %v", reconcileErr)
+	assert.EqualError(t, reconcileErr, "Service Account namespaces do not exist yet: deploy")
+
+	// Service Accounts in namespaces that exist and bindings are still created
+	serviceAccounts, err := client.CoreV1().ServiceAccounts("").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, serviceAccounts.Items, 1) {
+		assert.Equal(t, "bots", serviceAccounts.Items[0].Namespace)
+	}
+
+	crbs, err := client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, crbs.Items, 1) {
+		assert.Len(t, crbs.Items[0].Subjects, 2)
+	}
+
+	recorder := record.NewFakeRecorder(1)
+	result := handleReconcileError(&rbacDef, reconcileErr, recorder)
+	assert.Equal(t, MissingNamespaceRequeueInterval, result.RequeueAfter)
+
+	// once the namespace exists the Service Account is created
+	createNamespace(t, client, "deploy", map[string]string{})
+	err = r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	serviceAccounts, err = client.CoreV1().ServiceAccounts("").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, serviceAccounts.Items, 2)
+}
+
+// stubManager provides the parts of a manager.Manager used by NewReconciler
+type stubManager struct {
+	manager.Manager
+	recorder record.EventRecorder
+}
+
+func (m *stubManager) GetConfig() *rest.Config {
+	return &rest.Config{Host: "https://kubernetes.invalid"}
+}
+
+func (m *stubManager) GetRecorder(name string) record.EventRecorder {
+	return m.recorder
+}
+
+func (m *stubManager) GetRESTMapper() meta.RESTMapper {
+	return meta.NewDefaultRESTMapper(nil)
+}
+
+func TestNewReconciler(t *testing.T) {
+	ServerValidate = true
+	ShadowMode = true
+	TeamNamespaceLabel = "team"
+	ResolveNodeNamespaces = true
+	ResolveResourcePresence = true
+	GroupMembersConfigMap = "rbac-manager/groups"
+	defer func() {
+		ServerValidate = false
+		ShadowMode = false
+		TeamNamespaceLabel = ""
+		ResolveNodeNamespaces = false
+		ResolveResourcePresence = false
+		GroupMembersConfigMap = ""
+	}()
+
+	recorder := record.NewFakeRecorder(10)
+	rdr, err := NewReconciler(&stubManager{recorder: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotNil(t, rdr.Clientset)
+	assert.True(t, rdr.ServerValidate)
+	assert.True(t, rdr.ShadowMode)
+	assert.Equal(t, recorder, rdr.Recorder)
+	assert.Equal(t, Health, rdr.HealthRegistry)
+	assert.Equal(t, Backoff, rdr.BackoffRegistry)
+	assert.Equal(t, Roles, rdr.RoleIndex)
+	assert.Equal(t, Metrics, rdr.ResolverMetrics)
+	if assert.IsType(t, &LabelTeamNamespaceLister{}, rdr.TeamNamespaceLister) {
+		assert.Equal(t, "team", rdr.TeamNamespaceLister.(*LabelTeamNamespaceLister).Label)
+	}
+	assert.IsType(t, &ClientsetNodeNamespaceResolver{}, rdr.NodeNamespaceResolver)
+	assert.IsType(t, &DynamicResourcePresenceResolver{}, rdr.ResourcePresenceResolver)
+	if assert.NotNil(t, rdr.HTTPClient) {
+		assert.Equal(t, SubjectsURLTimeout, rdr.HTTPClient.Timeout)
+	}
+	assert.Equal(t, &ConfigMapGroupMembershipLister{
+		Clientset: rdr.Clientset,
+		Namespace: "rbac-manager",
+		Name:      "groups",
+	}, rdr.GroupMembershipLister)
+
+	GroupMembersConfigMap = "groups"
+	_, err = NewReconciler(&stubManager{recorder: recorder})
+	assert.EqualError(t, err, "Invalid group members ConfigMap groups, expected namespace/name")
+}
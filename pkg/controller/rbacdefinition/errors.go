@@ -0,0 +1,110 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceListForbiddenError indicates that RBAC Manager is missing the
+// RBAC required to list namespaces when evaluating namespace selectors
+type NamespaceListForbiddenError struct {
+	Err error
+}
+
+func (e *NamespaceListForbiddenError) Error() string {
+	return fmt.Sprintf("Missing namespace list RBAC, unable to evaluate namespace selector: %v", e.Err)
+}
+
+// IsNamespaceListForbidden returns true if an error was caused by RBAC
+// Manager not being allowed to list namespaces
+func IsNamespaceListForbidden(err error) bool {
+	_, ok := err.(*NamespaceListForbiddenError)
+	return ok
+}
+
+// MissingNamespaceError indicates that Service Accounts were not created
+// because their namespaces don't exist yet, everything else is still applied
+type MissingNamespaceError struct {
+	Namespaces []string
+}
+
+func (e *MissingNamespaceError) Error() string {
+	return fmt.Sprintf("Service Account namespaces do not exist yet: %v", strings.Join(e.Namespaces, ", "))
+}
+
+// IsMissingNamespace returns true if an error was caused by Service Account
+// namespaces that don't exist yet
+func IsMissingNamespace(err error) bool {
+	_, ok := err.(*MissingNamespaceError)
+	return ok
+}
+
+// ApplyFailure describes a change to a single resource that could not be
+// applied
+type ApplyFailure struct {
+	Action       string
+	ResourceType string
+	Namespace    string
+	Name         string
+	Err          error
+}
+
+// ApplyError aggregates the changes that failed while reconciling an RBAC
+// Definition, the remaining changes are still applied
+type ApplyError struct {
+	Attempted int
+	Failures  []ApplyFailure
+}
+
+func (e *ApplyError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%v %v %v/%v: %v", f.Action, f.ResourceType, f.Namespace, f.Name, f.Err)
+	}
+
+	return fmt.Sprintf("Failed to apply %v of %v changes: %v", len(e.Failures), e.Attempted, strings.Join(failures, ", "))
+}
+
+// ServerValidationError aggregates the resources rejected by the API server
+// when validating an RBAC Definition, no changes are applied when it occurs
+type ServerValidationError struct {
+	Validated int
+	Failures  []ApplyFailure
+}
+
+func (e *ServerValidationError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%v %v/%v: %v", f.ResourceType, f.Namespace, f.Name, f.Err)
+	}
+
+	return fmt.Sprintf("Server rejected %v of %v resources: %v", len(e.Failures), e.Validated, strings.Join(failures, ", "))
+}
+
+// IsServerValidationError returns true if an error was caused by the API
+// server rejecting one or more generated resources
+func IsServerValidationError(err error) bool {
+	_, ok := err.(*ServerValidationError)
+	return ok
+}
+
+// IsApplyError returns true if an error was caused by changes to one or
+// more resources failing
+func IsApplyError(err error) bool {
+	_, ok := err.(*ApplyError)
+	return ok
+}
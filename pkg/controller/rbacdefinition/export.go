@@ -0,0 +1,237 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serverManagedFields are metadata fields set by the API server, they are
+// stripped from exported resources so that diffs only show real changes
+var serverManagedFields = []string{"creationTimestamp", "resourceVersion", "selfLink", "uid"}
+
+// kustomization is the subset of a Kustomize kustomization.yaml written by
+// ExportKustomize
+type kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources"`
+}
+
+// RegoScopeCluster and RegoScopeNamespace are the kinds of scope access is
+// granted in by exported Rego facts
+const (
+	RegoScopeCluster   = "Cluster"
+	RegoScopeNamespace = "Namespace"
+)
+
+// regoData is the JSON document written by ExportRegoData, loaded into OPA
+// as data with a fact for each subject of each generated binding
+type regoData struct {
+	Bindings []regoFact `json:"bindings"`
+}
+
+// regoFact grants a subject a role in a scope through a generated binding
+type regoFact struct {
+	Subject regoSubject `json:"subject"`
+	Role    regoRole    `json:"role"`
+	Scope   regoScope   `json:"scope"`
+	Binding string      `json:"binding"`
+}
+
+type regoSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type regoRole struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type regoScope struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// exportedResource is a generated resource prepared for export
+type exportedResource struct {
+	kind string
+	meta metav1.ObjectMeta
+	obj  interface{}
+}
+
+// RenderYAML renders each resource generated by the last call to Parse as a
+// multi-document YAML stream, in the same form as ExportKustomize
+func (p *Parser) RenderYAML() ([]byte, error) {
+	var buf bytes.Buffer
+
+	for i, resource := range p.exportedResources() {
+		data, err := renderResource(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ExportKustomize writes each resource generated by the last call to Parse
+// to its own file in dir, along with a kustomization.yaml listing them.
+// Owner references are omitted since the resources will not be owned by an
+// RBAC Definition once they are applied from another source.
+func (p *Parser) ExportKustomize(dir string) error {
+	resources := []string{}
+
+	for _, resource := range p.exportedResources() {
+		fileName := strings.ToLower(resource.kind) + "-" + resource.meta.Name + ".yaml"
+		if resource.meta.Namespace != "" {
+			fileName = strings.ToLower(resource.kind) + "-" + resource.meta.Namespace + "-" + resource.meta.Name + ".yaml"
+		}
+
+		data, err := renderResource(resource)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, fileName), data, 0644)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, fileName)
+	}
+
+	data, err := yaml.Marshal(kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  resources,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "kustomization.yaml"), data, 0644)
+}
+
+// ExportRegoData renders the bindings generated by the last call to Parse as
+// a JSON document of facts for OPA, one for each subject of each Cluster Role
+// Binding and Role Binding, in the order they were generated
+func (p *Parser) ExportRegoData() ([]byte, error) {
+	data := regoData{Bindings: []regoFact{}}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		for _, subject := range crb.Subjects {
+			data.Bindings = append(data.Bindings, regoFact{
+				Subject: regoSubject{Kind: subject.Kind, Name: subject.Name, Namespace: subject.Namespace},
+				Role:    regoRole{Kind: crb.RoleRef.Kind, Name: crb.RoleRef.Name},
+				Scope:   regoScope{Kind: RegoScopeCluster},
+				Binding: crb.Name,
+			})
+		}
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		for _, subject := range rb.Subjects {
+			data.Bindings = append(data.Bindings, regoFact{
+				Subject: regoSubject{Kind: subject.Kind, Name: subject.Name, Namespace: subject.Namespace},
+				Role:    regoRole{Kind: rb.RoleRef.Kind, Name: rb.RoleRef.Name},
+				Scope:   regoScope{Kind: RegoScopeNamespace, Namespace: rb.Namespace},
+				Binding: rb.Name,
+			})
+		}
+	}
+
+	rendered, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering Rego data: %v", err)
+	}
+
+	return append(rendered, '\n'), nil
+}
+
+// exportedResources returns the parsed resources with type meta set and
+// owner references omitted
+func (p *Parser) exportedResources() []exportedResource {
+	resources := []exportedResource{}
+
+	for _, sa := range p.parsedServiceAccounts {
+		sa.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"}
+		sa.OwnerReferences = nil
+		resources = append(resources, exportedResource{kind: sa.Kind, meta: sa.ObjectMeta, obj: sa})
+	}
+
+	for _, secret := range p.parsedSecrets {
+		secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+		secret.OwnerReferences = nil
+		resources = append(resources, exportedResource{kind: secret.Kind, meta: secret.ObjectMeta, obj: secret})
+	}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		crb.TypeMeta = metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"}
+		crb.OwnerReferences = nil
+		resources = append(resources, exportedResource{kind: crb.Kind, meta: crb.ObjectMeta, obj: crb})
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		rb.TypeMeta = metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"}
+		rb.OwnerReferences = nil
+		resources = append(resources, exportedResource{kind: rb.Kind, meta: rb.ObjectMeta, obj: rb})
+	}
+
+	return resources
+}
+
+// renderResource renders a resource as YAML without server managed fields.
+// A zero creationTimestamp is otherwise rendered as null.
+func renderResource(resource exportedResource) ([]byte, error) {
+	data, err := json.Marshal(resource.obj)
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering %v %v: %v", resource.kind, resource.meta.Name, err)
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering %v %v: %v", resource.kind, resource.meta.Name, err)
+	}
+
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverManagedFields {
+			delete(metadata, field)
+		}
+	}
+
+	data, err = yaml.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering %v %v: %v", resource.kind, resource.meta.Name, err)
+	}
+
+	return data, nil
+}
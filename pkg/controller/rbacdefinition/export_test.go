@@ -0,0 +1,315 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExportKustomize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac-manager-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset(), CreateTokenSecret: true}
+	p.ownerRefs = rbacDefOwnerRefs(&rbacDef)
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ExportKustomize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := kustomization{}
+	err = yaml.Unmarshal(data, &k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Kustomization", k.Kind)
+	assert.Equal(t, []string{
+		"serviceaccount-bots-ci-bot.yaml",
+		"secret-bots-ci-bot-token.yaml",
+		"clusterrolebinding-rbac-config-ci-bot-view.yaml",
+		"rolebinding-bots-rbac-config-ci-bot-edit.yaml",
+	}, k.Resources)
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "rolebinding-bots-rbac-config-ci-bot-edit.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rb := rbacv1.RoleBinding{}
+	err = yaml.Unmarshal(data, &rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "RoleBinding", rb.Kind)
+	assert.Equal(t, "rbac.authorization.k8s.io/v1", rb.APIVersion)
+	assert.Equal(t, "rbac-config-ci-bot-edit", rb.Name)
+	assert.Equal(t, "bots", rb.Namespace)
+	assert.Equal(t, "edit", rb.RoleRef.Name)
+	assert.Len(t, rb.Subjects, 1)
+	assert.Empty(t, rb.OwnerReferences)
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "secret-bots-ci-bot-token.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := corev1.Secret{}
+	err = yaml.Unmarshal(data, &secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Secret", secret.Kind)
+	assert.Equal(t, "v1", secret.APIVersion)
+	assert.Equal(t, corev1.SecretTypeServiceAccountToken, secret.Type)
+	assert.Equal(t, "ci-bot", secret.Annotations[corev1.ServiceAccountNameKey])
+	assert.Empty(t, secret.OwnerReferences)
+}
+
+// regoDataGolden is the Rego data expected for the RBAC Definition in
+// TestExportRegoData
+const regoDataGolden = `{
+  "bindings": [
+    {
+      "subject": {
+        "kind": "ServiceAccount",
+        "name": "ci-bot",
+        "namespace": "bots"
+      },
+      "role": {
+        "kind": "ClusterRole",
+        "name": "view"
+      },
+      "scope": {
+        "kind": "Cluster"
+      },
+      "binding": "rbac-config-ci-bot-view"
+    },
+    {
+      "subject": {
+        "kind": "ServiceAccount",
+        "name": "ci-bot",
+        "namespace": "bots"
+      },
+      "role": {
+        "kind": "ClusterRole",
+        "name": "edit"
+      },
+      "scope": {
+        "kind": "Namespace",
+        "namespace": "bots"
+      },
+      "binding": "rbac-config-ci-bot-edit"
+    },
+    {
+      "subject": {
+        "kind": "User",
+        "name": "joe"
+      },
+      "role": {
+        "kind": "Role",
+        "name": "deployer"
+      },
+      "scope": {
+        "kind": "Namespace",
+        "namespace": "web"
+      },
+      "binding": "rbac-config-deployers-deployer-web"
+    },
+    {
+      "subject": {
+        "kind": "Group",
+        "name": "release-managers"
+      },
+      "role": {
+        "kind": "Role",
+        "name": "deployer"
+      },
+      "scope": {
+        "kind": "Namespace",
+        "namespace": "web"
+      },
+      "binding": "rbac-config-deployers-deployer-web"
+    }
+  ]
+}
+`
+
+func TestExportRegoData(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}, {
+		Name: "deployers",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, Name: "joe"},
+			{Kind: rbacv1.GroupKind, Name: "release-managers"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace: "web",
+			Role:      "deployer",
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := p.ExportRegoData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, regoDataGolden, string(data))
+
+	// nothing generated still renders a document OPA can load
+	p = Parser{Clientset: fake.NewSimpleClientset()}
+	data, err = p.ExportRegoData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "{\n  \"bindings\": []\n}\n", string(data))
+}
+
+func TestRenderYAMLServerManagedFields(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fields set by the API server, as if a resource had been read back
+	p.parsedRoleBindings[0].ResourceVersion = "12345"
+	p.parsedRoleBindings[0].UID = "6c5e2f1a-0b7d-4a4e-9f43-2d1c8e7b9a10"
+	p.parsedRoleBindings[0].SelfLink = "/apis/rbac.authorization.k8s.io/v1/namespaces/bots/rolebindings/rbac-config-ci-bot-edit"
+	p.parsedRoleBindings[0].CreationTimestamp = metav1.Now()
+
+	data, err := p.RenderYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := strings.Split(string(data), "---\n")
+	assert.Len(t, documents, 3)
+
+	dir, err := ioutil.TempDir("", "rbac-manager-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = p.ExportKustomize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := ioutil.ReadFile(filepath.Join(dir, "rolebinding-bots-rbac-config-ci-bot-edit.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents = append(documents, string(exported))
+
+	for _, document := range documents {
+		for _, field := range []string{"creationTimestamp", "resourceVersion", "selfLink", "uid"} {
+			assert.NotContains(t, document, field+":", "Expected %v to be omitted", field)
+		}
+	}
+
+	rb := rbacv1.RoleBinding{}
+	err = yaml.Unmarshal([]byte(documents[2]), &rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "rbac-config-ci-bot-edit", rb.Name)
+	assert.Equal(t, "edit", rb.RoleRef.Name)
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// bindingSpec is the canonical form of a binding used to compute its hash
+type bindingSpec struct {
+	RoleRef  rbacv1.RoleRef   `json:"roleRef"`
+	Subjects []rbacv1.Subject `json:"subjects"`
+}
+
+// serviceAccountSpec is the canonical form of a Service Account used to
+// compute its hash
+type serviceAccountSpec struct {
+	Secrets []string `json:"secrets"`
+}
+
+// tokenSecretSpec is the canonical form of a token Secret used to compute its
+// hash
+type tokenSecretSpec struct {
+	Type           v1.SecretType `json:"type"`
+	ServiceAccount string        `json:"serviceAccount"`
+}
+
+// SpecHash returns a hash of the parts of a generated resource that RBAC
+// Manager manages. Subjects and secrets are sorted first so that equivalent
+// resources always have the same hash. An empty string is returned for
+// unsupported types.
+func SpecHash(obj runtime.Object) string {
+	var spec interface{}
+
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		spec = bindingSpec{RoleRef: o.RoleRef, Subjects: sortSubjects(o.Subjects)}
+	case *rbacv1.RoleBinding:
+		spec = bindingSpec{RoleRef: o.RoleRef, Subjects: sortSubjects(o.Subjects)}
+	case *v1.ServiceAccount:
+		secrets := []string{}
+		for _, secret := range o.Secrets {
+			secrets = append(secrets, secret.Name)
+		}
+		sort.Strings(secrets)
+		spec = serviceAccountSpec{Secrets: secrets}
+	case *v1.Secret:
+		spec = tokenSecretSpec{Type: o.Type, ServiceAccount: o.Annotations[v1.ServiceAccountNameKey]}
+	default:
+		return ""
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// stampSpecHashes adds the SpecHashAnnotation to each parsed resource.
+// Annotations are copied first since parsed resources can share a map.
+func (p *Parser) stampSpecHashes() {
+	for i := range p.parsedServiceAccounts {
+		sa := &p.parsedServiceAccounts[i]
+		sa.Annotations = withAnnotation(sa.Annotations, SpecHashAnnotation, SpecHash(sa))
+	}
+
+	for i := range p.parsedSecrets {
+		secret := &p.parsedSecrets[i]
+		secret.Annotations = withAnnotation(secret.Annotations, SpecHashAnnotation, SpecHash(secret))
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		crb := &p.parsedClusterRoleBindings[i]
+		crb.Annotations = withAnnotation(crb.Annotations, SpecHashAnnotation, SpecHash(crb))
+	}
+
+	for i := range p.parsedRoleBindings {
+		rb := &p.parsedRoleBindings[i]
+		rb.Annotations = withAnnotation(rb.Annotations, SpecHashAnnotation, SpecHash(rb))
+	}
+}
+
+// withAnnotation returns a copy of annotations with key set to value
+func withAnnotation(annotations map[string]string, key string, value string) map[string]string {
+	copied := map[string]string{key: value}
+	for k, v := range annotations {
+		if k != key {
+			copied[k] = v
+		}
+	}
+	return copied
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSpecHash(t *testing.T) {
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+	ci := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}
+	view := rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}
+
+	crb := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "first"},
+		RoleRef:    view,
+		Subjects:   []rbacv1.Subject{joe, ci},
+	}
+
+	// names, metadata, and subject order don't affect the hash
+	identical := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "second", Labels: map[string]string{"team": "devs"}},
+		RoleRef:    view,
+		Subjects:   []rbacv1.Subject{ci, joe},
+	}
+	assert.NotEmpty(t, SpecHash(crb))
+	assert.Equal(t, SpecHash(crb), SpecHash(identical))
+
+	rb := &rbacv1.RoleBinding{RoleRef: view, Subjects: []rbacv1.Subject{joe, ci}}
+	assert.Equal(t, SpecHash(crb), SpecHash(rb))
+
+	differing := []*rbacv1.ClusterRoleBinding{{
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+		Subjects: []rbacv1.Subject{joe, ci},
+	}, {
+		RoleRef:  view,
+		Subjects: []rbacv1.Subject{joe},
+	}, {
+		RoleRef:  view,
+		Subjects: []rbacv1.Subject{joe, {Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "other"}},
+	}}
+
+	for _, other := range differing {
+		assert.NotEqual(t, SpecHash(crb), SpecHash(other), "Expected hash to differ for %v", other)
+	}
+
+	sa := &corev1.ServiceAccount{Secrets: []corev1.ObjectReference{{Name: "a"}, {Name: "b"}}}
+	assert.Equal(t, SpecHash(sa), SpecHash(&corev1.ServiceAccount{Secrets: []corev1.ObjectReference{{Name: "b"}, {Name: "a"}}}))
+	assert.NotEqual(t, SpecHash(sa), SpecHash(&corev1.ServiceAccount{Secrets: []corev1.ObjectReference{{Name: "a"}}}))
+
+	assert.Empty(t, SpecHash(&corev1.Namespace{}))
+}
+
+func TestParseSpecHashAnnotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:      "devs",
+		ExpiresAt: "2026-12-31T17:00:00-05:00",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "view",
+		}, {
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, CreateTokenSecret: true}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	crb := p.parsedClusterRoleBindings[0]
+	assert.Equal(t, SpecHash(&crb), crb.Annotations[SpecHashAnnotation])
+
+	sa := p.parsedServiceAccounts[0]
+	assert.Equal(t, SpecHash(&sa), sa.Annotations[SpecHashAnnotation])
+
+	secret := p.parsedSecrets[0]
+	assert.NotEmpty(t, secret.Annotations[SpecHashAnnotation])
+	assert.Equal(t, SpecHash(&secret), secret.Annotations[SpecHashAnnotation])
+
+	// bindings sharing binding annotations each get their own hash
+	view, edit := p.parsedRoleBindings[0], p.parsedRoleBindings[1]
+	assert.Equal(t, SpecHash(&view), view.Annotations[SpecHashAnnotation])
+	assert.Equal(t, SpecHash(&edit), edit.Annotations[SpecHashAnnotation])
+	assert.NotEqual(t, view.Annotations[SpecHashAnnotation], edit.Annotations[SpecHashAnnotation])
+	assert.Equal(t, "2026-12-31T22:00:00Z", edit.Annotations[ExpiresAtAnnotation])
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParseStatus is the result of the last parse of an RBAC Definition
+type ParseStatus struct {
+	Err  error
+	Time time.Time
+}
+
+// HealthRegistry records the result of the last parse of each RBAC Definition,
+// it is safe for concurrent use
+type HealthRegistry struct {
+	mutex    sync.RWMutex
+	statuses map[string]ParseStatus
+}
+
+// Health is the registry parse results are reported to by the controllers
+var Health = NewHealthRegistry()
+
+// NewHealthRegistry returns an empty HealthRegistry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{statuses: map[string]ParseStatus{}}
+}
+
+// Record stores the result of parsing an RBAC Definition
+func (h *HealthRegistry) Record(name string, err error) {
+	h.RecordAt(name, err, time.Now())
+}
+
+// RecordAt stores the result of parsing an RBAC Definition at a given time
+func (h *HealthRegistry) RecordAt(name string, err error, at time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.statuses[name] = ParseStatus{Err: err, Time: at}
+}
+
+// Remove forgets an RBAC Definition, used once it has been deleted
+func (h *HealthRegistry) Remove(name string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.statuses, name)
+}
+
+// Status returns the result of the last parse of an RBAC Definition
+func (h *HealthRegistry) Status(name string) (ParseStatus, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	status, ok := h.statuses[name]
+	return status, ok
+}
+
+// Healthy returns true if the last parse of every RBAC Definition succeeded
+func (h *HealthRegistry) Healthy() bool {
+	return len(h.failing()) < 1
+}
+
+// ServeHTTP responds with a 200 status when healthy, otherwise a 503 status
+// listing the RBAC Definitions that failed to parse
+func (h *HealthRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	failing := h.failing()
+	if len(failing) < 1 {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, name := range failing {
+		status, _ := h.Status(name)
+		fmt.Fprintf(w, "%v: %v\n", name, status.Err)
+	}
+}
+
+// failing returns the sorted names of RBAC Definitions that failed to parse
+func (h *HealthRegistry) failing() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	failing := []string{}
+	for name, status := range h.statuses {
+		if status.Err != nil {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+
+	return failing
+}
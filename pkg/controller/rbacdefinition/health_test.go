@@ -0,0 +1,87 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	registry := NewHealthRegistry()
+	assert.True(t, registry.Healthy(), "Expected an empty registry to be healthy")
+
+	valid := rbacmanagerv1beta1.RBACDefinition{}
+	valid.Name = "valid"
+	valid.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	invalid := rbacmanagerv1beta1.RBACDefinition{}
+	invalid.Name = "invalid"
+	invalid.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset(), HealthRegistry: registry}
+	assert.NoError(t, p.Parse(valid))
+	assert.True(t, registry.Healthy())
+
+	p = Parser{Clientset: fake.NewSimpleClientset(), HealthRegistry: registry}
+	assert.Error(t, p.Parse(invalid))
+	assert.False(t, registry.Healthy())
+
+	status, ok := registry.Status("invalid")
+	assert.True(t, ok)
+	assert.Error(t, status.Err)
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "invalid: No subjects specified")
+
+	// a later successful parse restores health
+	invalid.RBACBindings = valid.RBACBindings
+	p = Parser{Clientset: fake.NewSimpleClientset(), HealthRegistry: registry}
+	assert.NoError(t, p.Parse(invalid))
+	assert.True(t, registry.Healthy())
+
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// deleted definitions are forgotten
+	assert.Error(t, p.Parse(rbacmanagerv1beta1.RBACDefinition{
+		ObjectMeta:   valid.ObjectMeta,
+		RBACBindings: []rbacmanagerv1beta1.RBACBinding{{Name: "devs"}},
+	}))
+	assert.False(t, registry.Healthy())
+	registry.Remove("valid")
+	assert.True(t, registry.Healthy())
+}
@@ -15,6 +15,8 @@
 package rbacdefinition
 
 import (
+	"strings"
+
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -50,16 +52,31 @@ func rbMatches(existingRB *rbacv1.RoleBinding, requestedRB *rbacv1.RoleBinding)
 	return true
 }
 
-func saMatches(existingSA *v1.ServiceAccount, requestedSA *v1.ServiceAccount) bool {
-	if !metaMatches(&existingSA.ObjectMeta, &requestedSA.ObjectMeta) {
+// saIdentityMatches returns true if an existing Service Account is the
+// requested one, regardless of its labels and annotations. Those are updated
+// in place since recreating a Service Account invalidates its tokens.
+func saIdentityMatches(existingSA *v1.ServiceAccount, requestedSA *v1.ServiceAccount) bool {
+	return metaIdentityMatches(&existingSA.ObjectMeta, &requestedSA.ObjectMeta)
+}
+
+func metaMatches(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMeta) bool {
+	if !metaIdentityMatches(existingMeta, requestedMeta) {
 		return false
 	}
 
-	return true
+	return managedMetaMatches(existingMeta, requestedMeta)
 }
 
-func metaMatches(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMeta) bool {
-	if existingMeta.Name != requestedMeta.Name {
+// metaIdentityMatches returns true if existing metadata belongs to the
+// requested resource by name, namespace, and owner
+func metaIdentityMatches(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMeta) bool {
+	// Requested resources with a generated name match on the prefix, since
+	// the name is only known once the resource has been created
+	if requestedMeta.Name == "" && requestedMeta.GenerateName != "" {
+		if existingMeta.GenerateName != requestedMeta.GenerateName {
+			return false
+		}
+	} else if existingMeta.Name != requestedMeta.Name {
 		return false
 	}
 
@@ -74,6 +91,86 @@ func metaMatches(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMe
 	return true
 }
 
+// managedMetaMatches returns true if existing metadata has the labels,
+// managed annotations, and finalizers of the requested metadata. Managed
+// annotations include the SpecHashAnnotation, so resources with a stored
+// hash that differs from the requested one are always resynced.
+func managedMetaMatches(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMeta) bool {
+	if !stringMapsMatch(existingMeta.Labels, requestedMeta.Labels) {
+		return false
+	}
+
+	if !stringMapsMatch(managedAnnotations(existingMeta.Annotations), managedAnnotations(requestedMeta.Annotations)) {
+		return false
+	}
+
+	// Finalizers added by other controllers are left alone, only missing
+	// requested finalizers require an update
+	for _, finalizer := range requestedMeta.Finalizers {
+		if !containsString(existingMeta.Finalizers, finalizer) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// managedAnnotations returns the annotations set by RBAC Manager. Annotations
+// added by other tools are left alone, as is the FreezeAnnotation since it's
+// only ever set by users.
+func managedAnnotations(annotations map[string]string) map[string]string {
+	managed := map[string]string{}
+	for key, value := range annotations {
+		if strings.HasPrefix(key, ManagedAnnotationPrefix) && key != FreezeAnnotation {
+			managed[key] = value
+		}
+	}
+	return managed
+}
+
+// withManagedMeta returns a copy of existing metadata with the labels,
+// managed annotations, and finalizers of the requested metadata
+func withManagedMeta(existingMeta *metav1.ObjectMeta, requestedMeta *metav1.ObjectMeta) metav1.ObjectMeta {
+	updated := *existingMeta.DeepCopy()
+
+	updated.Labels = map[string]string{}
+	for key, value := range requestedMeta.Labels {
+		updated.Labels[key] = value
+	}
+
+	updated.Annotations = map[string]string{}
+	for key, value := range existingMeta.Annotations {
+		if _, managed := managedAnnotations(existingMeta.Annotations)[key]; !managed {
+			updated.Annotations[key] = value
+		}
+	}
+	for key, value := range managedAnnotations(requestedMeta.Annotations) {
+		updated.Annotations[key] = value
+	}
+
+	for _, finalizer := range requestedMeta.Finalizers {
+		if !containsString(updated.Finalizers, finalizer) {
+			updated.Finalizers = append(updated.Finalizers, finalizer)
+		}
+	}
+
+	return updated
+}
+
+func stringMapsMatch(existing map[string]string, requested map[string]string) bool {
+	if len(existing) != len(requested) {
+		return false
+	}
+
+	for key, value := range requested {
+		if existingValue, ok := existing[key]; !ok || existingValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 func ownerRefsMatch(existingOwnerRefs *[]metav1.OwnerReference, requestedOwnerRefs *[]metav1.OwnerReference) bool {
 	requested := *requestedOwnerRefs
 	existing := *existingOwnerRefs
@@ -91,6 +188,24 @@ func ownerRefsMatch(existingOwnerRefs *[]metav1.OwnerReference, requestedOwnerRe
 	return true
 }
 
+// ownerRefsStale returns true if existing owner references refer to the same
+// owners as the requested ones by kind and name, but to a previous instance of
+// one of them, such as an RBAC Definition that was deleted and recreated
+func ownerRefsStale(existingOwnerRefs *[]metav1.OwnerReference, requestedOwnerRefs *[]metav1.OwnerReference) bool {
+	if !ownerRefsMatch(existingOwnerRefs, requestedOwnerRefs) {
+		return false
+	}
+
+	requested := *requestedOwnerRefs
+	for index, existingOwnerRef := range *existingOwnerRefs {
+		if existingOwnerRef.UID != requested[index].UID {
+			return true
+		}
+	}
+
+	return false
+}
+
 func ownerRefMatches(existingOwnerRef *metav1.OwnerReference, requestedOwnerRef *metav1.OwnerReference) bool {
 	if existingOwnerRef.Kind != requestedOwnerRef.Kind {
 		return false
@@ -137,6 +252,12 @@ func subjectMatches(existingSubject *rbacv1.Subject, requestedSubject *rbacv1.Su
 		return false
 	}
 
+	// The API server defaults an empty API group to the RBAC API group for
+	// User and Group subjects
+	if requestedSubject.APIGroup != "" && existingSubject.APIGroup != requestedSubject.APIGroup {
+		return false
+	}
+
 	return true
 }
 
@@ -149,5 +270,10 @@ func roleRefMatches(existingRoleRef *rbacv1.RoleRef, requestedRoleRef *rbacv1.Ro
 		return false
 	}
 
+	// The API server defaults an empty API group to the RBAC API group
+	if requestedRoleRef.APIGroup != "" && existingRoleRef.APIGroup != requestedRoleRef.APIGroup {
+		return false
+	}
+
 	return true
 }
@@ -105,6 +105,18 @@ func TestCrbMatches(t *testing.T) {
 	if !crbMatches(&crb3, &crb3) {
 		t.Fatal("CRB 3 should match CRB 3")
 	}
+
+	crb4 := *crb1.DeepCopy()
+	crb4.Finalizers = []string{"example.com/audit"}
+
+	if crbMatches(&crb1, &crb4) {
+		t.Fatal("CRB 1 should not match CRB 4 without its finalizer")
+	}
+
+	crb1.Finalizers = []string{"example.com/audit", "example.com/other"}
+	if !crbMatches(&crb1, &crb4) {
+		t.Fatal("CRB 1 should match CRB 4 with additional finalizers")
+	}
 }
 
 func TestRbMatches(t *testing.T) {
@@ -180,4 +192,121 @@ func TestRbMatches(t *testing.T) {
 	if !rbMatches(&rb3, &rb3) {
 		t.Fatal("RB 3 should match RB 3")
 	}
+
+	// requested bindings with a generated name match on the prefix
+	requested := rb1
+	requested.Name = ""
+	requested.GenerateName = "hello-world-"
+
+	existing := requested
+	existing.Name = "hello-world-x7k2p"
+
+	if !rbMatches(&existing, &requested) {
+		t.Fatal("Generated RB should match requested RB")
+	}
+
+	if rbMatches(&rb1, &requested) {
+		t.Fatal("RB 1 should not match requested RB with a generated name")
+	}
+}
+
+func TestOwnerRefsStale(t *testing.T) {
+	current := []metav1.OwnerReference{{APIVersion: "v1", Kind: "RBACDefinition", Name: "rbac-config", UID: "current"}}
+	previous := []metav1.OwnerReference{{APIVersion: "v1", Kind: "RBACDefinition", Name: "rbac-config", UID: "previous"}}
+	other := []metav1.OwnerReference{{APIVersion: "v1", Kind: "RBACDefinition", Name: "other", UID: "previous"}}
+
+	if !ownerRefsStale(&previous, &current) {
+		t.Fatal("Owner refs to a previous instance should be stale")
+	}
+
+	if ownerRefsStale(&current, &current) {
+		t.Fatal("Owner refs to the current instance should not be stale")
+	}
+
+	if ownerRefsStale(&other, &current) {
+		t.Fatal("Owner refs to another owner should not be stale")
+	}
+
+	if ownerRefsStale(&[]metav1.OwnerReference{}, &current) {
+		t.Fatal("Missing owner refs should not be stale")
+	}
+}
+
+func TestRoleRefMatches(t *testing.T) {
+	defaulted := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"}
+	standard := rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}
+	custom := rbacv1.RoleRef{APIGroup: "authz.example.com", Kind: "ClusterRole", Name: "view"}
+
+	if !roleRefMatches(&defaulted, &standard) {
+		t.Fatal("Defaulted API group should match an empty requested API group")
+	}
+
+	if roleRefMatches(&defaulted, &custom) {
+		t.Fatal("Defaulted API group should not match a custom API group")
+	}
+
+	if !roleRefMatches(&custom, &custom) {
+		t.Fatal("Custom API group should match itself")
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	defaulted := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "joe"}
+	standard := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+	custom := rbacv1.Subject{APIGroup: "users.example.com", Kind: rbacv1.UserKind, Name: "joe"}
+
+	if !subjectMatches(&defaulted, &standard) {
+		t.Fatal("Defaulted API group should match an empty requested API group")
+	}
+
+	if subjectMatches(&defaulted, &custom) {
+		t.Fatal("Defaulted API group should not match a custom API group")
+	}
+
+	if !subjectMatches(&custom, &custom) {
+		t.Fatal("Custom API group should match itself")
+	}
+}
+
+func TestMetaMatchesManagedMeta(t *testing.T) {
+	requested := metav1.ObjectMeta{
+		Name:   "rbac-config-devs-view",
+		Labels: map[string]string{LabelKey: LabelValue, "team": "web"},
+		Annotations: map[string]string{
+			ApplyOrderAnnotation:     "2",
+			SourceRevisionAnnotation: "3f2c1e9",
+		},
+	}
+
+	existing := *requested.DeepCopy()
+	existing.Annotations["kubectl.kubernetes.io/last-applied-configuration"] = "{}"
+	existing.Annotations[FreezeAnnotation] = "false"
+	if !metaMatches(&existing, &requested) {
+		t.Fatal("Annotations not managed by RBAC Manager should be ignored")
+	}
+
+	changed := []func(meta *metav1.ObjectMeta){
+		func(meta *metav1.ObjectMeta) { meta.Labels["team"] = "api" },
+		func(meta *metav1.ObjectMeta) { meta.Labels["tier"] = "gold" },
+		func(meta *metav1.ObjectMeta) { delete(meta.Labels, "team") },
+		func(meta *metav1.ObjectMeta) { meta.Annotations[SourceRevisionAnnotation] = "8a7b6c5" },
+		func(meta *metav1.ObjectMeta) { meta.Annotations[subjectDisplayAnnotation("joe")] = "Joe" },
+		func(meta *metav1.ObjectMeta) { delete(meta.Annotations, ApplyOrderAnnotation) },
+	}
+
+	for i, change := range changed {
+		stale := *existing.DeepCopy()
+		change(&stale)
+		if metaMatches(&stale, &requested) {
+			t.Fatalf("Expected change %v to labels or managed annotations not to match", i)
+		}
+
+		updated := withManagedMeta(&stale, &requested)
+		if !metaMatches(&updated, &requested) {
+			t.Fatalf("Expected change %v to match once updated", i)
+		}
+		if updated.Annotations["kubectl.kubernetes.io/last-applied-configuration"] != "{}" || updated.Annotations[FreezeAnnotation] != "false" {
+			t.Fatalf("Expected updating change %v to keep annotations not managed by RBAC Manager", i)
+		}
+	}
 }
@@ -0,0 +1,151 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"strings"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ParseResult holds the resources generated for one or more RBAC Definitions
+type ParseResult struct {
+	ServiceAccounts     []v1.ServiceAccount
+	Secrets             []v1.Secret
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+	RoleBindings        []rbacv1.RoleBinding
+}
+
+// NameCollision describes a binding name generated by more than one RBAC
+// Definition
+type NameCollision struct {
+	ResourceType string
+	Namespace    string
+	Name         string
+	Definitions  []string
+}
+
+// NameCollisionError lists every binding name generated by more than one
+// RBAC Definition
+type NameCollisionError struct {
+	Collisions []NameCollision
+}
+
+func (e *NameCollisionError) Error() string {
+	collisions := make([]string, len(e.Collisions))
+	for i, c := range e.Collisions {
+		collisions[i] = fmt.Sprintf("%v %v/%v generated by %v", c.ResourceType, c.Namespace, c.Name, strings.Join(c.Definitions, " and "))
+	}
+
+	return fmt.Sprintf("Found %v name collisions: %v", len(e.Collisions), strings.Join(collisions, ", "))
+}
+
+// IsNameCollision returns true if an error was caused by RBAC Definitions
+// generating bindings with the same name
+func IsNameCollision(err error) bool {
+	_, ok := err.(*NameCollisionError)
+	return ok
+}
+
+// ParseAll parses each RBAC Definition and merges the generated resources
+// into a single result. Service Accounts and their token Secrets may be
+// shared between definitions, but bindings with the same name are reported in a NameCollisionError
+// alongside the merged result, which keeps the first definition's binding.
+func (p *Parser) ParseAll(rbacDefs []rbacmanagerv1beta1.RBACDefinition) (*ParseResult, error) {
+	result := &ParseResult{}
+	owners := map[string]string{}
+	collisions := []NameCollision{}
+	collisionIndex := map[string]int{}
+
+	// claim records the RBAC Definition that first generated a resource,
+	// returning false if another definition already has. Bindings using
+	// GenerateName have no fixed name and can't collide.
+	claim := func(resourceType string, namespace string, name string, rbacDefName string) bool {
+		if name == "" {
+			return true
+		}
+
+		key := fmt.Sprintf("%v/%v/%v", resourceType, namespace, name)
+		owner, ok := owners[key]
+		if !ok {
+			owners[key] = rbacDefName
+			return true
+		}
+
+		if resourceType == ResourceTypeServiceAccount || resourceType == ResourceTypeSecret {
+			return false
+		}
+
+		index, ok := collisionIndex[key]
+		if !ok {
+			collisions = append(collisions, NameCollision{
+				ResourceType: resourceType,
+				Namespace:    namespace,
+				Name:         name,
+				Definitions:  []string{owner},
+			})
+			index = len(collisions) - 1
+			collisionIndex[key] = index
+		}
+		collisions[index].Definitions = appendUnique(collisions[index].Definitions, rbacDefName)
+		return false
+	}
+
+	for _, rbacDef := range rbacDefs {
+		defParser := *p
+		defParser.parsedServiceAccounts = nil
+		defParser.parsedSecrets = nil
+		defParser.parsedClusterRoleBindings = nil
+		defParser.parsedRoleBindings = nil
+
+		err := defParser.Parse(rbacDef)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing RBAC Definition %v: %v", rbacDef.Name, err)
+		}
+
+		for _, sa := range defParser.parsedServiceAccounts {
+			if claim(ResourceTypeServiceAccount, sa.Namespace, sa.Name, rbacDef.Name) {
+				result.ServiceAccounts = append(result.ServiceAccounts, sa)
+			}
+		}
+
+		for _, secret := range defParser.parsedSecrets {
+			if claim(ResourceTypeSecret, secret.Namespace, secret.Name, rbacDef.Name) {
+				result.Secrets = append(result.Secrets, secret)
+			}
+		}
+
+		for _, crb := range defParser.parsedClusterRoleBindings {
+			if claim(ResourceTypeClusterRoleBinding, "", crb.Name, rbacDef.Name) {
+				result.ClusterRoleBindings = append(result.ClusterRoleBindings, crb)
+			}
+		}
+
+		for _, rb := range defParser.parsedRoleBindings {
+			if claim(ResourceTypeRoleBinding, rb.Namespace, rb.Name, rbacDef.Name) {
+				result.RoleBindings = append(result.RoleBindings, rb)
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		return result, &NameCollisionError{Collisions: collisions}
+	}
+
+	return result, nil
+}
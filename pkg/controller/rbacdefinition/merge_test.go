@@ -0,0 +1,94 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseAll(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ci := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}
+
+	devs := rbacmanagerv1beta1.RBACDefinition{}
+	devs.Name = "devs"
+	devs.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "web-ci",
+		Subjects: []rbacv1.Subject{ci},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	ops := rbacmanagerv1beta1.RBACDefinition{}
+	ops.Name = "ops"
+	ops.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{ci, {
+			Kind: rbacv1.UserKind,
+			Name: "jane",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "admin",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	result, err := p.ParseAll([]rbacmanagerv1beta1.RBACDefinition{devs, ops})
+	assert.NoError(t, err)
+
+	// the shared Service Account is only included once
+	assert.Len(t, result.ServiceAccounts, 1)
+	assert.Len(t, result.ClusterRoleBindings, 1)
+	assert.Len(t, result.RoleBindings, 2)
+
+	// "devs" with "web-ci" and "devs-web" with "ci" both generate the
+	// devs-web-ci-view Cluster Role Binding
+	ops.Name = "devs-web"
+	ops.RBACBindings[0].ClusterRoleBindings = []rbacmanagerv1beta1.ClusterRoleBinding{{
+		ClusterRole: "view",
+	}}
+
+	result, err = p.ParseAll([]rbacmanagerv1beta1.RBACDefinition{devs, ops})
+	assert.True(t, IsNameCollision(err), "Expected name collision error, got %v", err)
+	assert.EqualValues(t, []NameCollision{{
+		ResourceType: ResourceTypeClusterRoleBinding,
+		Name:         "devs-web-ci-view",
+		Definitions:  []string{"devs", "devs-web"},
+	}}, err.(*NameCollisionError).Collisions)
+
+	// the first definition's binding is kept
+	assert.Len(t, result.ClusterRoleBindings, 1)
+	assert.Equal(t, "ci", result.ClusterRoleBindings[0].Subjects[0].Name)
+	assert.Len(t, result.ClusterRoleBindings[0].Subjects, 1)
+	assert.Len(t, result.RoleBindings, 2)
+
+	// parse errors are returned without a result
+	ops.RBACBindings[0].Subjects = nil
+	result, err = p.ParseAll([]rbacmanagerv1beta1.RBACDefinition{devs, ops})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResolverURL identifies subjects fetched from subjectsFromURL
+const ResolverURL = "url"
+
+// ResolverConfigMap identifies subjects read from subjectsFromConfigMap
+const ResolverConfigMap = "configmap"
+
+// ResolverGroupMembership identifies Group subjects expanded into their members
+const ResolverGroupMembership = "group-membership"
+
+// LatencyBuckets are the upper bounds in seconds of the resolution latency
+// histogram buckets
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// LatencyHistogram counts observed latencies into buckets. Counts are
+// cumulative, each includes every latency up to the matching bucket bound.
+type LatencyHistogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+// resolverStats holds the metrics recorded for one type of subject resolver
+type resolverStats struct {
+	successes uint64
+	failures  uint64
+	latency   LatencyHistogram
+}
+
+// ResolverMetrics counts the successes and failures of external subject
+// resolvers and records their latency, it is safe for concurrent use
+type ResolverMetrics struct {
+	mutex     sync.RWMutex
+	resolvers map[string]*resolverStats
+}
+
+// Metrics is the registry subject resolutions are reported to by the controllers
+var Metrics = NewResolverMetrics()
+
+// NewResolverMetrics returns an empty ResolverMetrics
+func NewResolverMetrics() *ResolverMetrics {
+	return &ResolverMetrics{resolvers: map[string]*resolverStats{}}
+}
+
+// Observe records a subject resolution by a type of resolver
+func (m *ResolverMetrics) Observe(resolver string, latency time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stats, ok := m.resolvers[resolver]
+	if !ok {
+		stats = &resolverStats{latency: LatencyHistogram{
+			Buckets: LatencyBuckets,
+			Counts:  make([]uint64, len(LatencyBuckets)),
+		}}
+		m.resolvers[resolver] = stats
+	}
+
+	if err != nil {
+		stats.failures++
+	} else {
+		stats.successes++
+	}
+
+	seconds := latency.Seconds()
+	for i, bound := range stats.latency.Buckets {
+		if seconds <= bound {
+			stats.latency.Counts[i]++
+		}
+	}
+	stats.latency.Count++
+	stats.latency.Sum += seconds
+}
+
+// Successes returns the number of successful resolutions by a type of resolver
+func (m *ResolverMetrics) Successes(resolver string) uint64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if stats, ok := m.resolvers[resolver]; ok {
+		return stats.successes
+	}
+	return 0
+}
+
+// Failures returns the number of failed resolutions by a type of resolver
+func (m *ResolverMetrics) Failures(resolver string) uint64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if stats, ok := m.resolvers[resolver]; ok {
+		return stats.failures
+	}
+	return 0
+}
+
+// Latency returns a copy of the latency histogram of a type of resolver
+func (m *ResolverMetrics) Latency(resolver string) LatencyHistogram {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats, ok := m.resolvers[resolver]
+	if !ok {
+		return LatencyHistogram{Buckets: LatencyBuckets, Counts: make([]uint64, len(LatencyBuckets))}
+	}
+
+	latency := stats.latency
+	latency.Counts = append([]uint64{}, stats.latency.Counts...)
+	return latency
+}
+
+// ServeHTTP responds with the metrics in the Prometheus text format
+func (m *ResolverMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	resolvers := []string{}
+	for resolver := range m.resolvers {
+		resolvers = append(resolvers, resolver)
+	}
+	sort.Strings(resolvers)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP rbac_manager_subject_resolutions_total Subject resolutions by resolver type and result.")
+	fmt.Fprintln(w, "# TYPE rbac_manager_subject_resolutions_total counter")
+	for _, resolver := range resolvers {
+		stats := m.resolvers[resolver]
+		fmt.Fprintf(w, "rbac_manager_subject_resolutions_total{resolver=%q,result=\"success\"} %v\n", resolver, stats.successes)
+		fmt.Fprintf(w, "rbac_manager_subject_resolutions_total{resolver=%q,result=\"failure\"} %v\n", resolver, stats.failures)
+	}
+
+	fmt.Fprintln(w, "# HELP rbac_manager_subject_resolution_duration_seconds Subject resolution latency by resolver type.")
+	fmt.Fprintln(w, "# TYPE rbac_manager_subject_resolution_duration_seconds histogram")
+	for _, resolver := range resolvers {
+		latency := m.resolvers[resolver].latency
+		for i, bound := range latency.Buckets {
+			fmt.Fprintf(w, "rbac_manager_subject_resolution_duration_seconds_bucket{resolver=%q,le=%q} %v\n",
+				resolver, strconv.FormatFloat(bound, 'g', -1, 64), latency.Counts[i])
+		}
+		fmt.Fprintf(w, "rbac_manager_subject_resolution_duration_seconds_bucket{resolver=%q,le=\"+Inf\"} %v\n", resolver, latency.Count)
+		fmt.Fprintf(w, "rbac_manager_subject_resolution_duration_seconds_sum{resolver=%q} %v\n", resolver, latency.Sum)
+		fmt.Fprintf(w, "rbac_manager_subject_resolution_duration_seconds_count{resolver=%q} %v\n", resolver, latency.Count)
+	}
+}
+
+// observeResolver records a subject resolution started at start with the
+// ResolverMetrics
+func (p *Parser) observeResolver(resolver string, start time.Time, err error) {
+	if p.ResolverMetrics == nil {
+		return
+	}
+	p.ResolverMetrics.Observe(resolver, p.now().Sub(start), err)
+}
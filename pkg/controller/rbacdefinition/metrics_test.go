@@ -0,0 +1,110 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// steppingClock moves forward by step each time it is read
+type steppingClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func TestResolverMetrics(t *testing.T) {
+	metrics := NewResolverMetrics()
+	metrics.Observe(ResolverURL, 3*time.Millisecond, nil)
+	metrics.Observe(ResolverURL, 300*time.Millisecond, errors.New("timeout"))
+	metrics.Observe(ResolverURL, 20*time.Second, nil)
+
+	assert.Equal(t, uint64(2), metrics.Successes(ResolverURL))
+	assert.Equal(t, uint64(1), metrics.Failures(ResolverURL))
+	assert.Equal(t, uint64(0), metrics.Successes(ResolverConfigMap))
+
+	latency := metrics.Latency(ResolverURL)
+	assert.Equal(t, []uint64{1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2}, latency.Counts)
+	assert.Equal(t, uint64(3), latency.Count)
+	assert.InDelta(t, 20.303, latency.Sum, 0.0001)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, `rbac_manager_subject_resolutions_total{resolver="url",result="success"} 2`)
+	assert.Contains(t, body, `rbac_manager_subject_resolutions_total{resolver="url",result="failure"} 1`)
+	assert.Contains(t, body, `rbac_manager_subject_resolution_duration_seconds_bucket{resolver="url",le="0.005"} 1`)
+	assert.Contains(t, body, `rbac_manager_subject_resolution_duration_seconds_bucket{resolver="url",le="+Inf"} 3`)
+	assert.Contains(t, body, `rbac_manager_subject_resolution_duration_seconds_count{resolver="url"} 3`)
+}
+
+func TestParseResolverMetrics(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "roster", Namespace: "rbac-manager"},
+		Data: map[string]string{
+			"subjects.csv": "kind,name,namespace\nGroup,platform\n",
+		},
+	})
+
+	rbacDef := subjectsFromURLDefinition("")
+	rbacDef.RBACBindings[0].SubjectsFromConfigMap = &rbacmanagerv1beta1.ConfigMapSubjects{
+		Namespace: "rbac-manager",
+		Name:      "roster",
+		Key:       "subjects.csv",
+	}
+
+	metrics := NewResolverMetrics()
+	p := Parser{
+		Clientset:             client,
+		GroupMembershipLister: fakeGroupMembershipLister{"platform": {"sue"}},
+		ResolverMetrics:       metrics,
+		Clock:                 &steppingClock{step: 20 * time.Millisecond},
+	}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, uint64(1), metrics.Successes(ResolverConfigMap))
+	assert.Equal(t, uint64(0), metrics.Failures(ResolverConfigMap))
+	assert.Equal(t, uint64(1), metrics.Successes(ResolverGroupMembership))
+	assert.InDelta(t, 0.02, metrics.Latency(ResolverConfigMap).Sum, 0.0001)
+
+	// resolvers that fail are counted as failures
+	rbacDef.RBACBindings[0].SubjectsFromConfigMap.Name = "missing"
+	err = p.Parse(rbacDef)
+	assert.Error(t, err)
+
+	assert.Equal(t, uint64(1), metrics.Successes(ResolverConfigMap))
+	assert.Equal(t, uint64(1), metrics.Failures(ResolverConfigMap))
+	assert.Equal(t, uint64(2), metrics.Latency(ResolverConfigMap).Count)
+
+	// subjects that aren't resolved externally aren't counted
+	assert.Equal(t, uint64(0), metrics.Successes(ResolverURL)+metrics.Failures(ResolverURL))
+}
@@ -0,0 +1,325 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	logrus "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ProjectResource is the OpenShift Project resource that wraps each namespace
+var ProjectResource = schema.GroupVersionResource{
+	Group:    "project.openshift.io",
+	Version:  "v1",
+	Resource: "projects",
+}
+
+// NamespaceLister lists the namespaces that namespace selectors are evaluated against
+type NamespaceLister interface {
+	ListNamespaces(listOptions metav1.ListOptions) ([]v1.Namespace, error)
+}
+
+// TeamNamespaceLister lists the namespaces that belong to a team, such as
+// from TeamNamespace custom resources mapping namespaces to teams
+type TeamNamespaceLister interface {
+	ListTeamNamespaces(team string) ([]string, error)
+}
+
+// NodeNamespaceResolver resolves the namespaces running workloads on nodes
+// matching a node selector, such as the nodes of a node pool
+type NodeNamespaceResolver interface {
+	ResolveNodeNamespaces(nodeSelector map[string]string) ([]string, error)
+}
+
+// ResourcePresenceResolver checks whether a namespace has a resource matching
+// a requirement, such as a Deployment labeled app=db
+type ResourcePresenceResolver interface {
+	ResourcePresent(namespace string, requirement rbacmanagerv1beta1.ResourceRequirement) (bool, error)
+}
+
+// ClientsetNamespaceLister lists core Kubernetes namespaces
+type ClientsetNamespaceLister struct {
+	Clientset kubernetes.Interface
+}
+
+// ListNamespaces lists namespaces matching listOptions
+func (l *ClientsetNamespaceLister) ListNamespaces(listOptions metav1.ListOptions) ([]v1.Namespace, error) {
+	namespaces, err := l.Clientset.CoreV1().Namespaces().List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+	return namespaces.Items, nil
+}
+
+// LabelTeamNamespaceLister lists the namespaces of a team by a namespace
+// label, such as the namespaces labeled team=web for the web team
+type LabelTeamNamespaceLister struct {
+	NamespaceLister NamespaceLister
+	Label           string
+}
+
+// ListTeamNamespaces lists the namespaces with Label set to team
+func (l *LabelTeamNamespaceLister) ListTeamNamespaces(team string) ([]string, error) {
+	namespaces, err := l.NamespaceLister.ListNamespaces(metav1.ListOptions{LabelSelector: l.Label + "=" + team})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, namespace := range namespaces {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
+
+// ClientsetNodeNamespaceResolver resolves the namespaces of the pods
+// scheduled on nodes matching a node selector
+type ClientsetNodeNamespaceResolver struct {
+	Clientset kubernetes.Interface
+}
+
+// ResolveNodeNamespaces lists the namespaces with pods on nodes matching
+// nodeSelector
+func (r *ClientsetNodeNamespaceResolver) ResolveNodeNamespaces(nodeSelector map[string]string) ([]string, error) {
+	nodes, err := r.Clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: labels.Set(nodeSelector).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, node := range nodes.Items {
+		pods, err := r.Clientset.CoreV1().Pods("").List(metav1.ListOptions{FieldSelector: "spec.nodeName=" + node.Name})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != node.Name || seen[pod.Namespace] {
+				continue
+			}
+			seen[pod.Namespace] = true
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+
+	return namespaces, nil
+}
+
+// DynamicResourcePresenceResolver checks for resources of any kind with a
+// dynamic client, mapping kinds to resources with Mapper
+type DynamicResourcePresenceResolver struct {
+	Client dynamic.Interface
+	Mapper meta.RESTMapper
+}
+
+// ResourcePresent returns true if namespace has at least one resource
+// matching requirement
+func (r *DynamicResourcePresenceResolver) ResourcePresent(namespace string, requirement rbacmanagerv1beta1.ResourceRequirement) (bool, error) {
+	mapping, err := r.Mapper.RESTMapping(schema.GroupKind{Group: requirement.APIGroup, Kind: requirement.Kind})
+	if err != nil {
+		return false, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&requirement.LabelSelector)
+	if err != nil {
+		return false, err
+	}
+
+	resources, err := r.Client.Resource(mapping.Resource).Namespace(namespace).List(metav1.ListOptions{LabelSelector: selector.String(), Limit: 1})
+	if err != nil {
+		return false, err
+	}
+
+	return len(resources.Items) > 0, nil
+}
+
+// ProjectNamespaceLister lists namespaces through OpenShift Projects, so that
+// namespace selectors are evaluated against Project labels
+type ProjectNamespaceLister struct {
+	Client dynamic.Interface
+}
+
+// ListNamespaces lists the namespaces of Projects matching listOptions
+func (l *ProjectNamespaceLister) ListNamespaces(listOptions metav1.ListOptions) ([]v1.Namespace, error) {
+	projects, err := l.Client.Resource(ProjectResource).List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []v1.Namespace{}
+	for _, project := range projects.Items {
+		namespaces = append(namespaces, v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            project.GetName(),
+				Labels:          project.GetLabels(),
+				Annotations:     project.GetAnnotations(),
+				OwnerReferences: project.GetOwnerReferences(),
+			},
+		})
+	}
+
+	return namespaces, nil
+}
+
+// NewProjectNamespaceLister returns a NamespaceLister for OpenShift Projects
+func NewProjectNamespaceLister(config *rest.Config) (NamespaceLister, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectNamespaceLister{Client: client}, nil
+}
+
+// listNamespaces lists namespaces with the configured NamespaceLister,
+// falling back to core Kubernetes namespaces
+func (p *Parser) listNamespaces(listOptions metav1.ListOptions) ([]v1.Namespace, error) {
+	if p.NamespaceLister != nil {
+		return p.NamespaceLister.ListNamespaces(listOptions)
+	}
+
+	lister := ClientsetNamespaceLister{Clientset: p.Clientset}
+	return lister.ListNamespaces(listOptions)
+}
+
+// skipTerminatingNamespace returns true and logs a warning if
+// SkipTerminatingNamespaces is set and a namespace is being deleted.
+// Namespaces that don't exist are not skipped.
+func (p *Parser) skipTerminatingNamespace(namespace string) (bool, error) {
+	if !p.SkipTerminatingNamespaces {
+		return false, nil
+	}
+
+	ns, err := p.Clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if ns.Status.Phase != v1.NamespaceTerminating && ns.DeletionTimestamp == nil {
+		return false, nil
+	}
+
+	logrus.Warnf("Skipping namespace %v, it is being deleted", namespace)
+	return true, nil
+}
+
+// isNamespaceGlob returns true if an explicit namespace is a glob pattern to
+// expand, such as team-*
+func (p *Parser) isNamespaceGlob(namespace string) bool {
+	return p.ExpandNamespaceGlobs && strings.Contains(namespace, "*")
+}
+
+// globNamespaces returns the names of namespaces matching a glob pattern
+func (p *Parser) globNamespaces(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid namespace pattern %v: %v", pattern, err)
+	}
+
+	namespaces, err := p.listNamespaces(metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil, &NamespaceListForbiddenError{Err: err}
+		}
+		return nil, err
+	}
+
+	matched := []string{}
+	for _, namespace := range namespaces {
+		if ok, _ := path.Match(pattern, namespace.Name); ok {
+			matched = append(matched, namespace.Name)
+		}
+	}
+
+	return matched, nil
+}
+
+// normalizeNamespace trims surrounding whitespace from a namespace name and
+// lowercases it, returning an error if the result isn't a valid DNS label
+func normalizeNamespace(namespace string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(namespace))
+	if len(validation.IsDNS1123Label(normalized)) > 0 {
+		return "", fmt.Errorf("Invalid namespace %v, namespaces must be valid DNS labels", namespace)
+	}
+
+	return normalized, nil
+}
+
+// normalizeRoleBindingNamespaces normalizes the explicitly listed namespaces
+// and subject override keys of a requested Role Binding, namespace globs are
+// only trimmed and lowercased since they are matched against existing
+// namespaces
+func (p *Parser) normalizeRoleBindingNamespaces(rb *rbacmanagerv1beta1.RoleBinding) error {
+	if p.isNamespaceGlob(rb.Namespace) {
+		rb.Namespace = strings.ToLower(strings.TrimSpace(rb.Namespace))
+	} else if rb.Namespace != "" {
+		namespace, err := normalizeNamespace(rb.Namespace)
+		if err != nil {
+			return err
+		}
+		rb.Namespace = namespace
+	}
+
+	if len(rb.Namespaces) > 0 {
+		namespaces := make([]string, len(rb.Namespaces))
+		for i, namespace := range rb.Namespaces {
+			normalized, err := normalizeNamespace(namespace)
+			if err != nil {
+				return err
+			}
+			namespaces[i] = normalized
+		}
+		rb.Namespaces = namespaces
+	}
+
+	if len(rb.SubjectOverrides) > 0 {
+		keys := make([]string, 0, len(rb.SubjectOverrides))
+		for namespace := range rb.SubjectOverrides {
+			keys = append(keys, namespace)
+		}
+		sort.Strings(keys)
+
+		// keys that normalize to the same namespace are merged in key order
+		overrides := map[string][]rbacv1.Subject{}
+		for _, namespace := range keys {
+			normalized, err := normalizeNamespace(namespace)
+			if err != nil {
+				return err
+			}
+			overrides[normalized] = append(overrides[normalized], rb.SubjectOverrides[namespace]...)
+		}
+		rb.SubjectOverrides = overrides
+	}
+
+	return nil
+}
@@ -0,0 +1,428 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeProjectClient is a dynamic client that only supports listing Projects
+type fakeProjectClient struct {
+	dynamic.NamespaceableResourceInterface
+	t        *testing.T
+	projects []unstructured.Unstructured
+}
+
+func (c *fakeProjectClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	assert.Equal(c.t, ProjectResource, resource)
+	return c
+}
+
+func (c *fakeProjectClient) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for _, project := range c.projects {
+		if selector.Matches(labels.Set(project.GetLabels())) {
+			list.Items = append(list.Items, project)
+		}
+	}
+	return list, nil
+}
+
+// fakeResourceClient is a dynamic client that only supports listing
+// namespaced resources
+type fakeResourceClient struct {
+	dynamic.NamespaceableResourceInterface
+	resource  schema.GroupVersionResource
+	namespace string
+	resources map[schema.GroupVersionResource][]unstructured.Unstructured
+}
+
+func (c fakeResourceClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	c.resource = resource
+	return &c
+}
+
+func (c *fakeResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	scoped := *c
+	scoped.namespace = namespace
+	return &scoped
+}
+
+func (c *fakeResourceClient) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for _, resource := range c.resources[c.resource] {
+		if resource.GetNamespace() == c.namespace && selector.Matches(labels.Set(resource.GetLabels())) {
+			list.Items = append(list.Items, resource)
+		}
+	}
+	return list, nil
+}
+
+func newProject(name string, projectLabels map[string]string) unstructured.Unstructured {
+	project := unstructured.Unstructured{}
+	project.SetAPIVersion("project.openshift.io/v1")
+	project.SetKind("Project")
+	project.SetName(name)
+	project.SetLabels(projectLabels)
+	return project
+}
+
+// fakeTeamNamespaceLister maps teams to their namespaces
+type fakeTeamNamespaceLister map[string][]string
+
+func (l fakeTeamNamespaceLister) ListTeamNamespaces(team string) ([]string, error) {
+	return l[team], nil
+}
+
+func TestParseTeamNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "web",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "web-devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Team:        "web",
+			Namespaces:  []string{"web-frontend"},
+		}},
+	}}
+
+	lister := fakeTeamNamespaceLister{
+		"web": {"web-frontend", "web-backend"},
+		"api": {"api"},
+	}
+
+	p := Parser{Clientset: client, TeamNamespaceLister: lister}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// namespaces both listed and belonging to the team only get one binding
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		assert.Equal(t, "rbac-config-web-edit", rb.Name)
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.Equal(t, []string{"web-frontend", "web-backend"}, namespaces)
+
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid role binding, team web requires team namespaces to be configured with --team-namespace-label")
+}
+
+// fakeNodeNamespaceResolver maps node pool labels to the namespaces running
+// workloads on those nodes
+type fakeNodeNamespaceResolver map[string][]string
+
+func (r fakeNodeNamespaceResolver) ResolveNodeNamespaces(nodeSelector map[string]string) ([]string, error) {
+	if nodeSelector["pool"] == "broken" {
+		return nil, errors.New("nodes is forbidden")
+	}
+	return r[nodeSelector["pool"]], nil
+}
+
+func TestParseNodeNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "ml",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "ml-engineers"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:  "view",
+			NodeSelector: map[string]string{"pool": "gpu"},
+			Namespaces:   []string{"training"},
+		}},
+	}}
+
+	resolver := fakeNodeNamespaceResolver{
+		"gpu":     {"training", "inference"},
+		"general": {"web"},
+	}
+
+	p := Parser{Clientset: client, NodeNamespaceResolver: resolver}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// namespaces both listed and resolved from nodes only get one binding
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		assert.Equal(t, "rbac-config-ml-view", rb.Name)
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.Equal(t, []string{"training", "inference"}, namespaces)
+
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid role binding, node selector pool=gpu requires node namespaces to be enabled with --resolve-node-namespaces")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].NodeSelector = map[string]string{"pool": "broken"}
+	p = Parser{Clientset: client, NodeNamespaceResolver: resolver}
+	assert.EqualError(t, p.Parse(rbacDef), "Error resolving namespaces for node selector pool=broken: nodes is forbidden")
+}
+
+// fakeResourcePresenceResolver lists the namespaces running each kind of
+// resource
+type fakeResourcePresenceResolver map[string][]string
+
+func (r fakeResourcePresenceResolver) ResourcePresent(namespace string, requirement rbacmanagerv1beta1.ResourceRequirement) (bool, error) {
+	if requirement.Kind == "Broken" {
+		return false, errors.New("the server could not find the requested resource")
+	}
+
+	for _, present := range r[requirement.Kind] {
+		if present == namespace {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestParseRequireResource(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "dev"})
+	createNamespace(t, client, "api", map[string]string{"team": "dev"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "dbas",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "dbas"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:       "edit",
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "dev"}},
+			RequireResource: &rbacmanagerv1beta1.ResourceRequirement{
+				APIGroup:      "apps",
+				Kind:          "Deployment",
+				LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			},
+		}},
+	}}
+
+	resolver := fakeResourcePresenceResolver{"Deployment": {"api"}}
+
+	p := Parser{Clientset: client, ResourcePresenceResolver: resolver}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// only namespaces running the resource get a binding
+	if assert.Len(t, p.parsedRoleBindings, 1) {
+		assert.Equal(t, "api", p.parsedRoleBindings[0].Namespace)
+	}
+
+	p = Parser{Clientset: client}
+	assert.Error(t, p.Parse(rbacDef), "Expected error without a resource presence resolver")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].RequireResource.Kind = "Broken"
+	p = Parser{Clientset: client, ResourcePresenceResolver: resolver}
+	assert.Error(t, p.Parse(rbacDef), "Expected error when resolving resource presence fails")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].RequireResource.Kind = ""
+	p = Parser{Clientset: client, ResourcePresenceResolver: resolver}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid role binding, requireResource requires a kind")
+}
+
+func TestParseSkipTerminatingNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "old"},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+	)
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "old",
+		}, {
+			ClusterRole: "view",
+			Namespaces:  []string{"web", "old", "new"},
+		}},
+	}}
+
+	parsedNamespaces := func(p Parser) []string {
+		namespaces := []string{}
+		for _, rb := range p.parsedRoleBindings {
+			namespaces = append(namespaces, rb.RoleRef.Name+"/"+rb.Namespace)
+		}
+		return namespaces
+	}
+
+	p := Parser{Clientset: client, SkipTerminatingNamespaces: true}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// namespaces that don't exist yet aren't skipped
+	assert.Equal(t, []string{"view/web", "view/new"}, parsedNamespaces(p))
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []string{"edit/old", "view/web", "view/old", "view/new"}, parsedNamespaces(p))
+}
+
+func TestParseNamespaceNormalization(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deployer",
+			Namespace: " CI\n",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "Web ",
+			Namespaces:  []string{"  api", "DB"},
+			SubjectOverrides: map[string][]rbacv1.Subject{
+				" API": {{Kind: rbacv1.UserKind, Name: "sue"}},
+				"Api":  {{Kind: rbacv1.UserKind, Name: "kay"}},
+			},
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		namespaces = append(namespaces, rb.Namespace)
+		assert.Equal(t, "ci", rb.Subjects[0].Namespace)
+	}
+	assert.Equal(t, []string{"web", "api", "db"}, namespaces)
+
+	// subject override keys are normalized, merging keys for the same namespace
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "deployer",
+		Namespace: "ci",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "kay",
+	}}, p.parsedRoleBindings[1].Subjects)
+	assert.Len(t, p.parsedRoleBindings[0].Subjects, 1)
+
+	if assert.Len(t, p.parsedServiceAccounts, 1) {
+		assert.Equal(t, "ci", p.parsedServiceAccounts[0].Namespace)
+	}
+
+	// names that aren't valid DNS labels after normalizing are rejected
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespace = "web_frontend"
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid namespace web_frontend, namespaces must be valid DNS labels")
+	assert.EqualError(t, p.ValidateStatic(&rbacDef), "Invalid namespace web_frontend, namespaces must be valid DNS labels")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespace = "web"
+	rbacDef.RBACBindings[0].RoleBindings[0].SubjectOverrides = map[string][]rbacv1.Subject{
+		"web_frontend": {{Kind: rbacv1.UserKind, Name: "sue"}},
+	}
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.ValidateStatic(&rbacDef), "Invalid namespace web_frontend, namespaces must be valid DNS labels")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].SubjectOverrides = nil
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespaces = []string{"api.v2"}
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid namespace api.v2, namespaces must be valid DNS labels")
+
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespaces = nil
+	rbacDef.RBACBindings[0].Subjects[0].Namespace = "c i"
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid namespace c i, namespaces must be valid DNS labels")
+}
+
+func TestParseOpenShiftProjects(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	// core namespaces are ignored when listing Projects
+	createNamespace(t, client, "db", map[string]string{"team": "devs"})
+
+	projects := &fakeProjectClient{t: t, projects: []unstructured.Unstructured{
+		newProject("web", map[string]string{"team": "devs"}),
+		newProject("api", map[string]string{"team": "devs"}),
+		newProject("ops", map[string]string{"team": "ops"}),
+	}}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, NamespaceLister: &ProjectNamespaceLister{Client: projects}}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		namespaces = append(namespaces, rb.Namespace)
+	}
+
+	assert.ElementsMatch(t, []string{"web", "api"}, namespaces)
+}
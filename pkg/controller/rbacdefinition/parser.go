@@ -1,177 +1,2258 @@
 package rbacdefinition
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
 	logrus "github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
+// maxNameLength is the longest name allowed for generated resources
+const maxNameLength = 253
+
+// minNameLength is the shortest MaxNameLengths limit allowed, leaving room
+// for at least one character of a name before the hash suffix of truncateName
+const minNameLength = 10
+
+// maxMatrixNamePrefixLength leaves room within the 253 character name limit
+// for the role name appended to matrix binding name prefixes
+const maxMatrixNamePrefixLength = 180
+
 // Parser parses RBAC Definitions and determines the Kubernetes resources that it specifies
 type Parser struct {
-	Clientset                 kubernetes.Interface
+	Clientset kubernetes.Interface
+
+	// GroupNameMap translates external group names (such as those emitted
+	// by an IdP) to the Kubernetes group names used in bindings
+	GroupNameMap map[string]string
+
+	// RoleAliases translates friendly names used for Cluster Roles in RBAC
+	// Definitions, such as admin or readonly, to Cluster Role names
+	RoleAliases map[string]string
+
+	// ValidateRoleAliases rejects Cluster Role names that are neither one of
+	// the RoleAliases nor an existing Cluster Role
+	ValidateRoleAliases bool
+
+	// MaxSubjectsPerBinding limits the number of subjects a generated binding
+	// may have, bindings exceeding it would be rejected by the API server.
+	// A zero value disables the limit.
+	MaxSubjectsPerBinding int
+
+	// InheritDefinitionLabels lists labels that are copied from an RBAC
+	// Definition to each resource generated for it
+	InheritDefinitionLabels []string
+
+	// EnabledResourceTypes restricts the types of resources that are
+	// generated, all types are generated when it is empty
+	EnabledResourceTypes map[string]bool
+
+	// NamespaceLister lists the namespaces that namespace selectors are
+	// evaluated against, core Kubernetes namespaces are used when it is nil
+	NamespaceLister NamespaceLister
+
+	// PropagateNamespaceLabels lists labels that are copied from the
+	// namespace of a Service Account subject to the generated Service Account
+	PropagateNamespaceLabels []string
+
+	// GroupMembershipLister expands Group subjects into their User members,
+	// Group subjects are left unchanged when it is nil
+	GroupMembershipLister GroupMembershipLister
+
+	// TeamNamespaceLister resolves the namespaces of Role Bindings that
+	// target a team, Role Bindings with a team are rejected when it is nil
+	TeamNamespaceLister TeamNamespaceLister
+
+	// NodeNamespaceResolver resolves the namespaces of Role Bindings with a
+	// node selector, Role Bindings with a node selector are rejected when it
+	// is nil
+	NodeNamespaceResolver NodeNamespaceResolver
+
+	// ResourcePresenceResolver checks whether a namespace has a resource
+	// required by a Role Binding, Role Bindings with requireResource are
+	// rejected when it is nil
+	ResourcePresenceResolver ResourcePresenceResolver
+
+	// ResolverMetrics records the results and latency of fetching subjects
+	// from URLs and ConfigMaps and of expanding groups, nothing is recorded
+	// when it is nil
+	ResolverMetrics *ResolverMetrics
+
+	// HTTPClient is used to fetch subjects from external endpoints, a
+	// client with a default timeout is used when it is nil
+	HTTPClient *http.Client
+
+	// AllowSystemGroups allows subjects to reference powerful built in groups
+	// like system:authenticated, these are rejected by default
+	AllowSystemGroups bool
+
+	// AllowSystemUsers allows User subjects with reserved names like
+	// system:kube-scheduler, these are rejected by default
+	AllowSystemUsers bool
+
+	// ProtectedNamespaces lists namespaces that managed resources are never
+	// deleted from, even when they are no longer requested
+	ProtectedNamespaces map[string]bool
+
+	// WarnOnEmpty logs a warning when an RBAC Definition has no RBAC
+	// Bindings, empty definitions are still valid either way
+	WarnOnEmpty bool
+
+	// FailOnEmptyBinding rejects RBAC Definitions with a requested Role
+	// Binding that isn't generated in any namespace, such as a namespace
+	// selector that matches nothing, instead of silently generating nothing
+	FailOnEmptyBinding bool
+
+	// UseGenerateName generates bindings with a GenerateName prefix instead
+	// of a fixed Name, leaving the API server to pick a unique name
+	UseGenerateName bool
+
+	// CompactServiceAccountNames names Role Bindings with a single Service
+	// Account subject in the Service Account's own namespace after the
+	// Service Account and role instead of the RBAC Definition and RBAC
+	// Binding, see compactRoleBindingName
+	CompactServiceAccountNames bool
+
+	// MaxNameLengths lowers the longest name allowed for generated resources
+	// of each type, keyed by resource type such as ResourceTypeRoleBinding,
+	// for admission controllers with stricter limits. Longer generated names
+	// are truncated the same way as names over the API server limit, while
+	// explicit Service Account names over the limit are rejected.
+	MaxNameLengths map[string]int
+
+	// NameSeparator joins the RBAC Definition, RBAC Binding, role, and
+	// namespace names that generated names are built from, DefaultNameSeparator
+	// is used when it is empty
+	NameSeparator string
+
+	// QualifyClusterRoleBindingNames suffixes the names of generated Cluster
+	// Role Bindings with a hash of the RBAC Definition UID, so that names
+	// built from different RBAC Definitions can never collide
+	QualifyClusterRoleBindingNames bool
+
+	// SortSubjects sorts the subjects of generated bindings, so that
+	// reordering subjects in an RBAC Definition has no effect on them.
+	// Subjects otherwise keep the order of the RBAC Definition, for tools
+	// that depend on it.
+	SortSubjects bool
+
+	// CollapseNamespaceBindings merges Role Bindings in the same namespace
+	// that refer to the same role into a single Role Binding with the
+	// subjects of each, see collapseNamespaceBindings
+	CollapseNamespaceBindings bool
+
+	// CreateTokenSecret generates a long-lived token Secret for each
+	// generated Service Account, for clusters where these are no longer
+	// created automatically (Kubernetes 1.24 and later)
+	CreateTokenSecret bool
+
+	// RequireServiceAccountNamespaces only generates Service Accounts in
+	// namespaces that exist, the namespaces that are missing are listed by
+	// MissingServiceAccountNamespaces so the RBAC Definition can be retried
+	RequireServiceAccountNamespaces bool
+
+	// SkipTerminatingNamespaces skips explicitly named namespaces that are
+	// being deleted instead of generating Role Bindings in them, namespace
+	// selectors can filter these with a namespaceFieldSelector instead
+	SkipTerminatingNamespaces bool
+
+	// SubjectNamePatterns enforces naming conventions by subject kind, such
+	// as requiring User names to match firstname.lastname. Subjects of kinds
+	// without a pattern are not checked.
+	SubjectNamePatterns map[string]*regexp.Regexp
+
+	// ExpandNamespaceGlobs treats an explicit namespace containing a *, such
+	// as team-*, as a glob pattern and generates a Role Binding in each
+	// matching namespace
+	ExpandNamespaceGlobs bool
+
+	// DefaultUserAPIGroup and DefaultGroupAPIGroup are set as the API group
+	// of User and Group subjects that don't specify one, for authenticators
+	// that register subjects outside of the RBAC API group. Subjects are
+	// left without an API group when these are empty, which the API server
+	// defaults to the RBAC API group.
+	DefaultUserAPIGroup  string
+	DefaultGroupAPIGroup string
+
+	// RoleLabelRules add labels to generated bindings based on the name of
+	// the role they reference, such as labeling bindings to roles
+	// containing admin for security tooling. Rules are applied in order, so
+	// later rules override the labels of earlier ones.
+	RoleLabelRules []RoleLabelRule
+
+	// DisallowedSubjectKinds rejects subjects of these kinds, such as
+	// clusters that only allow Groups and Service Accounts to be bound
+	DisallowedSubjectKinds map[string]bool
+
+	// SourceRevision identifies the revision, such as a git commit, of the
+	// RBAC Definition source. It is added to each generated resource as the
+	// SourceRevisionAnnotation when set.
+	SourceRevision string
+
+	// BindingFinalizers are added to each generated Cluster Role Binding and
+	// Role Binding, for workflows where another controller must act before
+	// a binding is removed. Deleting these bindings, including when they
+	// are cleaned up by RBAC Manager, then only completes once that
+	// controller has removed its finalizer.
+	BindingFinalizers []string
+
+	// ResolveAggregatedClusterRoles logs the Cluster Roles aggregated by
+	// each Cluster Role bound by a Cluster Role Binding at debug level
+	ResolveAggregatedClusterRoles bool
+
+	// Requester is the user an RBAC Definition is created on behalf of in
+	// self-service setups. When set, Role Bindings are only generated in
+	// namespaces where a SubjectAccessReview shows the requester can create
+	// Role Bindings themselves.
+	Requester *Requester
+
+	// HealthRegistry records the result of each call to Parse, results are
+	// not recorded when it is nil
+	HealthRegistry *HealthRegistry
+
+	// BackoffRegistry counts consecutive failures of calls to Parse to
+	// determine retry delays, failures are not counted when it is nil
+	BackoffRegistry *BackoffRegistry
+
+	// SchemaMigrations convert RBAC Definitions from older schema versions,
+	// keyed by version, to CurrentSchemaVersion before they are parsed.
+	// RBAC Definitions with any other version are rejected.
+	SchemaMigrations map[string]SchemaMigration
+
+	// Values are referenced by {{ .Values.x }} placeholders in subject names
+	// and namespaces, role binding namespaces, and role names, which are
+	// resolved before an RBAC Definition is parsed
+	Values map[string]interface{}
+
+	// Clock is used wherever the parser stamps the current time, it
+	// defaults to RealClock
+	Clock Clock
+
+	annotations               map[string]string
+	catchAllRoleBindings      []catchAllRoleBinding
+	checkedRoles              []RoleKey
+	coveredNamespaces         map[string]bool
+	compactQualifier          string
+	crbQualifier              string
+	requesterNamespaces       map[string]bool
+	labels                    map[string]string
+	missingNamespaces         []string
 	ownerRefs                 []metav1.OwnerReference
 	parsedClusterRoleBindings []rbacv1.ClusterRoleBinding
 	parsedRoleBindings        []rbacv1.RoleBinding
 	parsedServiceAccounts     []v1.ServiceAccount
+	parsedSecrets             []v1.Secret
+	rbacBindingName           string
+	selectorMatches           []rbacmanagerv1beta1.SelectorMatch
+	truncatedNames            []TruncatedName
+}
+
+// TruncatedName records a generated name that exceeded the name length limit
+// and the truncated name that was used instead
+type TruncatedName struct {
+	Original  string
+	Truncated string
+}
+
+// Requester identifies a user for SubjectAccessReviews
+type Requester struct {
+	User   string
+	Groups []string
+}
+
+// RoleLabelRule adds Labels to generated bindings referencing a role with a
+// name matching Pattern
+type RoleLabelRule struct {
+	Pattern *regexp.Regexp
+	Labels  map[string]string
+}
+
+// ParseRoleLabelRules parses RoleLabelRules separated by semicolons, each a
+// pattern followed by a colon and comma separated key=value labels, such as
+// admin:security=privileged,audit=true. Label keys and values can't contain
+// a colon, so patterns can.
+func ParseRoleLabelRules(rules string) ([]RoleLabelRule, error) {
+	parsed := []RoleLabelRule{}
+	for _, rule := range strings.Split(rules, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(rule, ":")
+		if separator < 0 {
+			return nil, fmt.Errorf("Invalid role label rule %v, must be pattern:key=value", rule)
+		}
+
+		pattern, err := regexp.Compile(strings.TrimSpace(rule[:separator]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid role label rule %v: %v", rule, err)
+		}
+
+		labels := map[string]string{}
+		for _, label := range strings.Split(rule[separator+1:], ",") {
+			parts := strings.SplitN(label, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, fmt.Errorf("Invalid role label rule %v, labels must be key=value", rule)
+			}
+			labels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+
+		parsed = append(parsed, RoleLabelRule{Pattern: pattern, Labels: labels})
+	}
+
+	return parsed, nil
+}
+
+// ParseSubjectKinds parses comma separated subject kinds, such as
+// User,ServiceAccount, for DisallowedSubjectKinds. Kinds are matched case
+// insensitively like the kinds of subjects.
+func ParseSubjectKinds(kinds string) (map[string]bool, error) {
+	parsed := map[string]bool{}
+	for _, kind := range strings.Split(kinds, ",") {
+		if strings.TrimSpace(kind) == "" {
+			continue
+		}
+
+		canonical, ok := canonicalSubjectKind(kind)
+		if !ok {
+			return nil, fmt.Errorf("Invalid subject kind %v, must be User, Group, or ServiceAccount", strings.TrimSpace(kind))
+		}
+		parsed[canonical] = true
+	}
+
+	return parsed, nil
+}
+
+// ParseSubjectNamePatterns parses SubjectNamePatterns separated by
+// semicolons, each a subject kind followed by = and a pattern, such as
+// User=^[a-z]+\.[a-z]+$. Patterns can contain = and commas, but not
+// semicolons.
+func ParseSubjectNamePatterns(patterns string) (map[string]*regexp.Regexp, error) {
+	parsed := map[string]*regexp.Regexp{}
+	for _, pattern := range strings.Split(patterns, ";") {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pattern, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid subject name pattern %v, must be kind=pattern", pattern)
+		}
+
+		kind, ok := canonicalSubjectKind(parts[0])
+		if !ok {
+			return nil, fmt.Errorf("Invalid subject name pattern %v, kind must be User, Group, or ServiceAccount", pattern)
+		}
+
+		re, err := regexp.Compile(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid subject name pattern %v: %v", pattern, err)
+		}
+		parsed[kind] = re
+	}
+
+	return parsed, nil
+}
+
+// ParseRoleAliases parses comma separated alias=clusterRole pairs, such as
+// reader=view,writer=edit, for RoleAliases
+func ParseRoleAliases(aliases string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, alias := range strings.Split(aliases, ",") {
+		if strings.TrimSpace(alias) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(alias, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("Invalid role alias %v, must be alias=clusterRole", alias)
+		}
+		parsed[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return parsed, nil
+}
+
+// ParseResourceTypes parses comma separated resource types, such as
+// RoleBinding,ServiceAccount, for EnabledResourceTypes
+func ParseResourceTypes(types string) (map[string]bool, error) {
+	parsed := map[string]bool{}
+	for _, resourceType := range strings.Split(types, ",") {
+		resourceType = strings.TrimSpace(resourceType)
+		switch resourceType {
+		case "":
+			continue
+		case ResourceTypeClusterRoleBinding, ResourceTypeRoleBinding, ResourceTypeServiceAccount:
+			parsed[resourceType] = true
+		default:
+			return nil, fmt.Errorf("Invalid resource type %v, must be ClusterRoleBinding, RoleBinding, or ServiceAccount", resourceType)
+		}
+	}
+
+	return parsed, nil
+}
+
+// catchAllRoleBinding is a catch-all Role Binding that is deferred until
+// every other Role Binding in an RBAC Definition has been parsed
+type catchAllRoleBinding struct {
+	annotations     map[string]string
+	prefix          string
+	rb              rbacmanagerv1beta1.RoleBinding
+	rbacBindingName string
+	subjects        []rbacv1.Subject
+}
+
+// Parse determines the desired Kubernetes resources an RBAC Definition refers to
+func (p *Parser) Parse(rbacDef rbacmanagerv1beta1.RBACDefinition) error {
+	err := p.parse(rbacDef)
+
+	if p.HealthRegistry != nil {
+		p.HealthRegistry.RecordAt(rbacDef.Name, err, p.now())
+	}
+
+	if p.BackoffRegistry != nil {
+		p.BackoffRegistry.Record(rbacDef.Name, err)
+	}
+
+	return err
+}
+
+// now returns the current time from the Clock
+func (p *Parser) now() time.Time {
+	if p.Clock == nil {
+		return RealClock{}.Now()
+	}
+	return p.Clock.Now()
+}
+
+func (p *Parser) parse(rbacDef rbacmanagerv1beta1.RBACDefinition) error {
+	p.parsedClusterRoleBindings = nil
+	p.parsedRoleBindings = nil
+	p.parsedServiceAccounts = nil
+	p.parsedSecrets = nil
+
+	rbacDef, err := p.migrateSchema(rbacDef)
+	if err != nil {
+		return err
+	}
+
+	rbacDef, err = p.resolveValues(rbacDef)
+	if err != nil {
+		return err
+	}
+
+	if rbacDef.RBACBindings == nil {
+		if p.WarnOnEmpty {
+			logrus.Warn("No RBACBindings defined")
+		}
+		return nil
+	}
+
+	err = p.ValidateStatic(&rbacDef)
+	if err != nil {
+		return err
+	}
+
+	err = p.checkMaxNameLengths()
+	if err != nil {
+		return err
+	}
+
+	p.labels = p.definitionLabels(&rbacDef)
+	p.catchAllRoleBindings = nil
+	p.checkedRoles = nil
+	p.coveredNamespaces = map[string]bool{}
+	p.requesterNamespaces = map[string]bool{}
+	p.selectorMatches = nil
+	p.truncatedNames = nil
+	p.missingNamespaces = nil
+	p.compactQualifier = ""
+	p.crbQualifier = ""
+
+	if p.CompactServiceAccountNames {
+		p.compactQualifier = compactNameQualifier(&rbacDef)
+	}
+
+	if p.QualifyClusterRoleBindingNames {
+		p.crbQualifier = clusterRoleBindingQualifier(&rbacDef)
+	}
+
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		namePrefix := p.rdNamePrefix(&rbacDef, &rbacBinding)
+
+		if rbacBinding.SubjectsFromURL != "" {
+			start := p.now()
+			subjects, err := p.fetchSubjects(rbacBinding.SubjectsFromURL)
+			p.observeResolver(ResolverURL, start, err)
+			if err != nil {
+				return err
+			}
+			rbacBinding.Subjects = append(append([]rbacv1.Subject{}, rbacBinding.Subjects...), subjects...)
+		}
+
+		if rbacBinding.SubjectsFromConfigMap != nil {
+			start := p.now()
+			subjects, err := p.configMapSubjects(rbacBinding.SubjectsFromConfigMap)
+			p.observeResolver(ResolverConfigMap, start, err)
+			if err != nil {
+				return err
+			}
+			rbacBinding.Subjects = append(append([]rbacv1.Subject{}, rbacBinding.Subjects...), subjects...)
+		}
+
+		err := p.parseRBACBinding(rbacBinding, namePrefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, catchAll := range p.catchAllRoleBindings {
+		p.annotations = catchAll.annotations
+		p.rbacBindingName = catchAll.rbacBindingName
+		err := p.parseRoleBinding(catchAll.rb, catchAll.subjects, catchAll.prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.CollapseNamespaceBindings {
+		p.collapseNamespaceBindings()
+	}
+
+	if len(p.RoleLabelRules) > 0 {
+		p.applyRoleLabelRules()
+	}
+
+	p.truncateLongNames()
+
+	if p.UseGenerateName {
+		p.useGenerateNames()
+	}
+
+	if len(p.BindingFinalizers) > 0 {
+		p.addBindingFinalizers()
+	}
+
+	p.clearServerManagedFields()
+	p.stampSpecHashes()
+
+	if p.SourceRevision != "" {
+		p.stampSourceRevision()
+	}
+
+	return nil
+}
+
+// clearServerManagedFields clears metadata that is only set by the API server
+// from each parsed resource, so generated resources never include it
+func (p *Parser) clearServerManagedFields() {
+	clear := func(meta *metav1.ObjectMeta) {
+		meta.CreationTimestamp = metav1.Time{}
+		meta.ResourceVersion = ""
+		meta.SelfLink = ""
+		meta.UID = ""
+	}
+
+	for i := range p.parsedServiceAccounts {
+		clear(&p.parsedServiceAccounts[i].ObjectMeta)
+	}
+
+	for i := range p.parsedSecrets {
+		clear(&p.parsedSecrets[i].ObjectMeta)
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		clear(&p.parsedClusterRoleBindings[i].ObjectMeta)
+	}
+
+	for i := range p.parsedRoleBindings {
+		clear(&p.parsedRoleBindings[i].ObjectMeta)
+	}
+}
+
+// addBindingFinalizers adds the BindingFinalizers to each parsed binding
+func (p *Parser) addBindingFinalizers() {
+	for i := range p.parsedClusterRoleBindings {
+		crb := &p.parsedClusterRoleBindings[i]
+		crb.Finalizers = append([]string{}, p.BindingFinalizers...)
+	}
+
+	for i := range p.parsedRoleBindings {
+		rb := &p.parsedRoleBindings[i]
+		rb.Finalizers = append([]string{}, p.BindingFinalizers...)
+	}
+}
+
+// stampSourceRevision adds the SourceRevisionAnnotation to each parsed resource
+func (p *Parser) stampSourceRevision() {
+	for i := range p.parsedServiceAccounts {
+		sa := &p.parsedServiceAccounts[i]
+		sa.Annotations = withAnnotation(sa.Annotations, SourceRevisionAnnotation, p.SourceRevision)
+	}
+
+	for i := range p.parsedSecrets {
+		secret := &p.parsedSecrets[i]
+		secret.Annotations = withAnnotation(secret.Annotations, SourceRevisionAnnotation, p.SourceRevision)
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		crb := &p.parsedClusterRoleBindings[i]
+		crb.Annotations = withAnnotation(crb.Annotations, SourceRevisionAnnotation, p.SourceRevision)
+	}
+
+	for i := range p.parsedRoleBindings {
+		rb := &p.parsedRoleBindings[i]
+		rb.Annotations = withAnnotation(rb.Annotations, SourceRevisionAnnotation, p.SourceRevision)
+	}
+}
+
+// applyRoleLabelRules adds the labels of each matching RoleLabelRule to the
+// parsed bindings. Labels are copied first since parsed bindings can share a
+// map.
+func (p *Parser) applyRoleLabelRules() {
+	roleLabels := func(labels map[string]string, roleRef rbacv1.RoleRef) map[string]string {
+		var copied map[string]string
+		for _, rule := range p.RoleLabelRules {
+			if !rule.Pattern.MatchString(roleRef.Name) {
+				continue
+			}
+
+			if copied == nil {
+				copied = map[string]string{}
+				for key, value := range labels {
+					copied[key] = value
+				}
+			}
+
+			for key, value := range rule.Labels {
+				copied[key] = value
+			}
+		}
+
+		if copied == nil {
+			return labels
+		}
+		return copied
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		crb := &p.parsedClusterRoleBindings[i]
+		crb.Labels = roleLabels(crb.Labels, crb.RoleRef)
+	}
+
+	for i := range p.parsedRoleBindings {
+		rb := &p.parsedRoleBindings[i]
+		rb.Labels = roleLabels(rb.Labels, rb.RoleRef)
+	}
+}
+
+// collapseNamespaceBindings merges parsed Role Bindings that share a namespace
+// and RoleRef into the first of them, which keeps its name and metadata and
+// gains the subjects of the others
+func (p *Parser) collapseNamespaceBindings() {
+	collapsed := []rbacv1.RoleBinding{}
+	index := map[string]int{}
+
+	for _, rb := range p.parsedRoleBindings {
+		key := fmt.Sprintf("%v/%v/%v/%v", rb.Namespace, rb.RoleRef.APIGroup, rb.RoleRef.Kind, rb.RoleRef.Name)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(collapsed)
+			collapsed = append(collapsed, rb)
+			continue
+		}
+
+		logrus.Debugf("Collapsing Role Binding %v into %v in %v namespace", rb.Name, collapsed[i].Name, rb.Namespace)
+		subjects := append([]rbacv1.Subject{}, collapsed[i].Subjects...)
+		for _, subject := range rb.Subjects {
+			subjects = appendUniqueSubject(subjects, subject)
+		}
+		collapsed[i].Subjects = subjects
+	}
+
+	p.parsedRoleBindings = collapsed
+}
+
+// useGenerateNames moves the name of each parsed binding to GenerateName.
+// Service Accounts keep their names since bindings refer to them by name.
+func (p *Parser) useGenerateNames() {
+	for i := range p.parsedClusterRoleBindings {
+		p.parsedClusterRoleBindings[i].GenerateName = p.parsedClusterRoleBindings[i].Name + "-"
+		p.parsedClusterRoleBindings[i].Name = ""
+	}
+
+	for i := range p.parsedRoleBindings {
+		p.parsedRoleBindings[i].GenerateName = p.parsedRoleBindings[i].Name + "-"
+		p.parsedRoleBindings[i].Name = ""
+	}
+}
+
+// resolveSubjects normalizes requested subjects, maps group names, defaults
+// API groups, checks them against the configured restrictions, and expands
+// groups into their members. The subjects of RBAC Bindings and their subject
+// overrides are both resolved with it.
+func (p *Parser) resolveSubjects(subjects []rbacv1.Subject) ([]rbacv1.Subject, error) {
+	subjects, err := normalizeSubjects(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	subjects = p.defaultSubjectAPIGroups(p.mapGroupNames(subjects))
+
+	if !p.AllowSystemGroups {
+		err = checkSystemGroups(subjects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.AllowSystemUsers {
+		err = checkSystemUsers(subjects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = p.checkSubjectKinds(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.checkSubjectNames(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.GroupMembershipLister != nil {
+		start := p.now()
+		subjects, err = p.expandGroups(subjects)
+		p.observeResolver(ResolverGroupMembership, start, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return subjects, nil
+}
+
+func (p *Parser) parseRBACBinding(rbacBinding rbacmanagerv1beta1.RBACBinding, namePrefix string) error {
+	if len(rbacBinding.Subjects) < 1 {
+		return errors.New("No subjects specified for RBAC Binding: " + namePrefix)
+	}
+
+	annotations, err := bindingAnnotations(&rbacBinding)
+	if err != nil {
+		return err
+	}
+	p.annotations = annotations
+	p.rbacBindingName = rbacBinding.Name
+
+	rbacBinding.Subjects, err = p.resolveSubjects(rbacBinding.Subjects)
+	if err != nil {
+		return err
+	}
+
+	createServiceAccounts := rbacBinding.CreateServiceAccounts == nil || *rbacBinding.CreateServiceAccounts
+
+	if createServiceAccounts {
+		err = p.checkServiceAccountNames(rbacBinding.Subjects)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, requestedSubject := range rbacBinding.Subjects {
+		if requestedSubject.Kind == "ServiceAccount" && !createServiceAccounts {
+			err := p.checkServiceAccountExists(rbacBinding.Name, requestedSubject)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if requestedSubject.Kind == "ServiceAccount" && p.resourceTypeEnabled(ResourceTypeServiceAccount) {
+			if p.RequireServiceAccountNamespaces {
+				exists, err := p.serviceAccountNamespaceExists(requestedSubject.Namespace)
+				if err != nil {
+					return err
+				}
+
+				if !exists {
+					logrus.Infof("Not creating Service Account %v, namespace %v does not exist", requestedSubject.Name, requestedSubject.Namespace)
+					continue
+				}
+			}
+
+			saLabels, err := p.serviceAccountLabels(requestedSubject.Namespace)
+			if err != nil {
+				return err
+			}
+
+			p.parsedServiceAccounts = append(p.parsedServiceAccounts, v1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            requestedSubject.Name,
+					Namespace:       requestedSubject.Namespace,
+					OwnerReferences: p.ownerRefs,
+					Labels:          saLabels,
+					Annotations:     p.annotations,
+				},
+				Secrets: secretReferences(rbacBinding.Secrets),
+			})
+
+			if p.CreateTokenSecret {
+				p.addTokenSecret(requestedSubject, saLabels)
+			}
+		}
+	}
+
+	if rbacBinding.Matrix {
+		for _, subject := range rbacBinding.Subjects {
+			err := p.parseBindings(rbacBinding, []rbacv1.Subject{subject}, p.matrixNamePrefix(namePrefix, subject))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return p.parseBindings(rbacBinding, rbacBinding.Subjects, namePrefix)
+}
+
+// parseBindings generates the Cluster Role Bindings and Role Bindings
+// requested by an RBAC Binding for a set of subjects
+func (p *Parser) parseBindings(rbacBinding rbacmanagerv1beta1.RBACBinding, subjects []rbacv1.Subject, namePrefix string) error {
+	if rbacBinding.ClusterRoleBindings != nil && p.resourceTypeEnabled(ResourceTypeClusterRoleBinding) {
+		for _, requestedCRB := range rbacBinding.ClusterRoleBindings {
+			for _, clusterRole := range clusterRoles(requestedCRB) {
+				crb := requestedCRB
+				crb.ClusterRole = clusterRole
+				err := p.parseClusterRoleBinding(crb, subjects, namePrefix)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if rbacBinding.RoleBindings != nil && p.resourceTypeEnabled(ResourceTypeRoleBinding) {
+		for _, requestedRB := range expandRoleBindings(rbacBinding.RoleBindings) {
+			if requestedRB.CatchAll {
+				p.catchAllRoleBindings = append(p.catchAllRoleBindings, catchAllRoleBinding{
+					annotations:     p.annotations,
+					prefix:          namePrefix,
+					rb:              requestedRB,
+					rbacBindingName: rbacBinding.Name,
+					subjects:        subjects,
+				})
+				continue
+			}
+
+			err := p.parseRoleBinding(requestedRB, subjects, namePrefix)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandRoleBindings replaces each Role Binding entry with perLabelValue set
+// by one entry per label value, each with a namespace selector requiring that
+// label value and the Cluster Role it maps to
+func expandRoleBindings(roleBindings []rbacmanagerv1beta1.RoleBinding) []rbacmanagerv1beta1.RoleBinding {
+	expanded := []rbacmanagerv1beta1.RoleBinding{}
+
+	for _, rb := range roleBindings {
+		if rb.PerLabelValue == nil {
+			expanded = append(expanded, rb)
+			continue
+		}
+
+		values := []string{}
+		for value := range rb.PerLabelValue.ClusterRoles {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			valueRB := rb
+			valueRB.PerLabelValue = nil
+			valueRB.ClusterRole = rb.PerLabelValue.ClusterRoles[value]
+			valueRB.NamespaceSelector = metav1.LabelSelector{MatchLabels: map[string]string{}}
+			for k, v := range rb.NamespaceSelector.MatchLabels {
+				valueRB.NamespaceSelector.MatchLabels[k] = v
+			}
+			valueRB.NamespaceSelector.MatchLabels[rb.PerLabelValue.Label] = value
+
+			expanded = append(expanded, valueRB)
+		}
+	}
+
+	return expanded
+}
+
+// clusterRoles returns every Cluster Role requested by a Cluster Role Binding
+// entry, combining clusterRole and clusterRoles
+func clusterRoles(crb rbacmanagerv1beta1.ClusterRoleBinding) []string {
+	roles := []string{}
+	if crb.ClusterRole != "" {
+		roles = append(roles, crb.ClusterRole)
+	}
+
+	for _, role := range crb.ClusterRoles {
+		if !containsString(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// matrixNamePrefix returns a name prefix unique to a subject for bindings
+// generated by an RBAC Binding with matrix set. Long prefixes are truncated
+// and suffixed with a hash so generated names stay within length limits.
+func (p *Parser) matrixNamePrefix(namePrefix string, subject rbacv1.Subject) string {
+	prefix := p.joinName(namePrefix, strings.ToLower(subject.Kind), subject.Name)
+	if subject.Namespace != "" {
+		prefix = p.joinName(namePrefix, strings.ToLower(subject.Kind), subject.Namespace, subject.Name)
+	}
+
+	return truncateName(prefix, maxMatrixNamePrefixLength)
+}
+
+// clusterRoleBindingQualifier returns a hash of the RBAC Definition UID to
+// suffix Cluster Role Binding names with. Cluster Role Bindings are cluster
+// scoped, so names like rbac-config-devs-view can otherwise be built by more
+// than one RBAC Definition. The name is hashed instead for RBAC Definitions
+// without a UID, such as those not yet created.
+func clusterRoleBindingQualifier(rbacDef *rbacmanagerv1beta1.RBACDefinition) string {
+	qualifier := string(rbacDef.UID)
+	if qualifier == "" {
+		qualifier = rbacDef.Name
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(qualifier))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// clusterRoleBindingName returns the name of a generated Cluster Role
+// Binding, qualified when QualifyClusterRoleBindingNames is set
+func (p *Parser) clusterRoleBindingName(name string) string {
+	if p.crbQualifier == "" {
+		return name
+	}
+	return p.joinName(name, p.crbQualifier)
+}
+
+// truncateName truncates a name longer than maxLength and suffixes it with a
+// hash of the full name, so that truncated names stay unique
+func truncateName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return fmt.Sprintf("%v-%08x", name[:maxLength-9], hash.Sum32())
+}
+
+// maxNameLength returns the longest name allowed for generated resources of
+// a type, the API server limit unless MaxNameLengths lowers it
+func (p *Parser) maxNameLength(resourceType string) int {
+	if limit := p.MaxNameLengths[resourceType]; limit > 0 && limit < maxNameLength {
+		return limit
+	}
+	return maxNameLength
+}
+
+// checkMaxNameLengths returns an error if a MaxNameLengths limit is too short
+// to hold the hash suffix of truncated names
+func (p *Parser) checkMaxNameLengths() error {
+	for resourceType, limit := range p.MaxNameLengths {
+		if limit < minNameLength {
+			return fmt.Errorf("Invalid max name length %v for %v, must be at least %v", limit, resourceType, minNameLength)
+		}
+	}
+
+	return nil
+}
+
+// truncateLongNames truncates the generated names of parsed resources built
+// from long RBAC Definition, RBAC Binding, role, and subject names so that
+// they stay within the name length limit of their type, recording each name
+// that was truncated. Service Accounts keep their explicit names, which are
+// checked by checkServiceAccountNames instead.
+func (p *Parser) truncateLongNames() {
+	truncate := func(meta *metav1.ObjectMeta, resourceType string) {
+		truncated := truncateName(meta.Name, p.maxNameLength(resourceType))
+		if truncated == meta.Name {
+			return
+		}
+
+		logrus.Warnf("Truncated generated name %v to %v", meta.Name, truncated)
+		p.truncatedNames = append(p.truncatedNames, TruncatedName{Original: meta.Name, Truncated: truncated})
+		meta.Name = truncated
+	}
+
+	for i := range p.parsedSecrets {
+		truncate(&p.parsedSecrets[i].ObjectMeta, ResourceTypeSecret)
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		truncate(&p.parsedClusterRoleBindings[i].ObjectMeta, ResourceTypeClusterRoleBinding)
+	}
+
+	for i := range p.parsedRoleBindings {
+		truncate(&p.parsedRoleBindings[i].ObjectMeta, ResourceTypeRoleBinding)
+	}
+}
+
+// TruncatedNames returns the generated names that were truncated by the last
+// call to Parse
+func (p *Parser) TruncatedNames() []TruncatedName {
+	return p.truncatedNames
+}
+
+// MissingServiceAccountNamespaces returns the namespaces that Service
+// Accounts were not generated in by the last call to Parse because they don't
+// exist yet, when RequireServiceAccountNamespaces is set
+func (p *Parser) MissingServiceAccountNamespaces() []string {
+	return p.missingNamespaces
+}
+
+// serviceAccountNamespaceExists returns true if a namespace exists, missing
+// namespaces are recorded for MissingServiceAccountNamespaces
+func (p *Parser) serviceAccountNamespaceExists(namespace string) (bool, error) {
+	for _, missing := range p.missingNamespaces {
+		if missing == namespace {
+			return false, nil
+		}
+	}
+
+	_, err := p.Clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			p.missingNamespaces = append(p.missingNamespaces, namespace)
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *Parser) parseClusterRoleBinding(
+	crb rbacmanagerv1beta1.ClusterRoleBinding, subjects []rbacv1.Subject, prefix string) error {
+	if crb.RoleRefAPIGroup == "" && crb.RoleRefKind == "" {
+		clusterRole, err := p.resolveClusterRole(crb.ClusterRole)
+		if err != nil {
+			return err
+		}
+		crb.ClusterRole = clusterRole
+	}
+
+	subjects = p.orderSubjects(subjects)
+	crbName := p.joinName(prefix, crb.ClusterRole)
+
+	err := p.checkSubjectLimit(crbName, subjects)
+	if err != nil {
+		return err
+	}
+
+	roleRef := rbacv1.RoleRef{
+		Kind: "ClusterRole",
+		Name: crb.ClusterRole,
+	}
+
+	if p.ResolveAggregatedClusterRoles {
+		p.logAggregatedClusterRoles(crb.ClusterRole)
+	}
+
+	if crb.ScopeToSubjectNamespaces {
+		p.parseSubjectNamespaceRoleBindings(crbName, overrideRoleRef(roleRef, crb.RoleRefAPIGroup, crb.RoleRefKind), subjects)
+		return nil
+	}
+
+	p.parsedClusterRoleBindings = append(p.parsedClusterRoleBindings, rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            p.clusterRoleBindingName(crbName),
+			OwnerReferences: p.ownerRefs,
+			Labels:          p.objectLabels(),
+			Annotations:     p.annotations,
+		},
+		RoleRef:  overrideRoleRef(roleRef, crb.RoleRefAPIGroup, crb.RoleRefKind),
+		Subjects: subjects,
+	})
+
+	return nil
+}
+
+// logAggregatedClusterRoles logs the Cluster Roles aggregated by a Cluster
+// Role with an aggregation rule, so operators can see the permissions that
+// a binding to it grants
+func (p *Parser) logAggregatedClusterRoles(clusterRole string) {
+	aggregated, err := p.aggregatedClusterRoles(clusterRole)
+	if err != nil {
+		logrus.Warnf("Unable to resolve Cluster Roles aggregated by %v: %v", clusterRole, err)
+		return
+	}
+
+	if aggregated == nil {
+		return
+	}
+
+	logrus.Debugf("Cluster Role %v aggregates %v", clusterRole, strings.Join(aggregated, ", "))
+}
+
+// aggregatedClusterRoles returns the sorted names of the Cluster Roles
+// matched by the aggregation rule of a Cluster Role. Nil is returned for
+// Cluster Roles that don't exist or don't have an aggregation rule.
+func (p *Parser) aggregatedClusterRoles(clusterRole string) ([]string, error) {
+	role, err := p.Clientset.RbacV1().ClusterRoles().Get(clusterRole, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logrus.Debugf("Cluster Role %v not found, unable to resolve aggregation", clusterRole)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if role.AggregationRule == nil {
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	aggregated := []string{}
+	for _, selector := range role.AggregationRule.ClusterRoleSelectors {
+		labelSelector, err := metav1.LabelSelectorAsSelector(&selector)
+		if err != nil {
+			return nil, err
+		}
+
+		matching, err := p.Clientset.RbacV1().ClusterRoles().List(metav1.ListOptions{LabelSelector: labelSelector.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matching.Items {
+			if match.Name != clusterRole && !seen[match.Name] {
+				seen[match.Name] = true
+				aggregated = append(aggregated, match.Name)
+			}
+		}
+	}
+
+	sort.Strings(aggregated)
+	return aggregated, nil
+}
+
+// parseSubjectNamespaceRoleBindings generates a Role Binding in the namespace
+// of each Service Account subject in place of a Cluster Role Binding
+func (p *Parser) parseSubjectNamespaceRoleBindings(name string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+	namespaces := []string{}
+	namespaceSubjects := map[string][]rbacv1.Subject{}
+
+	for _, subject := range subjects {
+		if subject.Kind != rbacv1.ServiceAccountKind {
+			logrus.Warnf("Skipping %v %v, only Service Accounts can be scoped to a namespace", subject.Kind, subject.Name)
+			continue
+		}
+
+		if _, ok := namespaceSubjects[subject.Namespace]; !ok {
+			namespaces = append(namespaces, subject.Namespace)
+		}
+		namespaceSubjects[subject.Namespace] = append(namespaceSubjects[subject.Namespace], subject)
+	}
+
+	for _, namespace := range namespaces {
+		logrus.Debugf("Adding Role Binding scoped to subject namespace %v", namespace)
+
+		p.parsedRoleBindings = append(p.parsedRoleBindings, rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				OwnerReferences: p.ownerRefs,
+				Labels:          p.objectLabels(),
+				Annotations:     p.annotations,
+			},
+			RoleRef:  roleRef,
+			Subjects: namespaceSubjects[namespace],
+		})
+	}
+}
+
+func (p *Parser) parseRoleBinding(
+	rb rbacmanagerv1beta1.RoleBinding, subjects []rbacv1.Subject, prefix string) error {
+
+	err := validateRoleBinding(rb)
+	if err != nil {
+		return err
+	}
+
+	err = p.normalizeRoleBindingNamespaces(&rb)
+	if err != nil {
+		return err
+	}
+
+	if rb.ClusterRole != "" && rb.RoleRefAPIGroup == "" && rb.RoleRefKind == "" {
+		rb.ClusterRole, err = p.resolveClusterRole(rb.ClusterRole)
+		if err != nil {
+			return err
+		}
+	}
+
+	subjects = p.orderSubjects(subjects)
+
+	objectMeta := metav1.ObjectMeta{
+		OwnerReferences: p.ownerRefs,
+		Labels:          p.objectLabels(),
+		Annotations:     p.annotations,
+	}
+
+	logrus.Debugf("Processing Requested Role Binding %v <> %v <> %v <> %v", rb.ClusterRole, rb.Role, rb.Namespace, rb)
+
+	name, roleRef, err := p.roleBindingTarget(rb, prefix, rb.Namespace)
+	if err != nil {
+		return err
+	}
+
+	objectMeta.Name = name
+
+	err = p.checkSubjectLimit(objectMeta.Name, subjects)
+	if err != nil {
+		return err
+	}
+
+	// namespaces a Role Binding has been generated in, so that explicitly
+	// listed namespaces aren't duplicated when the selector matches them
+	generated := map[string]bool{}
+
+	if usesNamespaceSelector(rb) {
+		logrus.Debugf("Processing Namespace Selector %v", rb.NamespaceSelector)
+
+		listOptions := metav1.ListOptions{
+			LabelSelector: labels.Set(rb.NamespaceSelector.MatchLabels).String(),
+			FieldSelector: rb.NamespaceFieldSelector,
+		}
+		namespaces, err := p.listNamespaces(listOptions)
+		if err != nil {
+			if apierrors.IsForbidden(err) {
+				return &NamespaceListForbiddenError{Err: err}
+			}
+			return err
+		}
+
+		// The exclude selector is evaluated here rather than by the API
+		// server, since label selectors can't negate a whole selector
+		var excludeSelector labels.Selector
+		if hasExcludeSelector(rb) {
+			excludeSelector, err = metav1.LabelSelectorAsSelector(&rb.NamespaceExcludeSelector)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, namespace := range namespaces {
+			// Catch-all Role Bindings are only created in namespaces no
+			// other Role Binding in the RBAC Definition was created in
+			if rb.CatchAll && p.coveredNamespaces[namespace.Name] {
+				continue
+			}
+
+			if excludeSelector != nil && excludeSelector.Matches(labels.Set(namespace.Labels)) {
+				logrus.Debugf("Skipping namespace %v, excluded by %v", namespace.Name, excludeSelector)
+				continue
+			}
+
+			if !annotationsMatch(rb.AnnotationExpression, namespace.Annotations) {
+				logrus.Debugf("Skipping namespace %v, annotations don't match %v", namespace.Name, rb.AnnotationExpression)
+				continue
+			}
+
+			if !namespaceOwnerMatches(rb.NamespaceOwner, namespace.OwnerReferences) {
+				logrus.Debugf("Skipping namespace %v, not owned by %v", namespace.Name, *rb.NamespaceOwner)
+				continue
+			}
+
+			logrus.Debugf("Adding Role Binding With Dynamic Namespace %v", namespace.Name)
+
+			ok, err := p.parseRoleBindingInNamespace(rb, objectMeta, subjects, prefix, namespace.Name)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			generated[namespace.Name] = true
+
+			if !rb.CatchAll {
+				p.coverNamespace(namespace.Name)
+			}
+		}
+
+		p.recordSelectorMatch(rb, len(generated))
+
+	} else if p.isNamespaceGlob(rb.Namespace) {
+		namespaces, err := p.globNamespaces(rb.Namespace)
+		if err != nil {
+			return err
+		}
+
+		for _, namespace := range namespaces {
+			logrus.Debugf("Adding Role Binding With Namespace %v matching %v", namespace, rb.Namespace)
+
+			ok, err := p.parseRoleBindingInNamespace(rb, objectMeta, subjects, prefix, namespace)
+			if err != nil {
+				return err
+			}
+			if ok {
+				p.coverNamespace(namespace)
+				generated[namespace] = true
+			}
+		}
+
+	} else if rb.Namespace != "" {
+		terminating, err := p.skipTerminatingNamespace(rb.Namespace)
+		if err != nil {
+			return err
+		}
+
+		if !terminating {
+			ok, err := p.parseRoleBindingInNamespace(rb, objectMeta, subjects, prefix, rb.Namespace)
+			if err != nil {
+				return err
+			}
+			if ok {
+				p.coverNamespace(rb.Namespace)
+				generated[rb.Namespace] = true
+			}
+		}
+	}
+
+	listed := rb.Namespaces
+	if rb.Team != "" {
+		teamNamespaces, err := p.teamNamespaces(rb.Team)
+		if err != nil {
+			return err
+		}
+		listed = append(append([]string{}, rb.Namespaces...), teamNamespaces...)
+	}
+
+	if len(rb.NodeSelector) > 0 {
+		nodeNamespaces, err := p.nodeNamespaces(rb.NodeSelector)
+		if err != nil {
+			return err
+		}
+		listed = append(append([]string{}, listed...), nodeNamespaces...)
+	}
+
+	for _, namespace := range listed {
+		if generated[namespace] {
+			logrus.Debugf("Role Binding already generated in namespace %v", namespace)
+			continue
+		}
+
+		terminating, err := p.skipTerminatingNamespace(namespace)
+		if err != nil {
+			return err
+		}
+		if terminating {
+			continue
+		}
+
+		logrus.Debugf("Adding Role Binding With Listed Namespace %v", namespace)
+
+		ok, err := p.parseRoleBindingInNamespace(rb, objectMeta, subjects, prefix, namespace)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		generated[namespace] = true
+
+		p.coverNamespace(namespace)
+	}
+
+	// Catch-all Role Bindings are expected to be empty once every namespace
+	// is covered by other Role Bindings
+	if p.FailOnEmptyBinding && len(generated) == 0 && !rb.CatchAll && !rb.Both {
+		return fmt.Errorf("Role Binding %v is not generated in any namespace", objectMeta.Name)
+	}
+
+	if rb.Both && p.resourceTypeEnabled(ResourceTypeClusterRoleBinding) {
+		// The cluster scoped binding is suffixed to avoid colliding with
+		//   Cluster Role Bindings requested for the same Cluster Role
+		p.parsedClusterRoleBindings = append(p.parsedClusterRoleBindings, rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            p.clusterRoleBindingName(p.joinName(objectMeta.Name, "cluster")),
+				OwnerReferences: p.ownerRefs,
+				Labels:          p.objectLabels(),
+				Annotations:     p.annotations,
+			},
+			RoleRef:  roleRef,
+			Subjects: subjects,
+		})
+	}
+
+	return nil
+}
+
+// roleBindingTarget returns the name of the Role Binding generated in a
+// namespace along with the role it refers to. A Role can only be referenced
+// from its own namespace, so the Role Binding name includes the namespace to
+// keep it unique while the role ref uses the name of the Role itself.
+func (p *Parser) roleBindingTarget(rb rbacmanagerv1beta1.RoleBinding, prefix string, namespace string) (string, rbacv1.RoleRef, error) {
+	if rb.ClusterRole != "" {
+		roleRef := rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: rb.ClusterRole,
+		}
+		return p.joinName(prefix, rb.ClusterRole), overrideRoleRef(roleRef, rb.RoleRefAPIGroup, rb.RoleRefKind), nil
+	}
+
+	roleName, err := resolveRoleName(rb.Role, namespace)
+	if err != nil {
+		return "", rbacv1.RoleRef{}, err
+	}
+
+	roleRef := rbacv1.RoleRef{
+		Kind: "Role",
+		Name: roleName,
+	}
+	return p.joinName(prefix, roleName, namespace), overrideRoleRef(roleRef, rb.RoleRefAPIGroup, rb.RoleRefKind), nil
+}
+
+// compactNameQualifier returns a hash of the RBAC Definition name to suffix
+// compact Role Binding names with, so that RBAC Definitions binding the same
+// Service Account to the same role don't build the same name
+func compactNameQualifier(rbacDef *rbacmanagerv1beta1.RBACDefinition) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(rbacDef.Name))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// compactRoleBindingName returns a name like ci:clusterrole:edit:1a2b3c4d for
+// a Role Binding whose only subject is a Service Account in the Role
+// Binding's own namespace, suffixed with a qualifier from
+// compactNameQualifier. Names in the general scheme only contain a colon when
+// an RBAC Binding or role name does, so compact names don't collide with them.
+func compactRoleBindingName(subjects []rbacv1.Subject, roleRef rbacv1.RoleRef, namespace string, qualifier string) (string, bool) {
+	if len(subjects) != 1 {
+		return "", false
+	}
+
+	subject := subjects[0]
+	if subject.Kind != rbacv1.ServiceAccountKind || subject.Namespace != namespace {
+		return "", false
+	}
+
+	return fmt.Sprintf("%v:%v:%v:%v", subject.Name, strings.ToLower(roleRef.Kind), roleRef.Name, qualifier), true
+}
+
+// recordSelectorMatch records the number of namespaces a Role Binding with a
+// namespace selector was generated in
+func (p *Parser) recordSelectorMatch(rb rbacmanagerv1beta1.RoleBinding, namespaces int) {
+	role := rb.ClusterRole
+	if role == "" {
+		role = rb.Role
+	}
+
+	p.selectorMatches = append(p.selectorMatches, rbacmanagerv1beta1.SelectorMatch{
+		RBACBinding: p.rbacBindingName,
+		Role:        role,
+		Selector:    metav1.FormatLabelSelector(&rb.NamespaceSelector),
+		Namespaces:  int32(namespaces),
+	})
+}
+
+// SelectorMatches returns the number of namespaces each Role Binding with a
+// namespace selector was generated in by the last call to Parse
+func (p *Parser) SelectorMatches() []rbacmanagerv1beta1.SelectorMatch {
+	return p.selectorMatches
+}
+
+// coverNamespace records that a Role Binding was generated in a namespace,
+// excluding it from catch-all Role Bindings
+func (p *Parser) coverNamespace(namespace string) {
+	if p.coveredNamespaces == nil {
+		p.coveredNamespaces = map[string]bool{}
+	}
+	p.coveredNamespaces[namespace] = true
+}
+
+// subjectsForNamespace returns the subjects of a Role Binding generated in a
+// namespace, including any subject overrides requested for that namespace.
+// Overrides are resolved like any other subjects, and the merged subjects
+// are held to the same limit.
+func (p *Parser) subjectsForNamespace(rb rbacmanagerv1beta1.RoleBinding, subjects []rbacv1.Subject, bindingName string, namespace string) ([]rbacv1.Subject, error) {
+	overrides, ok := rb.SubjectOverrides[namespace]
+	if !ok {
+		return subjects, nil
+	}
+
+	overrides, err := p.resolveSubjects(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := append([]rbacv1.Subject{}, subjects...)
+	for _, subject := range overrides {
+		merged = appendUniqueSubject(merged, subject)
+	}
+
+	err = p.checkSubjectLimit(bindingName, merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.orderSubjects(merged), nil
+}
+
+// orderSubjects sorts subjects when SortSubjects is set, otherwise they keep
+// the order of the RBAC Definition
+func (p *Parser) orderSubjects(subjects []rbacv1.Subject) []rbacv1.Subject {
+	if !p.SortSubjects {
+		return subjects
+	}
+	return sortSubjects(subjects)
+}
+
+// sortSubjects returns a copy of subjects sorted by Kind, Namespace, and
+// Name so that reordering subjects in an RBAC Definition has no effect on
+// the generated bindings
+func sortSubjects(subjects []rbacv1.Subject) []rbacv1.Subject {
+	sorted := append([]rbacv1.Subject{}, subjects...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Kind != sorted[j].Kind {
+			return sorted[i].Kind < sorted[j].Kind
+		}
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// annotationsMatch returns true if namespace annotations meet every
+// requirement of an annotation expression
+func annotationsMatch(requirements []rbacmanagerv1beta1.AnnotationRequirement, annotations map[string]string) bool {
+	for _, requirement := range requirements {
+		value, ok := annotations[requirement.Key]
+
+		switch requirement.Operator {
+		case AnnotationOperatorIn:
+			if !ok || !containsString(requirement.Values, value) {
+				return false
+			}
+		case AnnotationOperatorNotIn:
+			if ok && containsString(requirement.Values, value) {
+				return false
+			}
+		case AnnotationOperatorExists:
+			if !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// teamNamespaces returns the namespaces that belong to a team
+func (p *Parser) teamNamespaces(team string) ([]string, error) {
+	if p.TeamNamespaceLister == nil {
+		return nil, fmt.Errorf("Unable to find namespaces for team %v, team namespaces are not configured", team)
+	}
+
+	namespaces, err := p.TeamNamespaceLister.ListTeamNamespaces(team)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing namespaces for team %v: %v", team, err)
+	}
+
+	logrus.Debugf("Team %v has namespaces %v", team, namespaces)
+	return namespaces, nil
+}
+
+// nodeNamespaces returns the namespaces running workloads on nodes matching
+// a node selector
+func (p *Parser) nodeNamespaces(nodeSelector map[string]string) ([]string, error) {
+	selector := labels.Set(nodeSelector).String()
+	if p.NodeNamespaceResolver == nil {
+		return nil, fmt.Errorf("Unable to find namespaces for node selector %v, node namespaces are not configured", selector)
+	}
+
+	namespaces, err := p.NodeNamespaceResolver.ResolveNodeNamespaces(nodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("Error resolving namespaces for node selector %v: %v", selector, err)
+	}
+
+	logrus.Debugf("Nodes matching %v run workloads in namespaces %v", selector, namespaces)
+	return namespaces, nil
+}
+
+// resourcePresent returns true if a namespace has a resource matching a
+// requirement
+func (p *Parser) resourcePresent(namespace string, requirement rbacmanagerv1beta1.ResourceRequirement) (bool, error) {
+	if p.ResourcePresenceResolver == nil {
+		return false, fmt.Errorf("Unable to check namespace %v for %v, resource presence is not configured", namespace, requirement.Kind)
+	}
+
+	present, err := p.ResourcePresenceResolver.ResourcePresent(namespace, requirement)
+	if err != nil {
+		return false, fmt.Errorf("Error checking namespace %v for %v: %v", namespace, requirement.Kind, err)
+	}
+
+	return present, nil
 }
 
-// Parse determines the desired Kubernetes resources an RBAC Definition refers to
-func (p *Parser) Parse(rbacDef rbacmanagerv1beta1.RBACDefinition) error {
-	if rbacDef.RBACBindings == nil {
-		logrus.Warn("No RBACBindings defined")
-		return nil
+// usesNamespaceSelector returns true if a Role Binding selects namespaces by
+// their labels rather than only by name. An exclude selector on its own
+// selects every namespace it doesn't match.
+func usesNamespaceSelector(rb rbacmanagerv1beta1.RoleBinding) bool {
+	return rb.NamespaceSelector.MatchLabels != nil || rb.CatchAll || hasExcludeSelector(rb)
+}
+
+// hasExcludeSelector returns true if a Role Binding excludes namespaces by label
+func hasExcludeSelector(rb rbacmanagerv1beta1.RoleBinding) bool {
+	return len(rb.NamespaceExcludeSelector.MatchLabels) > 0 || len(rb.NamespaceExcludeSelector.MatchExpressions) > 0
+}
+
+// parseRoleBindingInNamespace generates a Role Binding in a single namespace,
+// returning false if it was skipped because a required Role is missing
+func (p *Parser) parseRoleBindingInNamespace(
+	rb rbacmanagerv1beta1.RoleBinding, objectMeta metav1.ObjectMeta, subjects []rbacv1.Subject, prefix string, namespace string) (bool, error) {
+
+	name, roleRef, err := p.roleBindingTarget(rb, prefix, namespace)
+	if err != nil {
+		return false, err
 	}
 
-	for _, rbacBinding := range rbacDef.RBACBindings {
-		namePrefix := rdNamePrefix(&rbacDef, &rbacBinding)
+	if p.Requester != nil {
+		allowed, err := p.requesterCanBind(namespace)
+		if err != nil {
+			return false, err
+		}
 
-		err := p.parseRBACBinding(rbacBinding, namePrefix)
+		if !allowed {
+			logrus.Warnf("Skipping namespace %v, %v can't create Role Bindings in it", namespace, p.Requester.User)
+			return false, nil
+		}
+	}
+
+	if rb.RequireRolePresent {
+		present, err := p.rolePresent(roleRef.Name, namespace)
 		if err != nil {
-			return err
+			return false, err
+		}
+
+		if !present {
+			logrus.Debugf("Skipping namespace %v, Role %v does not exist", namespace, roleRef.Name)
+			return false, nil
+		}
+	}
+
+	if rb.RequireResource != nil {
+		present, err := p.resourcePresent(namespace, *rb.RequireResource)
+		if err != nil {
+			return false, err
+		}
+
+		if !present {
+			logrus.Debugf("Skipping namespace %v, no %v matching %v", namespace, rb.RequireResource.Kind, metav1.FormatLabelSelector(&rb.RequireResource.LabelSelector))
+			return false, nil
+		}
+	}
+
+	objectMeta.Name = name
+	objectMeta.Namespace = namespace
+
+	nsSubjects, err := p.subjectsForNamespace(rb, subjects, name, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	if p.CompactServiceAccountNames {
+		if compactName, ok := compactRoleBindingName(nsSubjects, roleRef, namespace, p.compactQualifier); ok {
+			objectMeta.Name = compactName
 		}
 	}
 
+	p.parsedRoleBindings = append(p.parsedRoleBindings, rbacv1.RoleBinding{
+		ObjectMeta: objectMeta,
+		RoleRef:    roleRef,
+		Subjects:   nsSubjects,
+	})
+
+	return true, nil
+}
+
+// checkServiceAccountExists returns an error if a Service Account subject of
+// an RBAC Binding that doesn't create Service Accounts does not exist
+func (p *Parser) checkServiceAccountExists(rbacBindingName string, subject rbacv1.Subject) error {
+	_, err := p.Clientset.CoreV1().ServiceAccounts(subject.Namespace).Get(subject.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("Service Account %v/%v does not exist, RBAC Binding %v does not create Service Accounts", subject.Namespace, subject.Name, rbacBindingName)
+		}
+		return err
+	}
+
 	return nil
 }
 
-func (p *Parser) parseRBACBinding(rbacBinding rbacmanagerv1beta1.RBACBinding, namePrefix string) error {
-	if len(rbacBinding.Subjects) < 1 {
-		return errors.New("No subjects specified for RBAC Binding: " + namePrefix)
+// requesterCanBind returns true if the Requester can create Role Bindings in a
+// namespace, results are cached for the rest of the parse
+func (p *Parser) requesterCanBind(namespace string) (bool, error) {
+	if allowed, ok := p.requesterNamespaces[namespace]; ok {
+		return allowed, nil
 	}
 
-	for _, requestedSubject := range rbacBinding.Subjects {
-		if requestedSubject.Kind == "ServiceAccount" {
-			p.parsedServiceAccounts = append(p.parsedServiceAccounts, v1.ServiceAccount{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:            requestedSubject.Name,
-					Namespace:       requestedSubject.Namespace,
-					OwnerReferences: p.ownerRefs,
-					Labels:          Labels,
-				},
-			})
+	review, err := p.Clientset.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   p.Requester.User,
+			Groups: p.Requester.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "create",
+				Group:     rbacv1.GroupName,
+				Resource:  "rolebindings",
+			},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("Error checking if %v can create Role Bindings in %v namespace: %v", p.Requester.User, namespace, err)
+	}
+
+	if p.requesterNamespaces == nil {
+		p.requesterNamespaces = map[string]bool{}
+	}
+	p.requesterNamespaces[namespace] = review.Status.Allowed
+
+	return review.Status.Allowed, nil
+}
+
+// rolePresent returns true if a Role exists in a namespace
+func (p *Parser) rolePresent(name string, namespace string) (bool, error) {
+	p.checkedRoles = append(p.checkedRoles, RoleKey{Kind: "Role", Namespace: namespace, Name: name})
+
+	_, err := p.Clientset.RbacV1().Roles(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
 		}
+		return false, err
 	}
 
-	if rbacBinding.ClusterRoleBindings != nil {
-		for _, requestedCRB := range rbacBinding.ClusterRoleBindings {
-			err := p.parseClusterRoleBinding(requestedCRB, rbacBinding.Subjects, namePrefix)
+	return true, nil
+}
+
+// namespaceOwnerMatches returns true if a namespace has an owner reference
+// matching every field set on owner, or if owner is nil
+func namespaceOwnerMatches(owner *rbacmanagerv1beta1.NamespaceOwner, ownerRefs []metav1.OwnerReference) bool {
+	if owner == nil {
+		return true
+	}
+
+	for _, ownerRef := range ownerRefs {
+		if owner.Kind != "" && owner.Kind != ownerRef.Kind {
+			continue
+		}
+
+		if owner.UID != "" && owner.UID != string(ownerRef.UID) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// isRoleTemplate returns true if a Role name contains template actions that
+// are resolved against the namespace of each Role Binding
+func isRoleTemplate(role string) bool {
+	return strings.Contains(role, "{{")
+}
+
+// resolveRoleName renders a templated Role name like reader-{{.Namespace}}
+// for a namespace, names without template actions are returned unchanged
+func resolveRoleName(role string, namespace string) (string, error) {
+	if !isRoleTemplate(role) {
+		return role, nil
+	}
+
+	tmpl, err := template.New("role").Option("missingkey=error").Parse(role)
+	if err != nil {
+		return "", fmt.Errorf("Invalid role template %v: %v", role, err)
+	}
+
+	var name bytes.Buffer
+	err = tmpl.Execute(&name, struct{ Namespace string }{namespace})
+	if err != nil {
+		return "", fmt.Errorf("Invalid role template %v: %v", role, err)
+	}
+
+	return name.String(), nil
+}
+
+// checkNameSeparator rejects RBAC Definition and RBAC Binding names that
+// contain a custom name separator. Generated names can then always be split
+// back into their parts, and names built from different parts can't collide.
+// Names containing the default separator are still allowed, since existing
+// RBAC Definitions commonly use it.
+func (p *Parser) checkNameSeparator(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+	separator := p.nameSeparator()
+	if separator == DefaultNameSeparator {
+		return nil
+	}
+
+	if strings.Contains(rbacDef.Name, separator) {
+		return fmt.Errorf("Invalid RBAC Definition name %v, names can not contain the name separator %v", rbacDef.Name, separator)
+	}
+
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		if strings.Contains(rbacBinding.Name, separator) {
+			return fmt.Errorf("Invalid RBAC Binding name %v, names can not contain the name separator %v", rbacBinding.Name, separator)
+		}
+	}
+
+	return nil
+}
+
+// checkDuplicateBindingNames returns an error if RBAC Bindings share a name,
+// since their generated resources would collide
+func checkDuplicateBindingNames(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+	names := map[string]bool{}
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		if names[rbacBinding.Name] {
+			return fmt.Errorf("Duplicate RBAC Binding name %v in %v", rbacBinding.Name, rbacDef.Name)
+		}
+		names[rbacBinding.Name] = true
+	}
+
+	return nil
+}
+
+// canonicalSubjectKind returns a subject kind with its canonical
+// capitalization, ok is false for unrecognized kinds
+func canonicalSubjectKind(kind string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case strings.ToLower(rbacv1.ServiceAccountKind):
+		return rbacv1.ServiceAccountKind, true
+	case strings.ToLower(rbacv1.UserKind):
+		return rbacv1.UserKind, true
+	case strings.ToLower(rbacv1.GroupKind):
+		return rbacv1.GroupKind, true
+	}
+
+	return kind, false
+}
+
+// normalizeSubjects returns a copy of subjects with each Kind converted to
+// its canonical capitalization and each namespace normalized, unrecognized
+// kinds and invalid namespaces result in an error
+func normalizeSubjects(subjects []rbacv1.Subject) ([]rbacv1.Subject, error) {
+	normalized := make([]rbacv1.Subject, len(subjects))
+	for i, subject := range subjects {
+		kind, ok := canonicalSubjectKind(subject.Kind)
+		if !ok {
+			return nil, fmt.Errorf("Invalid subject kind %v for %v", subject.Kind, subject.Name)
+		}
+		subject.Kind = kind
+
+		if subject.Namespace != "" {
+			namespace, err := normalizeNamespace(subject.Namespace)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			subject.Namespace = namespace
 		}
+		normalized[i] = subject
 	}
 
-	if rbacBinding.RoleBindings != nil {
-		for _, requestedRB := range rbacBinding.RoleBindings {
-			err := p.parseRoleBinding(requestedRB, rbacBinding.Subjects, namePrefix)
-			if err != nil {
-				return err
+	return normalized, nil
+}
+
+// defaultSubjectAPIGroups sets the DefaultUserAPIGroup and DefaultGroupAPIGroup
+// on User and Group subjects without an API group
+func (p *Parser) defaultSubjectAPIGroups(subjects []rbacv1.Subject) []rbacv1.Subject {
+	defaulted := make([]rbacv1.Subject, len(subjects))
+	for i, subject := range subjects {
+		if subject.APIGroup == "" {
+			switch subject.Kind {
+			case rbacv1.UserKind:
+				subject.APIGroup = p.DefaultUserAPIGroup
+			case rbacv1.GroupKind:
+				subject.APIGroup = p.DefaultGroupAPIGroup
 			}
 		}
+		defaulted[i] = subject
+	}
+
+	return defaulted
+}
+
+// checkServiceAccountNames returns an error if a Service Account subject has a
+// name longer than the Service Account name limit. Service Account names are
+// chosen explicitly, so unlike generated names they are never truncated.
+func (p *Parser) checkServiceAccountNames(subjects []rbacv1.Subject) error {
+	maxLength := p.maxNameLength(ResourceTypeServiceAccount)
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind && len(subject.Name) > maxLength {
+			return fmt.Errorf("Invalid Service Account name %v, names can not be longer than %v characters", subject.Name, maxLength)
+		}
 	}
+
 	return nil
 }
 
-func (p *Parser) parseClusterRoleBinding(
-	crb rbacmanagerv1beta1.ClusterRoleBinding, subjects []rbacv1.Subject, prefix string) error {
-	crbName := fmt.Sprintf("%v-%v", prefix, crb.ClusterRole)
+// checkSystemGroups returns an error if any subject is a system group
+func checkSystemGroups(subjects []rbacv1.Subject) error {
+	for _, subject := range subjects {
+		if subject.Kind != rbacv1.GroupKind {
+			continue
+		}
 
-	p.parsedClusterRoleBindings = append(p.parsedClusterRoleBindings, rbacv1.ClusterRoleBinding{
+		for _, systemGroup := range SystemGroups {
+			if subject.Name == systemGroup {
+				return fmt.Errorf("Binding to system group %v is not allowed", subject.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSystemUsers returns an error if any subject is a User with a reserved
+// SystemUserPrefixes name
+func checkSystemUsers(subjects []rbacv1.Subject) error {
+	for _, subject := range subjects {
+		if subject.Kind != rbacv1.UserKind {
+			continue
+		}
+
+		for _, prefix := range SystemUserPrefixes {
+			if strings.HasPrefix(subject.Name, prefix) {
+				return fmt.Errorf("Binding to system user %v is not allowed, names starting with %v are reserved", subject.Name, prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSubjectKinds returns an error listing every subject with a kind in
+// DisallowedSubjectKinds
+func (p *Parser) checkSubjectKinds(subjects []rbacv1.Subject) error {
+	disallowed := []string{}
+
+	for _, subject := range subjects {
+		if p.DisallowedSubjectKinds[subject.Kind] {
+			disallowed = append(disallowed, fmt.Sprintf("%v %v", subject.Kind, subject.Name))
+		}
+	}
+
+	if len(disallowed) > 0 {
+		return fmt.Errorf("Disallowed subject kinds: %v", strings.Join(disallowed, ", "))
+	}
+
+	return nil
+}
+
+// checkSubjectNames returns an error listing every subject with a name that
+// doesn't match the SubjectNamePatterns for its kind
+func (p *Parser) checkSubjectNames(subjects []rbacv1.Subject) error {
+	violations := []string{}
+
+	for _, subject := range subjects {
+		pattern, ok := p.SubjectNamePatterns[subject.Kind]
+		if !ok || pattern.MatchString(subject.Name) {
+			continue
+		}
+
+		violations = append(violations, fmt.Sprintf("%v %v does not match %v", subject.Kind, subject.Name, pattern))
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("Invalid subject names: %v", strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// resolveClusterRole translates a Cluster Role name found in RoleAliases,
+// other names are returned unchanged unless ValidateRoleAliases is set and
+// no Cluster Role with that name exists
+func (p *Parser) resolveClusterRole(name string) (string, error) {
+	if clusterRole, ok := p.RoleAliases[name]; ok {
+		logrus.Debugf("Resolved role alias %v to Cluster Role %v", name, clusterRole)
+		return clusterRole, nil
+	}
+
+	if !p.ValidateRoleAliases {
+		return name, nil
+	}
+
+	_, err := p.Clientset.RbacV1().ClusterRoles().Get(name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("Unknown role alias or Cluster Role %v", name)
+		}
+		return "", err
+	}
+
+	return name, nil
+}
+
+// mapGroupNames returns a copy of subjects with Group names translated by
+// the GroupNameMap, unmapped groups pass through unchanged
+func (p *Parser) mapGroupNames(subjects []rbacv1.Subject) []rbacv1.Subject {
+	if len(p.GroupNameMap) == 0 {
+		return subjects
+	}
+
+	mapped := make([]rbacv1.Subject, len(subjects))
+	for i, subject := range subjects {
+		if subject.Kind == rbacv1.GroupKind {
+			if name, ok := p.GroupNameMap[subject.Name]; ok {
+				logrus.Debugf("Mapping group %v to %v", subject.Name, name)
+				subject.Name = name
+			}
+		}
+		mapped[i] = subject
+	}
+
+	return mapped
+}
+
+// addTokenSecret generates a Secret for a Service Account that the token
+// controller populates with a long-lived token, unless one was already
+// generated for the Service Account
+func (p *Parser) addTokenSecret(subject rbacv1.Subject, labels map[string]string) {
+	name := TokenSecretName(subject.Name)
+	for _, secret := range p.parsedSecrets {
+		if secret.Namespace == subject.Namespace && secret.Name == name {
+			return
+		}
+	}
+
+	p.parsedSecrets = append(p.parsedSecrets, v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            crbName,
+			Name:            name,
+			Namespace:       subject.Namespace,
 			OwnerReferences: p.ownerRefs,
-			Labels:          Labels,
-		},
-		RoleRef: rbacv1.RoleRef{
-			Kind: "ClusterRole",
-			Name: crb.ClusterRole,
+			Labels:          labels,
+			Annotations:     withAnnotation(p.annotations, v1.ServiceAccountNameKey, subject.Name),
 		},
-		Subjects: subjects,
+		Type: v1.SecretTypeServiceAccountToken,
 	})
+}
 
-	return nil
+// TokenSecretName returns the name of the token Secret generated for a
+// Service Account
+func TokenSecretName(serviceAccount string) string {
+	return serviceAccount + "-token"
 }
 
-func (p *Parser) parseRoleBinding(
-	rb rbacmanagerv1beta1.RoleBinding, subjects []rbacv1.Subject, prefix string) error {
+// secretReferences converts secret names into references for a Service Account
+func secretReferences(secrets []string) []v1.ObjectReference {
+	if len(secrets) == 0 {
+		return nil
+	}
 
-	objectMeta := metav1.ObjectMeta{
-		OwnerReferences: p.ownerRefs,
-		Labels:          Labels,
+	refs := []v1.ObjectReference{}
+	for _, secret := range secrets {
+		refs = append(refs, v1.ObjectReference{Name: secret})
 	}
 
-	var requestedRoleName string
-	var roleRef rbacv1.RoleRef
+	return refs
+}
 
-	if rb.ClusterRole != "" {
-		logrus.Debugf("Processing Requested ClusterRole %v <> %v <> %v", rb.ClusterRole, rb.Namespace, rb)
-		requestedRoleName = rb.ClusterRole
-		roleRef = rbacv1.RoleRef{
-			Kind: "ClusterRole",
-			Name: rb.ClusterRole,
-		}
-	} else if rb.Role != "" {
-		logrus.Debugf("Processing Requested Role %v <> %v <> %v", rb.Role, rb.Namespace, rb)
-		requestedRoleName = fmt.Sprintf("%v-%v", rb.Role, rb.Namespace)
-		roleRef = rbacv1.RoleRef{
-			Kind: "Role",
-			Name: rb.Role,
+// resourceTypeEnabled returns true if resources of the given type should be generated
+func (p *Parser) resourceTypeEnabled(resourceType string) bool {
+	if len(p.EnabledResourceTypes) == 0 {
+		return true
+	}
+	return p.EnabledResourceTypes[resourceType]
+}
+
+// definitionLabels merges the inherited labels of an RBAC Definition under
+// the labels given to all resources managed by RBAC Manager
+func (p *Parser) definitionLabels(rbacDef *rbacmanagerv1beta1.RBACDefinition) map[string]string {
+	if len(p.InheritDefinitionLabels) == 0 {
+		return Labels
+	}
+
+	merged := map[string]string{}
+	for _, key := range p.InheritDefinitionLabels {
+		if value, ok := rbacDef.Labels[key]; ok {
+			merged[key] = value
 		}
-	} else {
-		return errors.New("Invalid role binding, role or clusterRole required")
 	}
 
-	objectMeta.Name = fmt.Sprintf("%v-%v", prefix, requestedRoleName)
+	for key, value := range Labels {
+		merged[key] = value
+	}
 
-	if rb.NamespaceSelector.MatchLabels != nil {
-		logrus.Debugf("Processing Namespace Selector %v", rb.NamespaceSelector)
+	return merged
+}
+
+// objectLabels returns the labels to give a generated resource
+func (p *Parser) objectLabels() map[string]string {
+	if p.labels == nil {
+		return Labels
+	}
+	return p.labels
+}
+
+// bindingAnnotations returns the annotations added to each resource generated
+// for an RBAC Binding, nil if there are none
+func bindingAnnotations(rbacBinding *rbacmanagerv1beta1.RBACBinding) (map[string]string, error) {
+	if rbacBinding.ApplyOrder < 0 {
+		return nil, fmt.Errorf("Invalid applyOrder %v for RBAC Binding %v, must not be negative", rbacBinding.ApplyOrder, rbacBinding.Name)
+	}
 
-		listOptions := metav1.ListOptions{LabelSelector: labels.Set(rb.NamespaceSelector.MatchLabels).String()}
-		namespaces, err := p.Clientset.CoreV1().Namespaces().List(listOptions)
+	if rbacBinding.ExpiresAt == "" && !rbacBinding.DryRun && len(rbacBinding.SubjectDisplayNames) == 0 && rbacBinding.ApplyOrder == 0 {
+		return nil, nil
+	}
+
+	annotations := map[string]string{}
+
+	if rbacBinding.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, rbacBinding.ExpiresAt)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("Invalid expiresAt for RBAC Binding %v, must be an RFC3339 time: %v", rbacBinding.Name, err)
 		}
+		annotations[ExpiresAtAnnotation] = expiresAt.UTC().Format(time.RFC3339)
+	}
 
-		for _, namespace := range namespaces.Items {
-			logrus.Debugf("Adding Role Binding With Dynamic Namespace %v", namespace.Name)
+	if rbacBinding.DryRun {
+		annotations[DryRunAnnotation] = "true"
+	}
+
+	for subject, displayName := range rbacBinding.SubjectDisplayNames {
+		annotations[subjectDisplayAnnotation(subject)] = displayName
+	}
 
-			om := objectMeta
-			om.Namespace = namespace.Name
+	if rbacBinding.ApplyOrder > 0 {
+		annotations[ApplyOrderAnnotation] = strconv.Itoa(rbacBinding.ApplyOrder)
+	}
 
-			p.parsedRoleBindings = append(p.parsedRoleBindings, rbacv1.RoleBinding{
-				ObjectMeta: om,
-				RoleRef:    roleRef,
-				Subjects:   subjects,
-			})
+	return annotations, nil
+}
+
+// invalidAnnotationNameChars matches characters that can't be used in the
+// name part of an annotation key
+var invalidAnnotationNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// maxSubjectDisplayNameLength leaves room within the 63 character limit on
+// the name part of annotation keys for the subject-display- prefix
+const maxSubjectDisplayNameLength = 47
+
+// subjectDisplayAnnotation returns the annotation key for the display name
+// of a subject. Subject names like email addresses aren't valid in annotation
+// keys, so invalid characters are replaced and long names are truncated.
+func subjectDisplayAnnotation(subject string) string {
+	name := strings.Trim(invalidAnnotationNameChars.ReplaceAllString(subject, "-"), "-_.")
+	return SubjectDisplayAnnotationPrefix + truncateName(name, maxSubjectDisplayNameLength)
+}
+
+// serviceAccountLabels returns the labels for a Service Account generated in
+// a namespace, with PropagateNamespaceLabels copied from the namespace
+func (p *Parser) serviceAccountLabels(namespace string) (map[string]string, error) {
+	if len(p.PropagateNamespaceLabels) == 0 {
+		return p.objectLabels(), nil
+	}
+
+	ns, err := p.Clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logrus.Debugf("Namespace %v not found, not propagating labels", namespace)
+			return p.objectLabels(), nil
+		}
+		return nil, err
+	}
+
+	merged := map[string]string{}
+	for _, key := range p.PropagateNamespaceLabels {
+		if value, ok := ns.Labels[key]; ok {
+			merged[key] = value
 		}
+	}
 
-	} else if rb.Namespace != "" {
-		objectMeta.Namespace = rb.Namespace
+	for key, value := range p.objectLabels() {
+		merged[key] = value
+	}
 
-		p.parsedRoleBindings = append(p.parsedRoleBindings, rbacv1.RoleBinding{
-			ObjectMeta: objectMeta,
-			RoleRef:    roleRef,
-			Subjects:   subjects,
-		})
+	return merged, nil
+}
 
-	} else {
-		return errors.New("Invalid role binding, namespace or namespace selector required")
+// checkSubjectLimit returns an error if a binding has more subjects than
+// the MaxSubjectsPerBinding limit allows
+func (p *Parser) checkSubjectLimit(bindingName string, subjects []rbacv1.Subject) error {
+	if p.MaxSubjectsPerBinding > 0 && len(subjects) > p.MaxSubjectsPerBinding {
+		return fmt.Errorf("Binding %v has %v subjects, exceeding the limit of %v",
+			bindingName, len(subjects), p.MaxSubjectsPerBinding)
 	}
 
 	return nil
 }
 
-func (p *Parser) hasNamespaceSelectors(rbacDef *rbacmanagerv1beta1.RBACDefinition) bool {
+// overrideRoleRef allows bindings to reference role-like resources outside
+// of the standard RBAC API group, empty values keep the standard RBAC defaults
+func overrideRoleRef(roleRef rbacv1.RoleRef, apiGroup string, kind string) rbacv1.RoleRef {
+	if apiGroup != "" {
+		roleRef.APIGroup = apiGroup
+	}
+
+	if kind != "" {
+		roleRef.Kind = kind
+	}
+
+	return roleRef
+}
+
+// HasNamespaceSelectors returns true if any Role Binding in an RBAC Definition
+// is targeted with a namespace selector or namespace pattern, or is a catch-all
+func (p *Parser) HasNamespaceSelectors(rbacDef *rbacmanagerv1beta1.RBACDefinition) bool {
 	for _, rbacBinding := range rbacDef.RBACBindings {
 		for _, roleBinding := range rbacBinding.RoleBindings {
-			if roleBinding.Namespace == "" && roleBinding.NamespaceSelector.MatchLabels != nil {
+			if roleBinding.Namespace == "" && (usesNamespaceSelector(roleBinding) || roleBinding.PerLabelValue != nil) {
+				return true
+			}
+			if p.isNamespaceGlob(roleBinding.Namespace) {
 				return true
 			}
 		}
@@ -179,15 +2260,27 @@ func (p *Parser) hasNamespaceSelectors(rbacDef *rbacmanagerv1beta1.RBACDefinitio
 	return false
 }
 
-func (p *Parser) parseRoleBindings(rbacDef *rbacmanagerv1beta1.RBACDefinition) {
-	for _, rbacBinding := range rbacDef.RBACBindings {
-		for _, roleBinding := range rbacBinding.RoleBindings {
-			namePrefix := rdNamePrefix(rbacDef, &rbacBinding)
-			p.parseRoleBinding(roleBinding, rbacBinding.Subjects, namePrefix)
-		}
+// SortByPriority orders RBAC Definitions so that higher priority definitions
+// are processed first, definitions with equal priority keep their order
+func SortByPriority(rbacDefs []rbacmanagerv1beta1.RBACDefinition) {
+	sort.SliceStable(rbacDefs, func(i, j int) bool {
+		return rbacDefs[i].Priority > rbacDefs[j].Priority
+	})
+}
+
+func (p *Parser) rdNamePrefix(rbacDef *rbacmanagerv1beta1.RBACDefinition, rbacBinding *rbacmanagerv1beta1.RBACBinding) string {
+	return p.joinName(rbacDef.Name, rbacBinding.Name)
+}
+
+// nameSeparator returns the separator joining the parts of generated names
+func (p *Parser) nameSeparator() string {
+	if p.NameSeparator == "" {
+		return DefaultNameSeparator
 	}
+	return p.NameSeparator
 }
 
-func rdNamePrefix(rbacDef *rbacmanagerv1beta1.RBACDefinition, rbacBinding *rbacmanagerv1beta1.RBACBinding) string {
-	return fmt.Sprintf("%v-%v", rbacDef.Name, rbacBinding.Name)
+// joinName joins the parts of a generated name with the name separator
+func (p *Parser) joinName(parts ...string) string {
+	return strings.Join(parts, p.nameSeparator())
 }
@@ -15,14 +15,26 @@
 package rbacdefinition
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
+	logrus "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestParseEmpty(t *testing.T) {
@@ -30,6 +42,25 @@ func TestParseEmpty(t *testing.T) {
 	testEmpty(t, client, "empty-example")
 }
 
+func TestParseWarnOnEmpty(t *testing.T) {
+	var output bytes.Buffer
+	logrus.SetOutput(&output)
+	defer logrus.SetOutput(os.Stderr)
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "placeholder"
+
+	p := Parser{Clientset: fake.NewSimpleClientset(), WarnOnEmpty: true}
+	assert.NoError(t, p.Parse(rbacDef))
+	assert.Contains(t, output.String(), "No RBACBindings defined")
+
+	output.Reset()
+
+	p = Parser{Clientset: fake.NewSimpleClientset(), WarnOnEmpty: false}
+	assert.NoError(t, p.Parse(rbacDef))
+	assert.NotContains(t, output.String(), "No RBACBindings defined")
+}
+
 func TestParseStandard(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
@@ -167,6 +198,3143 @@ func TestParseMissingSubjects(t *testing.T) {
 	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
 }
 
+func TestParseRoleWithNamespaceSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"app": "web", "team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"app": "api", "team": "devs"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			Role:              "custom",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error when combining role with namespace selector")
+	expectParsedRB(t, p, []rbacv1.RoleBinding{})
+
+	// The same Role with an explicit namespace remains valid
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		Namespace: "web",
+		Role:      "custom",
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-custom-web",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "Role",
+			Name: "custom",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+}
+
+func TestParseTemplatedRoleWithNamespaceSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			Role:              "reader-{{.Namespace}}",
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-reader-api-api",
+			Namespace: "api",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "Role",
+			Name: "reader-api",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-reader-web-web",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "Role",
+			Name: "reader-web",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	rbacDef.RBACBindings[0].RoleBindings[0].Role = "reader-{{.Missing}}"
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for a role template with an unknown field")
+}
+
+func TestParseSubjectOverrides(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+			SubjectOverrides: map[string][]rbacv1.Subject{
+				"web": {{
+					Kind:      "serviceaccount",
+					Name:      "web-deployer",
+					Namespace: "web",
+				}, {
+					Kind: rbacv1.UserKind,
+					Name: "joe",
+				}},
+				"missing": {{
+					Kind: rbacv1.UserKind,
+					Name: "sue",
+				}},
+			},
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-edit",
+			Namespace: "api",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-edit",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "web-deployer",
+			Namespace: "web",
+		}},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	rbacDef.RBACBindings[0].RoleBindings[0].SubjectOverrides["web"] = []rbacv1.Subject{{
+		Kind: rbacv1.GroupKind,
+		Name: "system:authenticated",
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for a system group override")
+}
+
+func TestParseSubjectOverridesResolved(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			ClusterRole:       "edit",
+			SubjectOverrides: map[string][]rbacv1.Subject{
+				"web": {{
+					Kind: rbacv1.GroupKind,
+					Name: "web-team",
+				}},
+			},
+		}},
+	}}
+
+	// override subjects are defaulted like the binding's own subjects
+	p := Parser{Clientset: client, DefaultGroupAPIGroup: "auth.example.com"}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind:     rbacv1.GroupKind,
+		APIGroup: "auth.example.com",
+		Name:     "web-team",
+	}}, p.parsedRoleBindings[0].Subjects)
+
+	// override groups are expanded into their members
+	p = Parser{
+		Clientset:             client,
+		GroupMembershipLister: fakeGroupMembershipLister{"web-team": {"joe", "sue"}},
+	}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}}, p.parsedRoleBindings[0].Subjects)
+
+	// merged subjects are held to the subject limit
+	p = Parser{
+		Clientset:             client,
+		GroupMembershipLister: fakeGroupMembershipLister{"web-team": {"sue", "kay"}},
+		MaxSubjectsPerBinding: 2,
+	}
+	err = p.Parse(rbacDef)
+	assert.EqualError(t, err, "Binding rbac-config-devs-edit has 3 subjects, exceeding the limit of 2")
+}
+
+func TestParseAnnotationExpression(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	for name, tier := range map[string]string{"gold": "gold", "silver": "silver", "none": ""} {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"team": "devs"},
+		}}
+		if tier != "" {
+			namespace.Annotations = map[string]string{"tier": tier}
+		}
+		_, err := client.CoreV1().Namespaces().Create(namespace)
+		if err != nil {
+			t.Fatalf("Error creating namespace %v", err)
+		}
+	}
+
+	expectedRoleBindings := func(namespaces ...string) []rbacv1.RoleBinding {
+		roleBindings := []rbacv1.RoleBinding{}
+		for _, namespace := range namespaces {
+			roleBindings = append(roleBindings, rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rbac-config-devs-edit",
+					Namespace: namespace,
+				},
+				RoleRef: rbacv1.RoleRef{
+					Kind: "ClusterRole",
+					Name: "edit",
+				},
+				Subjects: []rbacv1.Subject{{
+					Kind: rbacv1.UserKind,
+					Name: "joe",
+				}},
+			})
+		}
+		return roleBindings
+	}
+
+	tests := []struct {
+		requirement rbacmanagerv1beta1.AnnotationRequirement
+		namespaces  []string
+	}{{
+		requirement: rbacmanagerv1beta1.AnnotationRequirement{Key: "tier", Operator: "In", Values: []string{"gold", "platinum"}},
+		namespaces:  []string{"gold"},
+	}, {
+		requirement: rbacmanagerv1beta1.AnnotationRequirement{Key: "tier", Operator: "NotIn", Values: []string{"gold", "platinum"}},
+		namespaces:  []string{"none", "silver"},
+	}, {
+		requirement: rbacmanagerv1beta1.AnnotationRequirement{Key: "tier", Operator: "Exists"},
+		namespaces:  []string{"gold", "silver"},
+	}}
+
+	for _, test := range tests {
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name: "devs",
+			Subjects: []rbacv1.Subject{{
+				Kind: rbacv1.UserKind,
+				Name: "joe",
+			}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				NamespaceSelector:    metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+				ClusterRole:          "edit",
+				AnnotationExpression: []rbacmanagerv1beta1.AnnotationRequirement{test.requirement},
+			}},
+		}}
+
+		newParseTest(t, client, rbacDef, expectedRoleBindings(test.namespaces...), []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+	}
+
+	invalid := []rbacmanagerv1beta1.AnnotationRequirement{
+		{Key: "tier", Operator: "Matches", Values: []string{"gold"}},
+		{Key: "tier", Operator: "In"},
+		{Key: "tier", Operator: "Exists", Values: []string{"gold"}},
+	}
+
+	for _, requirement := range invalid {
+		rbacDef.RBACBindings[0].RoleBindings[0].AnnotationExpression = []rbacmanagerv1beta1.AnnotationRequirement{requirement}
+
+		p := Parser{Clientset: client}
+		err := p.Parse(rbacDef)
+		assert.Error(t, err, "Expected error for %v annotation expression", requirement.Operator)
+	}
+
+	// annotation expressions are only evaluated against namespace selectors
+	rbacDef.RBACBindings[0].RoleBindings[0] = rbacmanagerv1beta1.RoleBinding{
+		Namespace:            "gold",
+		ClusterRole:          "edit",
+		AnnotationExpression: []rbacmanagerv1beta1.AnnotationRequirement{tests[0].requirement},
+	}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for annotation expression without a namespace selector")
+}
+
+func TestParseNamespaceOwner(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	owners := map[string][]metav1.OwnerReference{
+		"owned":       {{APIVersion: "v1", Kind: "Tenant", Name: "acme", UID: "acme-uid"}},
+		"other-owner": {{APIVersion: "v1", Kind: "Tenant", Name: "globex", UID: "globex-uid"}},
+		"other-kind":  {{APIVersion: "v1", Kind: "Project", Name: "acme", UID: "project-uid"}},
+		"unowned":     nil,
+	}
+
+	for name, ownerRefs := range owners {
+		_, err := client.CoreV1().Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          map[string]string{"team": "devs"},
+			OwnerReferences: ownerRefs,
+		}})
+		if err != nil {
+			t.Fatalf("Error creating namespace %v", err)
+		}
+	}
+
+	expectedRoleBindings := func(namespaces ...string) []rbacv1.RoleBinding {
+		roleBindings := []rbacv1.RoleBinding{}
+		for _, namespace := range namespaces {
+			roleBindings = append(roleBindings, rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rbac-config-devs-edit",
+					Namespace: namespace,
+				},
+				RoleRef: rbacv1.RoleRef{
+					Kind: "ClusterRole",
+					Name: "edit",
+				},
+				Subjects: []rbacv1.Subject{{
+					Kind: rbacv1.UserKind,
+					Name: "joe",
+				}},
+			})
+		}
+		return roleBindings
+	}
+
+	tests := []struct {
+		owner      rbacmanagerv1beta1.NamespaceOwner
+		namespaces []string
+	}{{
+		owner:      rbacmanagerv1beta1.NamespaceOwner{Kind: "Tenant", UID: "acme-uid"},
+		namespaces: []string{"owned"},
+	}, {
+		owner:      rbacmanagerv1beta1.NamespaceOwner{Kind: "Tenant"},
+		namespaces: []string{"owned", "other-owner"},
+	}, {
+		owner:      rbacmanagerv1beta1.NamespaceOwner{UID: "project-uid"},
+		namespaces: []string{"other-kind"},
+	}, {
+		owner:      rbacmanagerv1beta1.NamespaceOwner{Kind: "Project", UID: "acme-uid"},
+		namespaces: []string{},
+	}}
+
+	for _, test := range tests {
+		owner := test.owner
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name: "devs",
+			Subjects: []rbacv1.Subject{{
+				Kind: rbacv1.UserKind,
+				Name: "joe",
+			}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+				ClusterRole:       "edit",
+				NamespaceOwner:    &owner,
+			}},
+		}}
+
+		newParseTest(t, client, rbacDef, expectedRoleBindings(test.namespaces...), []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+	}
+
+	// an owner without a kind or uid would match every owned namespace
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceOwner = &rbacmanagerv1beta1.NamespaceOwner{}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for empty namespace owner")
+
+	// namespace owners are only evaluated against namespace selectors
+	rbacDef.RBACBindings[0].RoleBindings[0] = rbacmanagerv1beta1.RoleBinding{
+		Namespace:      "owned",
+		ClusterRole:    "edit",
+		NamespaceOwner: &tests[0].owner,
+	}
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for namespace owner without a namespace selector")
+}
+
+func TestParseNamespaceList(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+	createNamespace(t, client, "db", map[string]string{"team": "db"})
+
+	expectedRoleBindings := func(namespaces ...string) []rbacv1.RoleBinding {
+		roleBindings := []rbacv1.RoleBinding{}
+		for _, namespace := range namespaces {
+			roleBindings = append(roleBindings, rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rbac-config-devs-edit",
+					Namespace: namespace,
+				},
+				RoleRef: rbacv1.RoleRef{
+					Kind: "ClusterRole",
+					Name: "edit",
+				},
+				Subjects: []rbacv1.Subject{{
+					Kind: rbacv1.UserKind,
+					Name: "joe",
+				}},
+			})
+		}
+		return roleBindings
+	}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespaces:  []string{"db", "staging"},
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, expectedRoleBindings("db", "staging"), []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	// listed namespaces are added to those matched by the selector, api is
+	// matched by both and only gets one Role Binding
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceSelector = metav1.LabelSelector{
+		MatchLabels: map[string]string{"team": "devs"},
+	}
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespaces = []string{"api", "db", "staging", "db"}
+
+	newParseTest(t, client, rbacDef, expectedRoleBindings("web", "api", "db", "staging"), []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	// listed namespaces are covered, so catch-all Role Bindings skip them
+	rbacDef.RBACBindings[0].RoleBindings = append(rbacDef.RBACBindings[0].RoleBindings, rbacmanagerv1beta1.RoleBinding{
+		ClusterRole: "view",
+		CatchAll:    true,
+	})
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	for _, rb := range p.parsedRoleBindings {
+		if rb.RoleRef.Name == "view" {
+			t.Fatalf("Unexpected catch-all Role Binding in namespace %v", rb.Namespace)
+		}
+	}
+
+	rbacDef.RBACBindings[0].RoleBindings[1].Namespaces = []string{"db"}
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for catch-all Role Binding with listed namespaces")
+}
+
+func TestParseRequireRolePresent(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"team": "devs"})
+
+	for _, role := range []rbacv1.Role{{
+		ObjectMeta: metav1.ObjectMeta{Name: "reader-web", Namespace: "web"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "api"},
+	}} {
+		_, err := client.RbacV1().Roles(role.Namespace).Create(&role)
+		if err != nil {
+			t.Fatalf("Error creating role %v", err)
+		}
+	}
+
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{joe},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Role:               "reader-{{.Namespace}}",
+			NamespaceSelector:  metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			RequireRolePresent: true,
+		}, {
+			Role:               "deployer",
+			Namespaces:         []string{"web", "api"},
+			RequireRolePresent: true,
+		}},
+	}}
+
+	// reader-api and the deployer Role in web don't exist
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-reader-web-web",
+			Namespace: "web",
+		},
+		RoleRef:  rbacv1.RoleRef{Kind: "Role", Name: "reader-web"},
+		Subjects: []rbacv1.Subject{joe},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-deployer-api",
+			Namespace: "api",
+		},
+		RoleRef:  rbacv1.RoleRef{Kind: "Role", Name: "deployer"},
+		Subjects: []rbacv1.Subject{joe},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	// Role Bindings are generated regardless of missing Roles by default
+	rbacDef.RBACBindings[0].RoleBindings[0].RequireRolePresent = false
+	rbacDef.RBACBindings[0].RoleBindings[1].RequireRolePresent = false
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, p.parsedRoleBindings, 4)
+
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		ClusterRole:        "edit",
+		Namespace:          "web",
+		RequireRolePresent: true,
+	}}
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for requireRolePresent with a Cluster Role")
+}
+
+func TestParseCreateServiceAccounts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	_, err := client.CoreV1().ServiceAccounts("bots").Create(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"},
+	})
+	if err != nil {
+		t.Fatalf("Error creating service account %v", err)
+	}
+
+	createServiceAccounts := false
+	ciBot := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                  "bots",
+		CreateServiceAccounts: &createServiceAccounts,
+		Subjects:              []rbacv1.Subject{ciBot},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	// existing Service Accounts are bound without being generated
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-bots-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{ciBot},
+	}}, []corev1.ServiceAccount{})
+
+	// a typo in the name of a Service Account that isn't generated is an error
+	rbacDef.RBACBindings[0].Subjects = []rbacv1.Subject{ciBot, {
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bto",
+		Namespace: "bots",
+	}}
+
+	p := Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	assert.EqualError(t, err, "Service Account bots/ci-bto does not exist, RBAC Binding bots does not create Service Accounts")
+
+	// missing Service Accounts are generated by default
+	rbacDef.RBACBindings[0].CreateServiceAccounts = nil
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, p.parsedServiceAccounts, 2)
+}
+
+func TestParseSubjectNamePatterns(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	patterns := map[string]*regexp.Regexp{
+		rbacv1.UserKind:           regexp.MustCompile(`^[a-z]+\.[a-z]+$`),
+		rbacv1.GroupKind:          regexp.MustCompile(`^team-`),
+		rbacv1.ServiceAccountKind: regexp.MustCompile(`-bot$`),
+	}
+
+	tests := []struct {
+		subject rbacv1.Subject
+		valid   bool
+	}{
+		{rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane.doe"}, true},
+		{rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane"}, false},
+		{rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane.doe@example.com"}, false},
+		{rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-web"}, true},
+		{rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "web-team"}, false},
+		{rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"}, true},
+		{rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}, false},
+	}
+
+	for _, test := range tests {
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: []rbacv1.Subject{test.subject},
+			ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+				ClusterRole: "view",
+			}},
+		}}
+
+		p := Parser{Clientset: client, SubjectNamePatterns: patterns}
+		err := p.Parse(rbacDef)
+		if test.valid {
+			assert.NoError(t, err, "Expected %v %v to be valid", test.subject.Kind, test.subject.Name)
+		} else {
+			assert.Error(t, err, "Expected %v %v to be invalid", test.subject.Kind, test.subject.Name)
+		}
+	}
+
+	// every violation is reported, kinds without a pattern aren't checked
+	rbacDef.RBACBindings[0].Subjects = []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, Name: "jane"},
+		{Kind: rbacv1.GroupKind, Name: "web-team"},
+		{Kind: rbacv1.GroupKind, Name: "team-web"},
+	}
+
+	p := Parser{Clientset: client, SubjectNamePatterns: patterns}
+	err := p.Parse(rbacDef)
+	assert.EqualError(t, err, `Invalid subject names: User jane does not match ^[a-z]+\.[a-z]+$, Group web-team does not match ^team-`)
+
+	delete(patterns, rbacv1.UserKind)
+	rbacDef.RBACBindings[0].Subjects = rbacDef.RBACBindings[0].Subjects[:1]
+
+	p = Parser{Clientset: client, SubjectNamePatterns: patterns}
+	assert.NoError(t, p.Parse(rbacDef))
+
+	// subject overrides are checked as well
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		ClusterRole: "edit",
+		Namespace:   "web",
+		SubjectOverrides: map[string][]rbacv1.Subject{
+			"web": {{Kind: rbacv1.GroupKind, Name: "web-admins"}},
+		},
+	}}
+
+	p = Parser{Clientset: client, SubjectNamePatterns: patterns}
+	assert.Error(t, p.Parse(rbacDef))
+}
+
+func TestParseDisallowedSubjectKinds(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	disallowed := map[string]bool{rbacv1.UserKind: true}
+
+	tests := []struct {
+		subject rbacv1.Subject
+		valid   bool
+	}{
+		{rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}, false},
+		{rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "devs"}, true},
+		{rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}, true},
+	}
+
+	for _, test := range tests {
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: []rbacv1.Subject{test.subject},
+			ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+				ClusterRole: "view",
+			}},
+		}}
+
+		p := Parser{Clientset: client, DisallowedSubjectKinds: disallowed}
+		err := p.Parse(rbacDef)
+		if test.valid {
+			assert.NoError(t, err, "Expected %v %v to be allowed", test.subject.Kind, test.subject.Name)
+		} else {
+			assert.Error(t, err, "Expected %v %v to be disallowed", test.subject.Kind, test.subject.Name)
+		}
+	}
+
+	rbacDef.RBACBindings[0].Subjects = []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, Name: "devs"},
+		{Kind: rbacv1.UserKind, Name: "joe"},
+		{Kind: rbacv1.UserKind, Name: "sue"},
+	}
+
+	p := Parser{Clientset: client, DisallowedSubjectKinds: disallowed}
+	err := p.Parse(rbacDef)
+	assert.EqualError(t, err, "Disallowed subject kinds: User joe, User sue")
+
+	// subject overrides are checked as well
+	rbacDef.RBACBindings[0].Subjects = rbacDef.RBACBindings[0].Subjects[:1]
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		ClusterRole: "edit",
+		Namespace:   "web",
+		SubjectOverrides: map[string][]rbacv1.Subject{
+			"web": {{Kind: rbacv1.UserKind, Name: "joe"}},
+		},
+	}}
+
+	p = Parser{Clientset: client, DisallowedSubjectKinds: disallowed}
+	assert.EqualError(t, p.Parse(rbacDef), "Disallowed subject kinds: User joe")
+
+	p = Parser{Clientset: client}
+	assert.NoError(t, p.Parse(rbacDef))
+}
+
+func TestParseSourceRevision(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, SourceRevision: "3f2c1e9", CreateTokenSecret: true}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, "3f2c1e9", p.parsedServiceAccounts[0].Annotations[SourceRevisionAnnotation])
+	assert.Equal(t, "3f2c1e9", p.parsedSecrets[0].Annotations[SourceRevisionAnnotation])
+	assert.Equal(t, "3f2c1e9", p.parsedClusterRoleBindings[0].Annotations[SourceRevisionAnnotation])
+	assert.Equal(t, "3f2c1e9", p.parsedRoleBindings[0].Annotations[SourceRevisionAnnotation])
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.NotContains(t, p.parsedServiceAccounts[0].Annotations, SourceRevisionAnnotation)
+	assert.NotContains(t, p.parsedClusterRoleBindings[0].Annotations, SourceRevisionAnnotation)
+	assert.NotContains(t, p.parsedRoleBindings[0].Annotations, SourceRevisionAnnotation)
+}
+
+func TestParseBindingFinalizers(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}, {
+			Namespace:   "api",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	finalizers := []string{"example.com/audit"}
+	p := Parser{Clientset: client, BindingFinalizers: finalizers}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, finalizers, p.parsedClusterRoleBindings[0].Finalizers)
+	assert.Len(t, p.parsedRoleBindings, 2)
+	for _, rb := range p.parsedRoleBindings {
+		assert.Equal(t, finalizers, rb.Finalizers)
+	}
+	assert.Empty(t, p.parsedServiceAccounts[0].Finalizers)
+
+	// each binding gets its own copy
+	p.parsedRoleBindings[0].Finalizers[0] = "changed"
+	assert.Equal(t, "example.com/audit", p.parsedRoleBindings[1].Finalizers[0])
+	assert.Equal(t, "example.com/audit", finalizers[0])
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Empty(t, p.parsedClusterRoleBindings[0].Finalizers)
+	assert.Empty(t, p.parsedRoleBindings[0].Finalizers)
+}
+
+func TestParsePerLabelValue(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"quota-tier": "gold", "team": "devs"})
+	createNamespace(t, client, "api", map[string]string{"quota-tier": "silver", "team": "devs"})
+	createNamespace(t, client, "db", map[string]string{"quota-tier": "bronze", "team": "devs"})
+	createNamespace(t, client, "ops", map[string]string{"quota-tier": "gold", "team": "ops"})
+	createNamespace(t, client, "tools", map[string]string{"team": "devs"})
+
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{joe},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "devs"}},
+			PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{
+				Label: "quota-tier",
+				ClusterRoles: map[string]string{
+					"gold":   "admin",
+					"silver": "edit",
+				},
+			},
+		}},
+	}}
+
+	// bronze isn't mapped to a Cluster Role, tools has no tier, and ops
+	// isn't matched by the selector
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-admin",
+			Namespace: "web",
+		},
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"},
+		Subjects: []rbacv1.Subject{joe},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-edit",
+			Namespace: "api",
+		},
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+		Subjects: []rbacv1.Subject{joe},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	p := Parser{Clientset: client}
+	assert.True(t, p.HasNamespaceSelectors(&rbacDef))
+
+	invalid := []rbacmanagerv1beta1.RoleBinding{{
+		PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{ClusterRoles: map[string]string{"gold": "admin"}},
+	}, {
+		PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{Label: "quota-tier"},
+	}, {
+		ClusterRole:   "view",
+		PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{Label: "quota-tier", ClusterRoles: map[string]string{"gold": "admin"}},
+	}, {
+		Namespace:     "web",
+		PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{Label: "quota-tier", ClusterRoles: map[string]string{"gold": "admin"}},
+	}, {
+		PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{Label: "quota-tier", ClusterRoles: map[string]string{"gold": ""}},
+	}}
+
+	for _, rb := range invalid {
+		rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{rb}
+
+		p = Parser{Clientset: client}
+		err := p.Parse(rbacDef)
+		assert.Error(t, err, "Expected error for %v", rb)
+	}
+}
+
+func TestParseAggregatedClusterRoles(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	parser := Parser{Clientset: client, ResolveAggregatedClusterRoles: true}
+
+	aggregate := rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "monitoring"},
+		AggregationRule: &rbacv1.AggregationRule{
+			ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"aggregate-to-monitoring": "true"}},
+				{MatchLabels: map[string]string{"team": "observability"}},
+			},
+		},
+	}
+	roles := []rbacv1.ClusterRole{
+		aggregate,
+		{ObjectMeta: metav1.ObjectMeta{Name: "prometheus", Labels: map[string]string{"aggregate-to-monitoring": "true", "team": "observability"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "grafana", Labels: map[string]string{"team": "observability"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Labels: map[string]string{"team": "platform"}}},
+	}
+	for i := range roles {
+		_, err := client.RbacV1().ClusterRoles().Create(&roles[i])
+		assert.Nil(t, err)
+	}
+
+	aggregated, err := parser.aggregatedClusterRoles("monitoring")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"grafana", "prometheus"}, aggregated)
+
+	aggregated, err = parser.aggregatedClusterRoles("grafana")
+	assert.Nil(t, err)
+	assert.Nil(t, aggregated)
+
+	aggregated, err = parser.aggregatedClusterRoles("missing")
+	assert.Nil(t, err)
+	assert.Nil(t, aggregated)
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "monitoring",
+		Subjects: []rbacv1.Subject{
+			{Kind: "User", Name: "joe"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{
+			{ClusterRole: "monitoring"},
+		},
+	}}
+
+	err = parser.parse(rbacDef)
+	assert.Nil(t, err)
+	assert.Len(t, parser.parsedClusterRoleBindings, 1)
+	assert.Equal(t, "monitoring", parser.parsedClusterRoleBindings[0].RoleRef.Name)
+}
+
+// fieldSelectorNamespaceLister filters namespaces by field selector, which
+// the fake clientset ignores
+type fieldSelectorNamespaceLister struct {
+	namespaces []corev1.Namespace
+}
+
+func (l *fieldSelectorNamespaceLister) ListNamespaces(listOptions metav1.ListOptions) ([]corev1.Namespace, error) {
+	labelSelector, err := labels.Parse(listOptions.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldSelector, err := fields.ParseSelector(listOptions.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []corev1.Namespace{}
+	for _, namespace := range l.namespaces {
+		namespaceFields := fields.Set{"metadata.name": namespace.Name, "status.phase": string(namespace.Status.Phase)}
+		if labelSelector.Matches(labels.Set(namespace.Labels)) && fieldSelector.Matches(namespaceFields) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+func TestParseNamespaceFieldSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	lister := &fieldSelectorNamespaceLister{}
+	phases := map[string]corev1.NamespacePhase{
+		"web":      corev1.NamespaceActive,
+		"api":      corev1.NamespaceActive,
+		"old":      corev1.NamespaceTerminating,
+		"platform": corev1.NamespaceActive,
+	}
+	for _, name := range []string{"api", "old", "platform", "web"} {
+		team := "dev"
+		if name == "platform" {
+			team = "ops"
+		}
+		lister.namespaces = append(lister.namespaces, corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}},
+			Status:     corev1.NamespaceStatus{Phase: phases[name]},
+		})
+	}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+			},
+			NamespaceFieldSelector: "status.phase=Active",
+		}},
+	}}
+
+	p := Parser{Clientset: client, NamespaceLister: lister}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.Equal(t, []string{"api", "web"}, namespaces)
+
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceFieldSelector = "status.phase"
+	p = Parser{Clientset: client, NamespaceLister: lister}
+	err = p.Parse(rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Invalid role binding, namespaceFieldSelector status.phase")
+	}
+
+	rbacDef.RBACBindings[0].RoleBindings[0] = rbacmanagerv1beta1.RoleBinding{
+		ClusterRole:            "edit",
+		Namespace:              "web",
+		NamespaceFieldSelector: "status.phase=Active",
+	}
+	p = Parser{Clientset: client, NamespaceLister: lister}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid role binding, namespaceFieldSelector requires a namespace selector")
+}
+
+func TestParseCompactServiceAccountNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespace: "bots"},
+			{Role: "deployer", Namespace: "bots"},
+			{ClusterRole: "view", Namespace: "web"},
+		},
+	}, {
+		Name: "devs",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"},
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespace: "bots"},
+		},
+	}}
+
+	names := func(p Parser) []string {
+		names := []string{}
+		for _, rb := range p.parsedRoleBindings {
+			names = append(names, rb.Namespace+"/"+rb.Name)
+		}
+		return names
+	}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, []string{
+		"bots/rbac-config-ci-edit",
+		"bots/rbac-config-ci-deployer-bots",
+		"web/rbac-config-ci-view",
+		"bots/rbac-config-devs-edit",
+	}, names(p))
+
+	// only bindings for a single Service Account in its own namespace are
+	// shortened
+	p = Parser{Clientset: client, CompactServiceAccountNames: true}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, []string{
+		"bots/ci-bot:clusterrole:edit:4321ff6e",
+		"bots/ci-bot:role:deployer:4321ff6e",
+		"web/rbac-config-ci-view",
+		"bots/rbac-config-devs-edit",
+	}, names(p))
+
+	// another RBAC Definition binding the same Service Account to the same
+	// role gets a different name
+	otherDef := rbacmanagerv1beta1.RBACDefinition{}
+	otherDef.Name = "other-config"
+	otherDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{rbacDef.RBACBindings[0]}
+
+	err = p.Parse(otherDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, []string{
+		"bots/ci-bot:clusterrole:edit:6e937b86",
+		"bots/ci-bot:role:deployer:6e937b86",
+		"web/other-config-ci-view",
+	}, names(p))
+}
+
+func TestParseRoleAliases(t *testing.T) {
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-viewer"},
+	})
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{
+			{ClusterRole: "readonly"},
+			{ClusterRole: "custom-viewer"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "admin", Namespace: "web"},
+		},
+	}}
+
+	aliases := map[string]string{
+		"readonly": "view",
+		"admin":    "tenant-admin",
+	}
+
+	p := Parser{Clientset: client, RoleAliases: aliases}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Len(t, p.parsedClusterRoleBindings, 2)
+	assert.Equal(t, "rbac-config-devs-view", p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, "view", p.parsedClusterRoleBindings[0].RoleRef.Name)
+	assert.Equal(t, "custom-viewer", p.parsedClusterRoleBindings[1].RoleRef.Name)
+
+	assert.Len(t, p.parsedRoleBindings, 1)
+	assert.Equal(t, "rbac-config-devs-tenant-admin", p.parsedRoleBindings[0].Name)
+	assert.Equal(t, "tenant-admin", p.parsedRoleBindings[0].RoleRef.Name)
+
+	// with validation, names must be an alias or an existing Cluster Role
+	p = Parser{Clientset: client, RoleAliases: aliases, ValidateRoleAliases: true}
+	assert.NoError(t, p.Parse(rbacDef))
+
+	rbacDef.RBACBindings[0].ClusterRoleBindings[1].ClusterRole = "readonyl"
+	p = Parser{Clientset: client, RoleAliases: aliases, ValidateRoleAliases: true}
+	assert.EqualError(t, p.Parse(rbacDef), "Unknown role alias or Cluster Role readonyl")
+
+	p = Parser{Clientset: client, RoleAliases: aliases}
+	assert.NoError(t, p.Parse(rbacDef))
+	assert.Equal(t, "readonyl", p.parsedClusterRoleBindings[1].RoleRef.Name)
+}
+
+func TestParseNamespaceExcludeSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	namespaces := map[string]map[string]string{
+		"include-only": {"team": "dev"},
+		"both":         {"team": "dev", "frozen": "true"},
+		"exclude-only": {"frozen": "true"},
+		"neither":      {},
+	}
+	for name, namespaceLabels := range namespaces {
+		createNamespace(t, client, name, namespaceLabels)
+	}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+			},
+			NamespaceExcludeSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"frozen": "true"},
+			},
+		}},
+	}}
+
+	parsedNamespaces := func(p Parser) []string {
+		names := []string{}
+		for _, rb := range p.parsedRoleBindings {
+			names = append(names, rb.Namespace)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []string{"include-only"}, parsedNamespaces(p))
+	assert.True(t, p.HasNamespaceSelectors(&rbacDef))
+
+	// an exclude selector on its own selects every other namespace
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceSelector = metav1.LabelSelector{}
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceExcludeSelector = metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "frozen",
+			Operator: metav1.LabelSelectorOpExists,
+		}},
+	}
+
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []string{"include-only", "neither"}, parsedNamespaces(p))
+	assert.True(t, p.HasNamespaceSelectors(&rbacDef))
+
+	rbacDef.RBACBindings[0].RoleBindings[0].NamespaceExcludeSelector.MatchExpressions[0].Operator = "Bogus"
+	p = Parser{Clientset: client}
+	assert.Error(t, p.Parse(rbacDef))
+}
+
+func TestParseCollapseNamespaceBindings(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespaces: []string{"web", "api"}},
+		},
+	}, {
+		Name: "oncall",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.GroupKind, Name: "oncall"},
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespace: "web"},
+			{ClusterRole: "view", Namespace: "web"},
+		},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, p.parsedRoleBindings, 4)
+
+	p = Parser{Clientset: client, CollapseNamespaceBindings: true}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expectedNames := []string{"rbac-config-devs-edit", "rbac-config-devs-edit", "rbac-config-oncall-view"}
+	expectedNamespaces := []string{"web", "api", "web"}
+	if !assert.Len(t, p.parsedRoleBindings, len(expectedNames)) {
+		return
+	}
+
+	for i, rb := range p.parsedRoleBindings {
+		assert.Equal(t, expectedNames[i], rb.Name)
+		assert.Equal(t, expectedNamespaces[i], rb.Namespace)
+	}
+
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, Name: "devs"},
+		{Kind: rbacv1.GroupKind, Name: "oncall"},
+	}, p.parsedRoleBindings[0].Subjects)
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}}, p.parsedRoleBindings[1].Subjects)
+}
+
+func TestParseTokenSecrets(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"},
+			{Kind: rbacv1.UserKind, Name: "joe"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}, {
+		Name:         "ci-edit",
+		Subjects:     []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{ClusterRole: "edit", Namespace: "bots"}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Empty(t, p.parsedSecrets)
+
+	p = Parser{Clientset: client, CreateTokenSecret: true}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// a Service Account in more than one RBAC Binding only gets one Secret
+	if !assert.Len(t, p.parsedSecrets, 1) {
+		return
+	}
+
+	secret := p.parsedSecrets[0]
+	assert.Equal(t, "ci-bot-token", secret.Name)
+	assert.Equal(t, "bots", secret.Namespace)
+	assert.Equal(t, corev1.SecretTypeServiceAccountToken, secret.Type)
+	assert.Equal(t, "ci-bot", secret.Annotations[corev1.ServiceAccountNameKey])
+	assert.Equal(t, LabelValue, secret.Labels[LabelKey])
+}
+
+func TestParseDefaultSubjectAPIGroups(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, Name: "joe"},
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+			{Kind: rbacv1.UserKind, Name: "sue", APIGroup: rbacv1.GroupName},
+			{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	apiGroups := func(p Parser) map[string]string {
+		groups := map[string]string{}
+		for _, subject := range p.parsedClusterRoleBindings[0].Subjects {
+			groups[subject.Name] = subject.APIGroup
+		}
+		return groups
+	}
+
+	// subjects are left for the API server to default to the RBAC API group
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, map[string]string{
+		"joe":    "",
+		"devs":   "",
+		"sue":    rbacv1.GroupName,
+		"ci-bot": "",
+	}, apiGroups(p))
+
+	p = Parser{
+		Clientset:            client,
+		DefaultUserAPIGroup:  "users.example.com",
+		DefaultGroupAPIGroup: "groups.example.com",
+	}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, map[string]string{
+		"joe":    "users.example.com",
+		"devs":   "groups.example.com",
+		"sue":    rbacv1.GroupName,
+		"ci-bot": "",
+	}, apiGroups(p))
+}
+
+func TestParseNamespaceGlobs(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	for _, name := range []string{"team-a", "team-b", "web"} {
+		createNamespace(t, client, name, nil)
+	}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespace: "team-*"},
+			{ClusterRole: "view", Namespace: "web"},
+		},
+	}}
+
+	parsedNamespaces := func(p Parser) []string {
+		names := []string{}
+		for _, rb := range p.parsedRoleBindings {
+			names = append(names, rb.RoleRef.Name+"/"+rb.Namespace)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	p := Parser{Clientset: client, ExpandNamespaceGlobs: true}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, []string{"edit/team-a", "edit/team-b", "view/web"}, parsedNamespaces(p))
+	assert.True(t, p.HasNamespaceSelectors(&rbacDef))
+
+	// without glob expansion the namespace isn't a valid namespace name
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid namespace team-*, namespaces must be valid DNS labels")
+	assert.False(t, p.HasNamespaceSelectors(&rbacDef))
+
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespace = "team-[*"
+	p = Parser{Clientset: client, ExpandNamespaceGlobs: true}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid namespace pattern team-[*: syntax error in pattern")
+}
+
+func TestParseSelectorMatches(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "web", map[string]string{"team": "dev"})
+	createNamespace(t, client, "api", map[string]string{"team": "dev", "frozen": "true"})
+	createNamespace(t, client, "db", map[string]string{"team": "dev"})
+	createNamespace(t, client, "kube-system", map[string]string{})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+			},
+			NamespaceExcludeSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"frozen": "true"},
+			},
+		}, {
+			ClusterRole: "view",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "ops"},
+			},
+		}, {
+			ClusterRole: "view",
+			Namespace:   "web",
+		}},
+	}, {
+		Name:     "support",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "support"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "view",
+			CatchAll:    true,
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, []rbacmanagerv1beta1.SelectorMatch{
+		{RBACBinding: "devs", Role: "edit", Selector: "team=dev", Namespaces: 2},
+		{RBACBinding: "devs", Role: "view", Selector: "team=ops", Namespaces: 0},
+		{RBACBinding: "support", Role: "view", Selector: "<none>", Namespaces: 2},
+	}, p.SelectorMatches())
+}
+
+func TestParseRoleLabelRules(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{
+			{ClusterRole: "cluster-admin"},
+			{ClusterRole: "view"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "admin", Namespace: "web"},
+			{ClusterRole: "edit", Namespace: "web"},
+		},
+	}}
+
+	p := Parser{
+		Clientset: client,
+		RoleLabelRules: []RoleLabelRule{{
+			Pattern: regexp.MustCompile("admin"),
+			Labels:  map[string]string{"sensitivity": "high"},
+		}, {
+			Pattern: regexp.MustCompile("^cluster-"),
+			Labels:  map[string]string{"sensitivity": "critical", "scope": "cluster"},
+		}},
+	}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	bindingLabels := map[string]map[string]string{}
+	for _, crb := range p.parsedClusterRoleBindings {
+		bindingLabels[crb.RoleRef.Name] = crb.Labels
+	}
+	for _, rb := range p.parsedRoleBindings {
+		bindingLabels[rb.RoleRef.Name] = rb.Labels
+	}
+
+	assert.Equal(t, map[string]string{LabelKey: LabelValue, "sensitivity": "critical", "scope": "cluster"}, bindingLabels["cluster-admin"])
+	assert.Equal(t, map[string]string{LabelKey: LabelValue, "sensitivity": "high"}, bindingLabels["admin"])
+	assert.Equal(t, map[string]string{LabelKey: LabelValue}, bindingLabels["view"])
+	assert.Equal(t, map[string]string{LabelKey: LabelValue}, bindingLabels["edit"])
+
+	// the shared default labels are left unchanged
+	assert.Equal(t, map[string]string{LabelKey: LabelValue}, Labels)
+}
+
+func TestParseRoleLabelRulesFlag(t *testing.T) {
+	rules, err := ParseRoleLabelRules("admin:sensitivity=high; ^cluster-:sensitivity=critical,scope=cluster;")
+	if err != nil {
+		t.Fatalf("Error parsing role label rules: %v", err)
+	}
+
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "admin", rules[0].Pattern.String())
+		assert.Equal(t, map[string]string{"sensitivity": "high"}, rules[0].Labels)
+		assert.Equal(t, "^cluster-", rules[1].Pattern.String())
+		assert.Equal(t, map[string]string{"sensitivity": "critical", "scope": "cluster"}, rules[1].Labels)
+	}
+
+	// patterns can contain colons
+	rules, err = ParseRoleLabelRules("^system:auth:scope=system")
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, "^system:auth", rules[0].Pattern.String())
+	}
+
+	rules, err = ParseRoleLabelRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, invalid := range []string{"admin", "admin:sensitivity", "(:scope=cluster", "admin:=high"} {
+		_, err = ParseRoleLabelRules(invalid)
+		assert.Error(t, err, "Expected error for %v", invalid)
+	}
+}
+
+func TestParseSubjectKindsFlag(t *testing.T) {
+	kinds, err := ParseSubjectKinds("user, serviceaccount,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{rbacv1.UserKind: true, rbacv1.ServiceAccountKind: true}, kinds)
+
+	kinds, err = ParseSubjectKinds("")
+	assert.NoError(t, err)
+	assert.Empty(t, kinds)
+
+	_, err = ParseSubjectKinds("User,Robot")
+	assert.EqualError(t, err, "Invalid subject kind Robot, must be User, Group, or ServiceAccount")
+}
+
+func TestParseSubjectNamePatternsFlag(t *testing.T) {
+	patterns, err := ParseSubjectNamePatterns("user=^[a-z]+\\.[a-z]+$; ServiceAccount=^[a-z]{2,}=?$;")
+	if err != nil {
+		t.Fatalf("Error parsing subject name patterns: %v", err)
+	}
+
+	if assert.Len(t, patterns, 2) {
+		assert.Equal(t, "^[a-z]+\\.[a-z]+$", patterns[rbacv1.UserKind].String())
+		assert.Equal(t, "^[a-z]{2,}=?$", patterns[rbacv1.ServiceAccountKind].String())
+	}
+
+	patterns, err = ParseSubjectNamePatterns("")
+	assert.NoError(t, err)
+	assert.Empty(t, patterns)
+
+	for _, invalid := range []string{"User", "Robot=^r", "User=("} {
+		_, err = ParseSubjectNamePatterns(invalid)
+		assert.Error(t, err, "Expected error for %v", invalid)
+	}
+}
+
+func TestParseRoleAliasesFlag(t *testing.T) {
+	aliases, err := ParseRoleAliases("reader=view, writer = edit,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"reader": "view", "writer": "edit"}, aliases)
+
+	aliases, err = ParseRoleAliases("")
+	assert.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	for _, invalid := range []string{"reader", "reader=", "=view"} {
+		_, err = ParseRoleAliases(invalid)
+		assert.Error(t, err, "Expected error for %v", invalid)
+	}
+}
+
+func TestParseResourceTypesFlag(t *testing.T) {
+	types, err := ParseResourceTypes("RoleBinding, ServiceAccount,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{ResourceTypeRoleBinding: true, ResourceTypeServiceAccount: true}, types)
+
+	types, err = ParseResourceTypes("")
+	assert.NoError(t, err)
+	assert.Empty(t, types)
+
+	_, err = ParseResourceTypes("RoleBinding,Role")
+	assert.EqualError(t, err, "Invalid resource type Role, must be ClusterRoleBinding, RoleBinding, or ServiceAccount")
+}
+
+func TestParseRequesterAccess(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviews := []authorizationv1.SubjectAccessReviewSpec{}
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, review.Spec)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Namespace != "kube-system"
+		return true, review, nil
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespaces: []string{"web", "kube-system"}},
+			{ClusterRole: "view", Namespaces: []string{"web", "kube-system"}},
+		},
+	}}
+
+	p := Parser{Clientset: client, Requester: &Requester{User: "joe", Groups: []string{"team-leads"}}}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	namespaces := []string{}
+	for _, rb := range p.parsedRoleBindings {
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.Equal(t, []string{"web", "web"}, namespaces)
+
+	// each namespace is only reviewed once
+	assert.Equal(t, []authorizationv1.SubjectAccessReviewSpec{{
+		User:   "joe",
+		Groups: []string{"team-leads"},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: "web",
+			Verb:      "create",
+			Group:     rbacv1.GroupName,
+			Resource:  "rolebindings",
+		},
+	}, {
+		User:   "joe",
+		Groups: []string{"team-leads"},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: "kube-system",
+			Verb:      "create",
+			Group:     rbacv1.GroupName,
+			Resource:  "rolebindings",
+		},
+	}}, reviews)
+
+	// without a requester no reviews are made
+	reviews = nil
+	p = Parser{Clientset: client}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, p.parsedRoleBindings, 4)
+	assert.Empty(t, reviews)
+}
+
+func TestParseMatrix(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:   "devs",
+		Matrix: true,
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}, {
+			ClusterRole: "edit",
+		}, {
+			ClusterRole: "admin",
+		}},
+	}}
+
+	expectedCrb := []rbacv1.ClusterRoleBinding{}
+	for _, role := range []string{"view", "edit", "admin"} {
+		expectedCrb = append(expectedCrb, rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "rbac-config-devs-user-joe-" + role,
+			},
+			RoleRef: rbacv1.RoleRef{
+				Kind: "ClusterRole",
+				Name: role,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind: rbacv1.UserKind,
+				Name: "joe",
+			}},
+		}, rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "rbac-config-devs-serviceaccount-bots-ci-bot-" + role,
+			},
+			RoleRef: rbacv1.RoleRef{
+				Kind: "ClusterRole",
+				Name: role,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      "ci-bot",
+				Namespace: "bots",
+			}},
+		})
+	}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, expectedCrb, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ci-bot",
+			Namespace: "bots",
+		},
+	}})
+
+	// long subject names are truncated to keep generated names unique and
+	// within length limits
+	longName := strings.Repeat("a", 300)
+	p := Parser{}
+	prefix := p.matrixNamePrefix("rbac-config-devs", rbacv1.Subject{Kind: rbacv1.UserKind, Name: longName})
+	assert.Len(t, prefix, maxMatrixNamePrefixLength)
+	assert.NotEqual(t, prefix, p.matrixNamePrefix("rbac-config-devs", rbacv1.Subject{Kind: rbacv1.UserKind, Name: longName + "b"}))
+}
+
+func TestParseClusterRoles(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRoles: []string{"view", "edit", "monitoring"},
+		}},
+	}}
+
+	expectedCrb := []rbacv1.ClusterRoleBinding{}
+	for _, role := range []string{"view", "edit", "monitoring"} {
+		expectedCrb = append(expectedCrb, rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "rbac-config-devs-" + role,
+			},
+			RoleRef: rbacv1.RoleRef{
+				Kind: "ClusterRole",
+				Name: role,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind: rbacv1.GroupKind,
+				Name: "devs",
+			}},
+		})
+	}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, expectedCrb, []corev1.ServiceAccount{})
+
+	// clusterRole is combined with clusterRoles without duplicates
+	rbacDef.RBACBindings[0].ClusterRoleBindings[0] = rbacmanagerv1beta1.ClusterRoleBinding{
+		ClusterRole:  "view",
+		ClusterRoles: []string{"edit", "monitoring", "view"},
+	}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, expectedCrb, []corev1.ServiceAccount{})
+
+	rbacDef.RBACBindings[0].ClusterRoleBindings[0] = rbacmanagerv1beta1.ClusterRoleBinding{}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error when no cluster roles are specified")
+}
+
+func TestParseSubjectOrder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bot",
+		Namespace: "bots",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}, {
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bot",
+		Namespace: "apps",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind: rbacv1.GroupKind,
+		Name: "devs",
+	}}
+
+	reversed := []rbacv1.Subject{}
+	for i := len(subjects) - 1; i >= 0; i-- {
+		reversed = append(reversed, subjects[i])
+	}
+
+	parse := func(subjects []rbacv1.Subject, sortSubjects bool) Parser {
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: subjects,
+			ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+				ClusterRole: "view",
+			}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				Namespace:   "web",
+				ClusterRole: "edit",
+			}},
+		}}
+
+		p := Parser{Clientset: client, SortSubjects: sortSubjects}
+		err := p.Parse(rbacDef)
+		if err != nil {
+			t.Fatalf("Error parsing RBAC Definition: %v", err)
+		}
+		return p
+	}
+
+	// subjects keep the order of the RBAC Definition by default
+	first := parse(subjects, false)
+	second := parse(reversed, false)
+
+	assert.Equal(t, subjects, first.parsedClusterRoleBindings[0].Subjects)
+	assert.Equal(t, subjects, first.parsedRoleBindings[0].Subjects)
+	assert.Equal(t, reversed, second.parsedClusterRoleBindings[0].Subjects)
+	assert.Equal(t, reversed, second.parsedRoleBindings[0].Subjects)
+
+	first = parse(subjects, true)
+	second = parse(reversed, true)
+
+	assert.Equal(t, first.parsedClusterRoleBindings, second.parsedClusterRoleBindings)
+	assert.Equal(t, first.parsedRoleBindings, second.parsedRoleBindings)
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind: rbacv1.GroupKind,
+		Name: "devs",
+	}, {
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bot",
+		Namespace: "apps",
+	}, {
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bot",
+		Namespace: "bots",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}}, first.parsedRoleBindings[0].Subjects)
+}
+
+func TestParseCatchAll(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "gold", map[string]string{"tier": "gold"})
+	createNamespace(t, client, "silver", map[string]string{"tier": "silver"})
+	createNamespace(t, client, "web", map[string]string{})
+	createNamespace(t, client, "dev", map[string]string{})
+
+	subjects := []rbacv1.Subject{{
+		Kind: rbacv1.GroupKind,
+		Name: "support",
+	}}
+
+	// the catch-all is listed first but only applies to namespaces that
+	// the other Role Bindings don't cover
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "default",
+		Subjects: subjects,
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "view",
+			CatchAll:    true,
+		}},
+	}, {
+		Name:     "gold",
+		Subjects: subjects,
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}},
+			ClusterRole:       "edit",
+		}, {
+			Namespace:   "web",
+			ClusterRole: "admin",
+		}},
+	}}
+
+	expectedRoleBinding := func(name string, namespace string, role string) rbacv1.RoleBinding {
+		return rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			RoleRef: rbacv1.RoleRef{
+				Kind: "ClusterRole",
+				Name: role,
+			},
+			Subjects: subjects,
+		}
+	}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{
+		expectedRoleBinding("rbac-config-gold-edit", "gold", "edit"),
+		expectedRoleBinding("rbac-config-gold-admin", "web", "admin"),
+		expectedRoleBinding("rbac-config-default-view", "silver", "view"),
+		expectedRoleBinding("rbac-config-default-view", "dev", "view"),
+	}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{})
+
+	// a catch-all can't target a specific namespace
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespace = "dev"
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error when combining catchAll with a namespace")
+}
+
+func TestParseUseGenerateName(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, UseGenerateName: true}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Empty(t, p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, "rbac-config-ci-bot-view-", p.parsedClusterRoleBindings[0].GenerateName)
+
+	assert.Len(t, p.parsedRoleBindings, 1)
+	assert.Empty(t, p.parsedRoleBindings[0].Name)
+	assert.Equal(t, "rbac-config-ci-bot-edit-", p.parsedRoleBindings[0].GenerateName)
+
+	// Service Accounts are referenced by name, so they keep fixed names
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Equal(t, "ci-bot", p.parsedServiceAccounts[0].Name)
+	assert.Empty(t, p.parsedServiceAccounts[0].GenerateName)
+}
+
+func TestRoleBindingTarget(t *testing.T) {
+	tests := []struct {
+		rb        rbacmanagerv1beta1.RoleBinding
+		namespace string
+		name      string
+		roleRef   rbacv1.RoleRef
+	}{{
+		rb:        rbacmanagerv1beta1.RoleBinding{ClusterRole: "edit"},
+		namespace: "web",
+		name:      "rbac-config-devs-edit",
+		roleRef:   rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	}, {
+		rb:        rbacmanagerv1beta1.RoleBinding{Role: "custom"},
+		namespace: "web",
+		name:      "rbac-config-devs-custom-web",
+		roleRef:   rbacv1.RoleRef{Kind: "Role", Name: "custom"},
+	}, {
+		rb:        rbacmanagerv1beta1.RoleBinding{Role: "custom"},
+		namespace: "api",
+		name:      "rbac-config-devs-custom-api",
+		roleRef:   rbacv1.RoleRef{Kind: "Role", Name: "custom"},
+	}, {
+		rb:        rbacmanagerv1beta1.RoleBinding{Role: "reader-{{.Namespace}}"},
+		namespace: "api",
+		name:      "rbac-config-devs-reader-api-api",
+		roleRef:   rbacv1.RoleRef{Kind: "Role", Name: "reader-api"},
+	}, {
+		rb:        rbacmanagerv1beta1.RoleBinding{Role: "deployer", RoleRefAPIGroup: "example.com", RoleRefKind: "DeployRole"},
+		namespace: "web",
+		name:      "rbac-config-devs-deployer-web",
+		roleRef:   rbacv1.RoleRef{APIGroup: "example.com", Kind: "DeployRole", Name: "deployer"},
+	}}
+
+	p := Parser{}
+	for _, test := range tests {
+		name, roleRef, err := p.roleBindingTarget(test.rb, "rbac-config-devs", test.namespace)
+		assert.NoError(t, err)
+		assert.Equal(t, test.name, name)
+		assert.Equal(t, test.roleRef, roleRef)
+	}
+}
+
+func TestParseGroupNameMap(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "platform",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "CN=platform,OU=groups,DC=example,DC=com",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "security",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "CN=platform,OU=groups,DC=example,DC=com",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{
+		Clientset:    client,
+		GroupNameMap: map[string]string{"CN=platform,OU=groups,DC=example,DC=com": "platform"},
+	}
+
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expectParsedCRB(t, p, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-platform-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "platform",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "security",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "CN=platform,OU=groups,DC=example,DC=com",
+		}},
+	}})
+
+	// the original definition is left untouched
+	assert.Equal(t, "CN=platform,OU=groups,DC=example,DC=com", rbacDef.RBACBindings[0].Subjects[0].Name)
+}
+
+func TestParseSubjectKindCasing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "mixed",
+		Subjects: []rbacv1.Subject{{
+			Kind:      "serviceaccount",
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}, {
+			Kind: "USER",
+			Name: "joe",
+		}, {
+			Kind: "group",
+			Name: "devs",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{}, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-mixed-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+	}}, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ci-bot",
+			Namespace: "bots",
+		},
+	}})
+
+	rbacDef.RBACBindings[0].Subjects = []rbacv1.Subject{{
+		Kind: "Robot",
+		Name: "joe",
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.Error(t, err, "Expected error for unrecognized subject kind")
+	expectParsedCRB(t, p, []rbacv1.ClusterRoleBinding{})
+}
+
+func TestParseCustomRoleRef(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "custom",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole:     "auditor",
+			RoleRefAPIGroup: "authz.example.com",
+			RoleRefKind:     "ClusterPolicy",
+		}, {
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:       "web",
+			Role:            "deployer",
+			RoleRefAPIGroup: "authz.example.com",
+			RoleRefKind:     "Policy",
+		}},
+	}}
+
+	subjects := []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-custom-deployer-web",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "authz.example.com",
+			Kind:     "Policy",
+			Name:     "deployer",
+		},
+		Subjects: subjects,
+	}}, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-custom-auditor",
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "authz.example.com",
+			Kind:     "ClusterPolicy",
+			Name:     "auditor",
+		},
+		Subjects: subjects,
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-custom-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: subjects,
+	}}, []corev1.ServiceAccount{})
+}
+
+func TestParseMaxSubjectsPerBinding(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "sue",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "kay",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, MaxSubjectsPerBinding: 2}
+	err := p.Parse(rbacDef)
+	if assert.Error(t, err, "Expected error when exceeding subject limit") {
+		assert.Contains(t, err.Error(), "rbac-config-devs-edit")
+	}
+	expectParsedRB(t, p, []rbacv1.RoleBinding{})
+
+	p = Parser{Clientset: client, MaxSubjectsPerBinding: 3}
+	err = p.Parse(rbacDef)
+	assert.NoError(t, err)
+	assert.Len(t, p.parsedRoleBindings, 1)
+}
+
+func TestParseInheritDefinitionLabels(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.Labels = map[string]string{
+		"cost-center":  "1234",
+		"team":         "platform",
+		"environment":  "production",
+		"rbac-manager": "someone-else",
+	}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{
+		Clientset:               client,
+		InheritDefinitionLabels: []string{"cost-center", "team", "rbac-manager", "missing"},
+	}
+
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expected := map[string]string{
+		"cost-center":  "1234",
+		"team":         "platform",
+		"rbac-manager": "reactiveops",
+	}
+
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Len(t, p.parsedRoleBindings, 1)
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Equal(t, expected, p.parsedClusterRoleBindings[0].Labels)
+	assert.Equal(t, expected, p.parsedRoleBindings[0].Labels)
+	assert.Equal(t, expected, p.parsedServiceAccounts[0].Labels)
+
+	// the shared managed labels are left untouched
+	assert.Equal(t, map[string]string{"rbac-manager": "reactiveops"}, Labels)
+}
+
+func TestParseSubjectDisplayNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	subjects := []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "jdoe@example.com",
+	}, {
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      "ci-bot",
+		Namespace: "bots",
+	}}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: subjects,
+		SubjectDisplayNames: map[string]string{
+			"jdoe@example.com": "Jane Doe",
+			"ci-bot":           "CI Bot",
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Len(t, p.parsedRoleBindings, 1)
+	for _, annotations := range []map[string]string{p.parsedClusterRoleBindings[0].Annotations, p.parsedRoleBindings[0].Annotations} {
+		assert.Equal(t, "Jane Doe", annotations["rbac-manager/subject-display-jdoe-example.com"])
+		assert.Equal(t, "CI Bot", annotations["rbac-manager/subject-display-ci-bot"])
+	}
+
+	// display names never change the RBAC subjects
+	assert.Equal(t, subjects, p.parsedClusterRoleBindings[0].Subjects)
+	assert.Equal(t, subjects, p.parsedRoleBindings[0].Subjects)
+
+	// long subject names are truncated to a valid annotation key
+	key := subjectDisplayAnnotation(strings.Repeat("a", 100))
+	assert.Len(t, strings.TrimPrefix(key, "rbac-manager/"), 63)
+}
+
+func TestParseApplyOrder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ApplyOrder: 10,
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}, {
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Len(t, p.parsedRoleBindings, 2)
+	assert.Equal(t, "10", p.parsedServiceAccounts[0].Annotations[ApplyOrderAnnotation])
+	assert.Equal(t, "10", p.parsedClusterRoleBindings[0].Annotations[ApplyOrderAnnotation])
+	assert.Equal(t, "10", p.parsedRoleBindings[0].Annotations[ApplyOrderAnnotation])
+
+	// RBAC Bindings without an apply order aren't annotated
+	assert.NotContains(t, p.parsedRoleBindings[1].Annotations, ApplyOrderAnnotation)
+
+	rbacDef.RBACBindings[0].ApplyOrder = -1
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid applyOrder -1 for RBAC Binding ci-bot, must not be negative")
+}
+
+func TestParseQualifyClusterRoleBindingNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	// both RBAC Definitions would generate a team-a-devs-view Cluster Role Binding
+	team := rbacmanagerv1beta1.RBACDefinition{}
+	team.Name = "team"
+	team.UID = "4f1c9a52-d6a1-11e8-9f8b-f2801f1b9fd1"
+	team.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "a-devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+		}},
+	}}
+
+	teamA := rbacmanagerv1beta1.RBACDefinition{}
+	teamA.Name = "team-a"
+	teamA.UID = "5e0d7b3c-d6a1-11e8-9f8b-f2801f1b9fd1"
+	teamA.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	names := func(p *Parser, rbacDef rbacmanagerv1beta1.RBACDefinition) []string {
+		err := p.Parse(rbacDef)
+		if err != nil {
+			t.Fatalf("Error parsing RBAC Definition: %v", err)
+		}
+
+		crbNames := []string{}
+		for _, crb := range p.parsedClusterRoleBindings {
+			crbNames = append(crbNames, crb.Name)
+		}
+		return crbNames
+	}
+
+	assert.Equal(t, names(&Parser{Clientset: client}, team), names(&Parser{Clientset: client}, teamA))
+
+	p := Parser{Clientset: client, QualifyClusterRoleBindingNames: true}
+	teamNames := names(&p, team)
+	teamANames := names(&Parser{Clientset: client, QualifyClusterRoleBindingNames: true}, teamA)
+	assert.NotEqual(t, teamNames, teamANames)
+	assert.Regexp(t, "^team-a-devs-view-[0-9a-f]{8}$", teamNames[0])
+	assert.Regexp(t, "^team-a-devs-view-[0-9a-f]{8}$", teamANames[0])
+
+	// Role Bindings are namespaced and keep their names
+	assert.Equal(t, "team-a-devs-edit", p.parsedRoleBindings[0].Name)
+
+	// names are stable across parses
+	assert.Equal(t, teamNames, names(&Parser{Clientset: client, QualifyClusterRoleBindingNames: true}, team))
+}
+
+func TestParseNameSeparator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "web-team"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "on-call",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "web-on-call"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "cluster-admin",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Role:      "log-reader",
+			Namespace: "web-prod",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	// with the default separator names can't be split back into their parts
+	assert.Equal(t, "web-team-on-call-cluster-admin", p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, "web-team-on-call-log-reader-web-prod", p.parsedRoleBindings[0].Name)
+
+	p = Parser{Clientset: client, NameSeparator: "."}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, "web-team.on-call.cluster-admin", p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, "web-team.on-call.log-reader.web-prod", p.parsedRoleBindings[0].Name)
+	assert.Equal(t, []string{"web-team", "on-call", "log-reader", "web-prod"}, strings.Split(p.parsedRoleBindings[0].Name, "."))
+
+	// names containing a custom separator are rejected, since they would make
+	// generated names ambiguous
+	rbacDef.Name = "web.team"
+	p = Parser{Clientset: client, NameSeparator: "."}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid RBAC Definition name web.team, names can not contain the name separator .")
+
+	rbacDef.Name = "web-team"
+	rbacDef.RBACBindings[0].Name = "on.call"
+	p = Parser{Clientset: client, NameSeparator: "."}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid RBAC Binding name on.call, names can not contain the name separator .")
+}
+
+func TestParseFailOnEmptyBinding(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createNamespace(t, client, "web", map[string]string{"team": "dev"})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:       "edit",
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "dev"}},
+		}, {
+			ClusterRole:       "view",
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}},
+		}},
+	}}
+
+	// by default a selector matching nothing generates nothing
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	if assert.Len(t, p.parsedRoleBindings, 1) {
+		assert.Equal(t, "web", p.parsedRoleBindings[0].Namespace)
+	}
+
+	p = Parser{Clientset: client, FailOnEmptyBinding: true}
+	assert.EqualError(t, p.Parse(rbacDef), "Role Binding rbac-config-devs-view is not generated in any namespace")
+
+	// an explicit namespace alongside the selector isn't empty
+	rbacDef.RBACBindings[0].RoleBindings[1].Namespaces = []string{"ops"}
+	p = Parser{Clientset: client, FailOnEmptyBinding: true}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, p.parsedRoleBindings, 2)
+}
+
+func TestParseMaxNameLengths(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "deployers",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deployment-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{
+		Clientset:         client,
+		CreateTokenSecret: true,
+		MaxNameLengths: map[string]int{
+			ResourceTypeClusterRoleBinding: 20,
+			ResourceTypeRoleBinding:        16,
+			ResourceTypeServiceAccount:     14,
+			ResourceTypeSecret:             14,
+		},
+	}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	crbName := truncateName("rbac-config-deployers-view", 20)
+	rbName := truncateName("rbac-config-deployers-edit", 16)
+	secretName := truncateName(TokenSecretName("deployment-bot"), 14)
+	assert.Len(t, crbName, 20)
+	assert.Len(t, rbName, 16)
+
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Len(t, p.parsedRoleBindings, 1)
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Equal(t, crbName, p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, rbName, p.parsedRoleBindings[0].Name)
+	assert.Equal(t, "deployment-bot", p.parsedServiceAccounts[0].Name)
+	assert.Equal(t, []TruncatedName{
+		{Original: TokenSecretName("deployment-bot"), Truncated: secretName},
+		{Original: "rbac-config-deployers-view", Truncated: crbName},
+		{Original: "rbac-config-deployers-edit", Truncated: rbName},
+	}, p.TruncatedNames())
+
+	// generated token Secret names are truncated, but still refer to the
+	// Service Account by its explicit name
+	if assert.Len(t, p.parsedSecrets, 1) {
+		assert.Equal(t, secretName, p.parsedSecrets[0].Name)
+		assert.Equal(t, "deployment-bot", p.parsedSecrets[0].Annotations[corev1.ServiceAccountNameKey])
+	}
+
+	// explicit Service Account names over the limit are rejected instead of
+	// truncated
+	p = Parser{Clientset: client, MaxNameLengths: map[string]int{ResourceTypeServiceAccount: 12}}
+	assert.EqualError(t, p.ValidateStatic(&rbacDef), "Invalid Service Account name deployment-bot, names can not be longer than 12 characters")
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid Service Account name deployment-bot, names can not be longer than 12 characters")
+
+	// types without a limit keep the API server limit
+	p = Parser{Clientset: client, MaxNameLengths: map[string]int{ResourceTypeRoleBinding: 16}}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, "rbac-config-deployers-view", p.parsedClusterRoleBindings[0].Name)
+	assert.Equal(t, "deployment-bot", p.parsedServiceAccounts[0].Name)
+	assert.Equal(t, rbName, p.parsedRoleBindings[0].Name)
+
+	p = Parser{Clientset: client, MaxNameLengths: map[string]int{ResourceTypeRoleBinding: 9}}
+	assert.EqualError(t, p.Parse(rbacDef), "Invalid max name length 9 for RoleBinding, must be at least 10")
+}
+
+func TestParseExpiresAt(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:      "contractor",
+		ExpiresAt: "2026-12-31T17:00:00-05:00",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "contractor",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}, {
+		Name: "permanent",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expected := "2026-12-31T22:00:00Z"
+
+	assert.Len(t, p.parsedClusterRoleBindings, 2)
+	assert.Len(t, p.parsedRoleBindings, 1)
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Equal(t, expected, p.parsedClusterRoleBindings[0].Annotations[ExpiresAtAnnotation])
+	assert.Equal(t, expected, p.parsedRoleBindings[0].Annotations[ExpiresAtAnnotation])
+	assert.Equal(t, expected, p.parsedServiceAccounts[0].Annotations[ExpiresAtAnnotation])
+	assert.NotContains(t, p.parsedClusterRoleBindings[1].Annotations, ExpiresAtAnnotation)
+
+	for _, invalid := range []string{"2026-12-31", "tomorrow", "2026-12-31 17:00:00"} {
+		rbacDef.RBACBindings[0].ExpiresAt = invalid
+
+		p = Parser{Clientset: client}
+		err = p.Parse(rbacDef)
+		assert.Error(t, err, "Expected error for expiresAt %v", invalid)
+	}
+}
+
+func TestParseEnabledResourceTypes(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	tests := []struct {
+		enabled map[string]bool
+		crbs    int
+		rbs     int
+		sas     int
+	}{
+		{nil, 1, 1, 1},
+		{map[string]bool{ResourceTypeClusterRoleBinding: true}, 1, 0, 0},
+		{map[string]bool{ResourceTypeRoleBinding: true}, 0, 1, 0},
+		{map[string]bool{ResourceTypeServiceAccount: true}, 0, 0, 1},
+		{map[string]bool{ResourceTypeClusterRoleBinding: true, ResourceTypeRoleBinding: true}, 1, 1, 0},
+		{map[string]bool{ResourceTypeRoleBinding: false, ResourceTypeServiceAccount: true}, 0, 0, 1},
+	}
+
+	for _, test := range tests {
+		p := Parser{Clientset: client, EnabledResourceTypes: test.enabled}
+		err := p.Parse(rbacDef)
+		if err != nil {
+			t.Fatalf("Error parsing RBAC Definition: %v", err)
+		}
+
+		assert.Len(t, p.parsedClusterRoleBindings, test.crbs, "Expected cluster role bindings for %v", test.enabled)
+		assert.Len(t, p.parsedRoleBindings, test.rbs, "Expected role bindings for %v", test.enabled)
+		assert.Len(t, p.parsedServiceAccounts, test.sas, "Expected service accounts for %v", test.enabled)
+	}
+}
+
+func TestParseScopeToSubjectNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "bots",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "ci",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deploy-bot",
+			Namespace: "deploy",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "test-bot",
+			Namespace: "ci",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole:              "edit",
+			ScopeToSubjectNamespaces: true,
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-bots-edit",
+			Namespace: "ci",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "ci",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "test-bot",
+			Namespace: "ci",
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-bots-edit",
+			Namespace: "deploy",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deploy-bot",
+			Namespace: "deploy",
+		}},
+	}}, []rbacv1.ClusterRoleBinding{}, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ci-bot",
+			Namespace: "ci",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deploy-bot",
+			Namespace: "deploy",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-bot",
+			Namespace: "ci",
+		},
+	}})
+}
+
+func TestParseSystemGroups(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	for _, systemGroup := range []string{"system:authenticated", "system:unauthenticated", "system:masters"} {
+		rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+		rbacDef.Name = "rbac-config"
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name: "everyone",
+			Subjects: []rbacv1.Subject{{
+				Kind: rbacv1.GroupKind,
+				Name: systemGroup,
+			}},
+			ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+				ClusterRole: "view",
+			}},
+		}}
+
+		p := Parser{Clientset: client}
+		err := p.Parse(rbacDef)
+		assert.Error(t, err, "Expected error binding to %v", systemGroup)
+		assert.Len(t, p.parsedClusterRoleBindings, 0)
+
+		p = Parser{Clientset: client, AllowSystemGroups: true}
+		err = p.Parse(rbacDef)
+		assert.NoError(t, err, "Expected no error binding to %v when allowed", systemGroup)
+		assert.Len(t, p.parsedClusterRoleBindings, 1)
+	}
+
+	// Users sharing a system group name are not affected
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "user",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "system:masters",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client, AllowSystemUsers: true}
+	assert.NoError(t, p.Parse(rbacDef))
+}
+
+func TestParseSystemUsers(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "scheduler",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "system:kube-scheduler",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	assert.EqualError(t, err, "Binding to system user system:kube-scheduler is not allowed, names starting with system: are reserved")
+	assert.Len(t, p.parsedClusterRoleBindings, 0)
+
+	// system groups are still guarded separately
+	p = Parser{Clientset: client, AllowSystemGroups: true}
+	assert.Error(t, p.Parse(rbacDef))
+
+	p = Parser{Clientset: client, AllowSystemUsers: true}
+	assert.NoError(t, p.Parse(rbacDef))
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+
+	// Groups and Service Accounts aren't checked
+	rbacDef.RBACBindings[0].Subjects = []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, Name: "system:nodes"},
+		{Kind: rbacv1.UserKind, Name: "jane.system:admin"},
+	}
+	p = Parser{Clientset: client}
+	assert.NoError(t, p.Parse(rbacDef))
+}
+
+func TestSortByPriority(t *testing.T) {
+	rbacDefs := []rbacmanagerv1beta1.RBACDefinition{}
+	for _, def := range []struct {
+		name     string
+		priority int32
+	}{
+		{"team-a", 0},
+		{"platform", 100},
+		{"team-b", 0},
+		{"base", 1000},
+		{"overrides", -10},
+		{"security", 100},
+	} {
+		rbacDef := rbacmanagerv1beta1.RBACDefinition{Priority: def.priority}
+		rbacDef.Name = def.name
+		rbacDefs = append(rbacDefs, rbacDef)
+	}
+
+	SortByPriority(rbacDefs)
+
+	names := []string{}
+	for _, rbacDef := range rbacDefs {
+		names = append(names, rbacDef.Name)
+	}
+
+	assert.Equal(t, []string{"base", "platform", "security", "team-a", "team-b", "overrides"}, names)
+}
+
+func TestParseBoth(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	subjects := []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: subjects,
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "view",
+			Both:        true,
+		}},
+	}}
+
+	newParseTest(t, client, rbacDef, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbac-config-devs-view",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: subjects,
+	}}, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-devs-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: subjects,
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-devs-view-cluster",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: subjects,
+	}}, []corev1.ServiceAccount{})
+
+	rbacDef.RBACBindings[0].RoleBindings = []rbacmanagerv1beta1.RoleBinding{{
+		Namespace: "web",
+		Role:      "custom",
+		Both:      true,
+	}}
+
+	p := Parser{Clientset: client}
+	assert.Error(t, p.Parse(rbacDef), "Expected error when combining both with a role")
+}
+
+func TestParsePropagateNamespaceLabels(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createNamespace(t, client, "prod-bots", map[string]string{"environment": "production", "team": "bots", "rbac-manager": "other"})
+	createNamespace(t, client, "bots", map[string]string{"team": "bots"})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "bots",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deploy-bot",
+			Namespace: "prod-bots",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "test-bot",
+			Namespace: "missing",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client, PropagateNamespaceLabels: []string{"environment", "rbac-manager"}}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	actual := map[string]map[string]string{}
+	for _, sa := range p.parsedServiceAccounts {
+		actual[sa.Name] = sa.Labels
+	}
+
+	assert.Equal(t, map[string]map[string]string{
+		"deploy-bot": {"environment": "production", "rbac-manager": "reactiveops"},
+		"ci-bot":     {"rbac-manager": "reactiveops"},
+		"test-bot":   {"rbac-manager": "reactiveops"},
+	}, actual)
+}
+
+func TestParseDuplicateBindingNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}, {
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "sue",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if assert.Error(t, err, "Expected error for duplicate binding names") {
+		assert.Contains(t, err.Error(), "devs")
+	}
+	expectParsedCRB(t, p, []rbacv1.ClusterRoleBinding{})
+}
+
 func newParseTest(t *testing.T, client *fake.Clientset, rbacDef rbacmanagerv1beta1.RBACDefinition, expectedRb []rbacv1.RoleBinding, expectedCrb []rbacv1.ClusterRoleBinding, expectedSa []corev1.ServiceAccount) {
 	p := Parser{Clientset: client}
 
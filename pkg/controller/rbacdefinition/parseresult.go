@@ -0,0 +1,117 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// protobufMediaType is the media type of the Kubernetes protobuf serializer
+const protobufMediaType = "application/vnd.kubernetes.protobuf"
+
+// protobufEncoder encodes resources with the Kubernetes protobuf serializer,
+// setting the kind and version of each resource so it can be decoded again
+func protobufEncoder() (runtime.Encoder, error) {
+	info, ok := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), protobufMediaType)
+	if !ok {
+		return nil, fmt.Errorf("Unable to find a serializer for %v", protobufMediaType)
+	}
+
+	versions := schema.GroupVersions{v1.SchemeGroupVersion, rbacv1.SchemeGroupVersion}
+	return scheme.Codecs.EncoderForVersion(info.Serializer, versions), nil
+}
+
+// Marshal encodes the resources in a ParseResult as a protobuf List, so that
+// results can be cached between reconciles
+func (r *ParseResult) Marshal() ([]byte, error) {
+	encoder, err := protobufEncoder()
+	if err != nil {
+		return nil, err
+	}
+
+	objs := []runtime.Object{}
+	for i := range r.ServiceAccounts {
+		objs = append(objs, r.ServiceAccounts[i].DeepCopy())
+	}
+	for i := range r.Secrets {
+		objs = append(objs, r.Secrets[i].DeepCopy())
+	}
+	for i := range r.ClusterRoleBindings {
+		objs = append(objs, r.ClusterRoleBindings[i].DeepCopy())
+	}
+	for i := range r.RoleBindings {
+		objs = append(objs, r.RoleBindings[i].DeepCopy())
+	}
+
+	list := &v1.List{}
+	for _, obj := range objs {
+		data, err := runtime.Encode(encoder, obj)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, runtime.RawExtension{Raw: data})
+	}
+
+	return runtime.Encode(encoder, list)
+}
+
+// Unmarshal replaces the resources in a ParseResult with those decoded from
+// data returned by Marshal
+func (r *ParseResult) Unmarshal(data []byte) error {
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	obj, err := runtime.Decode(decoder, data)
+	if err != nil {
+		return err
+	}
+
+	list, ok := obj.(*v1.List)
+	if !ok {
+		return fmt.Errorf("Unable to unmarshal %T, expected a List", obj)
+	}
+
+	result := ParseResult{}
+	for _, item := range list.Items {
+		obj, err := runtime.Decode(decoder, item.Raw)
+		if err != nil {
+			return err
+		}
+
+		// Parsed resources don't set a kind or version
+		obj.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+
+		switch o := obj.(type) {
+		case *v1.ServiceAccount:
+			result.ServiceAccounts = append(result.ServiceAccounts, *o)
+		case *v1.Secret:
+			result.Secrets = append(result.Secrets, *o)
+		case *rbacv1.ClusterRoleBinding:
+			result.ClusterRoleBindings = append(result.ClusterRoleBindings, *o)
+		case *rbacv1.RoleBinding:
+			result.RoleBindings = append(result.RoleBindings, *o)
+		default:
+			return fmt.Errorf("Unable to unmarshal %T, only Cluster Role Bindings, Role Bindings, Service Accounts, and Secrets are supported", obj)
+		}
+	}
+
+	*r = result
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseResultMarshal(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.UID = "4f1c9a52-d6a1-11e8-9f8b-f2801f1b9fd1"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespaces:  []string{"web", "api"},
+			ClusterRole: "edit",
+		}},
+	}}
+
+	p := Parser{Clientset: client, CreateTokenSecret: true, ownerRefs: rbacDefOwnerRefs(&rbacDef)}
+	result, err := p.ParseAll([]rbacmanagerv1beta1.RBACDefinition{rbacDef})
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Len(t, result.ServiceAccounts, 1)
+	assert.Len(t, result.Secrets, 1)
+	assert.Len(t, result.ClusterRoleBindings, 1)
+	assert.Len(t, result.RoleBindings, 2)
+
+	data, err := result.Marshal()
+	assert.NoError(t, err)
+
+	// results are encoded with the Kubernetes protobuf serializer
+	assert.True(t, bytes.HasPrefix(data, []byte("k8s\x00")))
+
+	decoded := &ParseResult{}
+	err = decoded.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, result, decoded)
+
+	// an empty result round trips too
+	data, err = (&ParseResult{}).Marshal()
+	assert.NoError(t, err)
+	err = decoded.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &ParseResult{}, decoded)
+
+	assert.Error(t, decoded.Unmarshal([]byte("not a parse result")))
+}
@@ -0,0 +1,233 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"sort"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// requiredPermissions collects the verbs needed on each resource
+type requiredPermissions struct {
+	verbs         map[string]map[string]bool
+	boundRoles    map[string]map[string]bool
+	anyBoundRoles map[string]bool
+}
+
+func (r *requiredPermissions) add(resource string, verbs ...string) {
+	if r.verbs[resource] == nil {
+		r.verbs[resource] = map[string]bool{}
+	}
+	for _, verb := range verbs {
+		r.verbs[resource][verb] = true
+	}
+}
+
+func (r *requiredPermissions) bind(resource, name string) {
+	if name == "" {
+		return
+	}
+	if isRoleTemplate(name) {
+		r.anyBoundRoles[resource] = true
+		return
+	}
+	if r.boundRoles[resource] == nil {
+		r.boundRoles[resource] = map[string]bool{}
+	}
+	r.boundRoles[resource][name] = true
+}
+
+// RequiredControllerPermissions returns the rules RBAC Manager needs to
+// reconcile an RBAC Definition, so that the controller can be granted no more
+// than it needs. Rules cover the RBAC Definition itself, listing, creating,
+// updating and deleting the resources it manages, looking up namespaces and
+// roles where the definition depends on them, and binding each Cluster Role
+// and Role it refers to. Bindings to custom role references are not covered.
+func (p *Parser) RequiredControllerPermissions(rbacDef rbacmanagerv1beta1.RBACDefinition) []rbacv1.PolicyRule {
+	required := requiredPermissions{
+		verbs:         map[string]map[string]bool{},
+		boundRoles:    map[string]map[string]bool{},
+		anyBoundRoles: map[string]bool{},
+	}
+
+	// Managed resources are always listed and stale ones deleted, even when
+	// the definition no longer requests any of them
+	required.add("clusterrolebindings", "list", "delete")
+	required.add("rolebindings", "list", "delete")
+	required.add("serviceaccounts", "list", "delete")
+
+	if p.HasNamespaceSelectors(&rbacDef) {
+		required.add("namespaces", "get", "list", "watch")
+	}
+
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		serviceAccounts := rbacBinding.SubjectsFromURL != "" || hasServiceAccountSubject(rbacBinding.Subjects)
+
+		if rbacBinding.SubjectsFromConfigMap != nil {
+			required.add("configmaps", "get")
+			serviceAccounts = true
+		}
+		for _, rb := range rbacBinding.RoleBindings {
+			for _, overrides := range rb.SubjectOverrides {
+				serviceAccounts = serviceAccounts || hasServiceAccountSubject(overrides)
+			}
+		}
+
+		if serviceAccounts {
+			if rbacBinding.CreateServiceAccounts == nil || *rbacBinding.CreateServiceAccounts {
+				required.add("serviceaccounts", "create", "update")
+				if len(p.PropagateNamespaceLabels) > 0 {
+					required.add("namespaces", "get")
+				}
+				if p.CreateTokenSecret {
+					required.add("secrets", "list", "create")
+				}
+			} else {
+				required.add("serviceaccounts", "get")
+			}
+		}
+
+		for _, crb := range rbacBinding.ClusterRoleBindings {
+			if crb.ScopeToSubjectNamespaces {
+				required.add("rolebindings", "create", "update")
+			} else {
+				required.add("clusterrolebindings", "create", "update")
+			}
+
+			if crb.RoleRefKind != "" || crb.RoleRefAPIGroup != "" {
+				continue
+			}
+
+			clusterRoles := append([]string{crb.ClusterRole}, crb.ClusterRoles...)
+			for _, clusterRole := range clusterRoles {
+				required.bind("clusterroles", clusterRole)
+			}
+
+			if p.ResolveAggregatedClusterRoles {
+				required.add("clusterroles", "get", "list")
+			}
+		}
+
+		for _, rb := range rbacBinding.RoleBindings {
+			required.add("rolebindings", "create", "update")
+
+			if p.Requester != nil {
+				required.add("subjectaccessreviews", "create")
+			}
+
+			if rb.RequireRolePresent {
+				required.add("roles", "get")
+			}
+
+			if rb.RoleRefKind != "" || rb.RoleRefAPIGroup != "" {
+				continue
+			}
+
+			required.bind("clusterroles", rb.ClusterRole)
+			required.bind("roles", rb.Role)
+
+			if rb.PerLabelValue != nil {
+				for _, clusterRole := range rb.PerLabelValue.ClusterRoles {
+					required.bind("clusterroles", clusterRole)
+				}
+			}
+		}
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{rbacmanagerv1beta1.SchemeGroupVersion.Group},
+			Resources: []string{"rbacdefinitions"},
+			Verbs:     []string{"get", "list", "watch"},
+		},
+		{
+			APIGroups: []string{rbacmanagerv1beta1.SchemeGroupVersion.Group},
+			Resources: []string{"rbacdefinitions/status"},
+			Verbs:     []string{"get", "update", "patch"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
+		},
+	}
+
+	for _, resource := range []string{"configmaps", "namespaces", "secrets", "serviceaccounts"} {
+		if required.verbs[resource] != nil {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{resource},
+				Verbs:     sortedKeys(required.verbs[resource]),
+			})
+		}
+	}
+
+	for _, resource := range []string{"clusterrolebindings", "rolebindings", "clusterroles", "roles"} {
+		if required.verbs[resource] != nil {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{rbacv1.GroupName},
+				Resources: []string{resource},
+				Verbs:     sortedKeys(required.verbs[resource]),
+			})
+		}
+	}
+
+	if required.verbs["subjectaccessreviews"] != nil {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"authorization.k8s.io"},
+			Resources: []string{"subjectaccessreviews"},
+			Verbs:     sortedKeys(required.verbs["subjectaccessreviews"]),
+		})
+	}
+
+	for _, resource := range []string{"clusterroles", "roles"} {
+		if required.anyBoundRoles[resource] {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{rbacv1.GroupName},
+				Resources: []string{resource},
+				Verbs:     []string{"bind"},
+			})
+		} else if required.boundRoles[resource] != nil {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups:     []string{rbacv1.GroupName},
+				Resources:     []string{resource},
+				Verbs:         []string{"bind"},
+				ResourceNames: sortedKeys(required.boundRoles[resource]),
+			})
+		}
+	}
+
+	return rules
+}
+
+func hasServiceAccountSubject(subjects []rbacv1.Subject) bool {
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRequiredControllerPermissionsExplicitNamespaces(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{
+			{ClusterRole: "edit", Namespace: "web"},
+			{Role: "deployer", Namespace: "api"},
+		},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+	rules := p.RequiredControllerPermissions(rbacDef)
+
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{"rbacmanager.reactiveops.io"}, Resources: []string{"rbacdefinitions"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"rbacmanager.reactiveops.io"}, Resources: []string{"rbacdefinitions/status"}, Verbs: []string{"get", "update", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"serviceaccounts"}, Verbs: []string{"delete", "list"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterrolebindings"}, Verbs: []string{"delete", "list"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"rolebindings"}, Verbs: []string{"create", "delete", "list", "update"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}, Verbs: []string{"bind"}, ResourceNames: []string{"edit"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"bind"}, ResourceNames: []string{"deployer"}},
+	}, rules)
+}
+
+func TestRequiredControllerPermissionsNamespaceSelector(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{
+			{ClusterRole: "view"},
+		},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "web"},
+			},
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+	rules := p.RequiredControllerPermissions(rbacDef)
+
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{"rbacmanager.reactiveops.io"}, Resources: []string{"rbacdefinitions"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"rbacmanager.reactiveops.io"}, Resources: []string{"rbacdefinitions/status"}, Verbs: []string{"get", "update", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"serviceaccounts"}, Verbs: []string{"create", "delete", "list", "update"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterrolebindings"}, Verbs: []string{"create", "delete", "list", "update"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"rolebindings"}, Verbs: []string{"create", "delete", "list", "update"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}, Verbs: []string{"bind"}, ResourceNames: []string{"edit", "view"}},
+	}, rules)
+
+	// binding a templated Role requires binding any Role
+	rbacDef.RBACBindings[0].RoleBindings[0] = rbacmanagerv1beta1.RoleBinding{
+		Role:               "deployer-{{.Namespace}}",
+		NamespaceSelector:  metav1.LabelSelector{MatchLabels: map[string]string{"team": "web"}},
+		RequireRolePresent: true,
+	}
+
+	rules = p.RequiredControllerPermissions(rbacDef)
+	assert.Contains(t, rules, rbacv1.PolicyRule{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"get"}})
+	assert.Contains(t, rules, rbacv1.PolicyRule{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"bind"}})
+
+	// requesters are checked with SubjectAccessReviews
+	p.Requester = &Requester{User: "joe"}
+	rules = p.RequiredControllerPermissions(rbacDef)
+	assert.Contains(t, rules, rbacv1.PolicyRule{APIGroups: []string{"authorization.k8s.io"}, Resources: []string{"subjectaccessreviews"}, Verbs: []string{"create"}})
+	p.Requester = nil
+
+	// token Secrets are created for generated Service Accounts
+	p.CreateTokenSecret = true
+	rules = p.RequiredControllerPermissions(rbacDef)
+	assert.Contains(t, rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"create", "list"}})
+}
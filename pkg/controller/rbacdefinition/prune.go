@@ -0,0 +1,150 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"context"
+	"fmt"
+
+	logrus "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PruneStale deletes every Cluster Role Binding, Role Binding, Service
+// Account, and token Secret with the managed Labels that is not in desired. Objects are
+// compared by kind, namespace, and name. Since every managed object is
+// considered, desired must include the objects of all RBAC Definitions.
+// Deletes that fail are returned as an ApplyError once the rest have been
+// attempted.
+func (r *Reconciler) PruneStale(ctx context.Context, desired []runtime.Object) error {
+	keep := map[string]bool{}
+	for _, obj := range desired {
+		key, err := pruneKey(obj)
+		if err != nil {
+			return err
+		}
+		keep[key] = true
+	}
+
+	pruneErr := &ApplyError{}
+	prune := func(resourceType string, meta *metav1.ObjectMeta, del func() error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if keep[resourceType+"/"+meta.Namespace+"/"+meta.Name] {
+			return nil
+		}
+
+		if r.skipProtectedNamespace(resourceType, meta) || r.skipFrozen("delete", resourceType, meta) || r.skipInShadowMode("delete", resourceType, meta) {
+			return nil
+		}
+
+		logrus.Infof("Pruning %v %v/%v", resourceType, meta.Namespace, meta.Name)
+		pruneErr.Attempted++
+		err := del()
+		if err != nil {
+			pruneErr.Failures = append(pruneErr.Failures, ApplyFailure{
+				Action:       "delete",
+				ResourceType: resourceType,
+				Namespace:    meta.Namespace,
+				Name:         meta.Name,
+				Err:          err,
+			})
+		}
+		return nil
+	}
+
+	crbs, err := r.Clientset.RbacV1().ClusterRoleBindings().List(ListOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, crb := range crbs.Items {
+		err = prune(ResourceTypeClusterRoleBinding, &crb.ObjectMeta, func() error {
+			return r.applier().Delete(ctx, []runtime.Object{&crb})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	rbs, err := r.Clientset.RbacV1().RoleBindings("").List(ListOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, rb := range rbs.Items {
+		err = prune(ResourceTypeRoleBinding, &rb.ObjectMeta, func() error {
+			return r.applier().Delete(ctx, []runtime.Object{&rb})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sas, err := r.Clientset.CoreV1().ServiceAccounts("").List(ListOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, sa := range sas.Items {
+		err = prune(ResourceTypeServiceAccount, &sa.ObjectMeta, func() error {
+			return r.applier().Delete(ctx, []runtime.Object{&sa})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	secrets, err := r.Clientset.CoreV1().Secrets("").List(ListOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets.Items {
+		err = prune(ResourceTypeSecret, &secret.ObjectMeta, func() error {
+			return r.applier().Delete(ctx, []runtime.Object{&secret})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(pruneErr.Failures) > 0 {
+		return pruneErr
+	}
+
+	return nil
+}
+
+// pruneKey identifies a desired object by its resource type, namespace, and name
+func pruneKey(obj runtime.Object) (string, error) {
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		return ResourceTypeClusterRoleBinding + "/" + o.Namespace + "/" + o.Name, nil
+	case *rbacv1.RoleBinding:
+		return ResourceTypeRoleBinding + "/" + o.Namespace + "/" + o.Name, nil
+	case *v1.ServiceAccount:
+		return ResourceTypeServiceAccount + "/" + o.Namespace + "/" + o.Name, nil
+	case *v1.Secret:
+		return ResourceTypeSecret + "/" + o.Namespace + "/" + o.Name, nil
+	default:
+		return "", fmt.Errorf("Unable to prune %T, only Cluster Role Bindings, Role Bindings, Service Accounts, and Secrets are supported", obj)
+	}
+}
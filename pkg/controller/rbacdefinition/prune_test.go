@@ -0,0 +1,182 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPruneStale(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "prune-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}, {
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	// bindings without the managed labels are never pruned
+	_, err := client.RbacV1().RoleBindings("web").Create(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "web"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desired := []runtime.Object{
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "prune-example-ci-bot-edit", Namespace: "bots"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"}},
+	}
+
+	err = r.PruneStale(context.Background(), desired)
+	assert.NoError(t, err)
+
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{})
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"},
+	}})
+
+	rbs, err := client.RbacV1().RoleBindings("").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, rb := range rbs.Items {
+		names = append(names, rb.Namespace+"/"+rb.Name)
+	}
+	assert.ElementsMatch(t, []string{"bots/prune-example-ci-bot-edit", "web/unmanaged"}, names)
+
+	// an empty desired set prunes every managed object
+	err = r.PruneStale(context.Background(), []runtime.Object{})
+	assert.NoError(t, err)
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{})
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{})
+
+	err = r.PruneStale(context.Background(), []runtime.Object{&corev1.Namespace{}})
+	assert.Error(t, err, "Expected error for an unsupported desired object")
+
+	// frozen objects survive a prune that would otherwise delete them
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("prune-example-ci-bot-view", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crb.Annotations = map[string]string{FreezeAnnotation: "true"}
+	_, err = client.RbacV1().ClusterRoleBindings().Update(crb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.PruneStale(context.Background(), []runtime.Object{})
+	assert.NoError(t, err)
+
+	crbs, err := client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, crbs.Items, 1)
+	assert.Equal(t, "prune-example-ci-bot-view", crbs.Items[0].Name)
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	err = r.PruneStale(ctx, []runtime.Object{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestPruneStaleTokenSecrets(t *testing.T) {
+	CreateTokenSecret = true
+	defer func() { CreateTokenSecret = false }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "prune-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "bots",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "deploy-bot",
+			Namespace: "bots",
+		}},
+	}}
+
+	p := Parser{Clientset: client, CreateTokenSecret: true}
+	result, err := p.ParseAll([]rbacmanagerv1beta1.RBACDefinition{rbacDef})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, result.Secrets, 2)
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	secretNames := func() []string {
+		secrets, err := client.CoreV1().Secrets("").List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, secret := range secrets.Items {
+			names = append(names, secret.Namespace+"/"+secret.Name)
+		}
+		return names
+	}
+	assert.ElementsMatch(t, []string{"bots/ci-bot-token", "bots/deploy-bot-token"}, secretNames())
+
+	// only the token Secret of the remaining Service Account is kept
+	desired := []runtime.Object{
+		&result.ServiceAccounts[0],
+		&result.Secrets[0],
+	}
+
+	err = r.PruneStale(context.Background(), desired)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bots/ci-bot-token"}, secretNames())
+}
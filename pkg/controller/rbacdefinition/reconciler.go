@@ -15,43 +15,124 @@
 package rbacdefinition
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"reflect"
+	"strings"
 
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
 	logrus "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 )
 
 // Reconciler creates and deletes Kubernetes resources to achieve the desired state of an RBAC Definition
 type Reconciler struct {
 	Clientset kubernetes.Interface
-	ownerRefs []metav1.OwnerReference
+
+	// NamespaceLister lists the namespaces namespace selectors are evaluated against
+	NamespaceLister NamespaceLister
+
+	// GroupMembershipLister expands Group subjects into their members
+	GroupMembershipLister GroupMembershipLister
+
+	// HTTPClient fetches subjects from external endpoints
+	HTTPClient *http.Client
+
+	// TeamNamespaceLister resolves the namespaces of Role Bindings that
+	// target a team
+	TeamNamespaceLister TeamNamespaceLister
+
+	// NodeNamespaceResolver resolves the namespaces of Role Bindings that
+	// target the nodes matching a node selector
+	NodeNamespaceResolver NodeNamespaceResolver
+
+	// ResourcePresenceResolver checks namespaces for the resources required
+	// by Role Bindings
+	ResourcePresenceResolver ResourcePresenceResolver
+
+	// ShadowMode logs the changes that would be made without applying them
+	ShadowMode bool
+
+	// ServerValidate validates each generated resource with the API server
+	// before any changes are applied
+	ServerValidate bool
+
+	// ServerValidator validates generated resources when ServerValidate is
+	// set, resources are validated with the Clientset when it is nil
+	ServerValidator ServerValidator
+
+	// Recorder is used to record events for changes skipped in shadow mode
+	Recorder record.EventRecorder
+
+	// HealthRegistry records whether each RBAC Definition parsed successfully
+	HealthRegistry *HealthRegistry
+
+	// BackoffRegistry counts consecutive parse failures of each RBAC
+	// Definition
+	BackoffRegistry *BackoffRegistry
+
+	// ResolverMetrics records the results and latency of subject resolvers
+	ResolverMetrics *ResolverMetrics
+
+	// RoleIndex records the roles referenced by the bindings generated for
+	// each RBAC Definition
+	RoleIndex *RoleIndex
+
+	// Applier makes each change, changes are applied directly with the
+	// Clientset when it is nil
+	Applier Applier
+
+	attempted           int
+	failures            []ApplyFailure
+	ownerRefs           []metav1.OwnerReference
+	protectedNamespaces map[string]bool
+	rbacDef             *rbacmanagerv1beta1.RBACDefinition
 }
 
 // ReconcileNamespaceChange reconciles relevant portions of RBAC Definitions
 //   after changes to namespaces within the cluster
 func (r *Reconciler) ReconcileNamespaceChange(rbacDef *rbacmanagerv1beta1.RBACDefinition, namespace *v1.Namespace) error {
 	r.ownerRefs = rbacDefOwnerRefs(rbacDef)
+	r.rbacDef = rbacDef
+	r.resetApplyResults()
+
+	p := r.newParser()
+	r.protectedNamespaces = p.ProtectedNamespaces
 
-	p := Parser{
-		Clientset: r.Clientset,
-		ownerRefs: r.ownerRefs,
+	requester, err := r.requester()
+	if err != nil {
+		return err
 	}
+	p.Requester = requester
 
-	if p.hasNamespaceSelectors(rbacDef) {
+	if p.HasNamespaceSelectors(rbacDef) && p.resourceTypeEnabled(ResourceTypeRoleBinding) {
 		logrus.Infof("Reconciling %v namespace for %v", namespace.Name, rbacDef.Name)
-		p.parseRoleBindings(rbacDef)
-		err := r.reconcileRoleBindings(&p.parsedRoleBindings)
+		err = p.Parse(*rbacDef)
+		if err != nil {
+			return err
+		}
+
+		if r.ServerValidate {
+			err = r.validateOnServer(&p)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = r.reconcileRoleBindings(&p.parsedRoleBindings)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return r.applyResults()
 }
 
 // Reconcile creates, updates, or deletes Kubernetes resources to match
@@ -60,50 +141,187 @@ func (r *Reconciler) Reconcile(rbacDef *rbacmanagerv1beta1.RBACDefinition) error
 	logrus.Infof("Reconciling RBACDefinition %v", rbacDef.Name)
 
 	r.ownerRefs = rbacDefOwnerRefs(rbacDef)
+	r.rbacDef = rbacDef
+	r.resetApplyResults()
 
-	p := Parser{
-		Clientset: r.Clientset,
-		ownerRefs: r.ownerRefs,
-	}
+	p := r.newParser()
+	r.protectedNamespaces = p.ProtectedNamespaces
 
 	var err error
 
-	err = p.Parse(*rbacDef)
+	p.Requester, err = r.requester()
 	if err != nil {
 		return err
 	}
 
-	err = r.reconcileServiceAccounts(&p.parsedServiceAccounts)
+	err = p.Parse(*rbacDef)
 	if err != nil {
 		return err
 	}
 
-	err = r.reconcileClusterRoleBindings(&p.parsedClusterRoleBindings)
-	if err != nil {
-		return err
+	rbacDef.Status.SelectorMatches = p.SelectorMatches()
+
+	if r.RoleIndex != nil {
+		r.RoleIndex.Record(rbacDef.Name, p.ReferencedRoles())
+	}
+
+	if r.Recorder != nil {
+		for _, name := range p.TruncatedNames() {
+			r.Recorder.Eventf(rbacDef, v1.EventTypeWarning, "NameTruncated", "Truncated generated name %v to %v",
+				name.Original, name.Truncated)
+		}
+	}
+
+	if r.ServerValidate {
+		err = r.validateOnServer(&p)
+		if err != nil {
+			return err
+		}
+	}
+
+	// existing resources of disabled types are left alone rather than
+	// deleted as orphans
+	if p.resourceTypeEnabled(ResourceTypeServiceAccount) {
+		err = r.reconcileServiceAccounts(&p.parsedServiceAccounts)
+		if err != nil {
+			return err
+		}
+
+		err = r.reconcileTokenSecrets(&p.parsedSecrets)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.resourceTypeEnabled(ResourceTypeClusterRoleBinding) {
+		err = r.reconcileClusterRoleBindings(&p.parsedClusterRoleBindings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.resourceTypeEnabled(ResourceTypeRoleBinding) {
+		err = r.reconcileRoleBindings(&p.parsedRoleBindings)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = r.reconcileRoleBindings(&p.parsedRoleBindings)
+	err = r.applyResults()
 	if err != nil {
 		return err
 	}
 
+	if missing := p.MissingServiceAccountNamespaces(); len(missing) > 0 {
+		return &MissingNamespaceError{Namespaces: missing}
+	}
+
 	return nil
 }
 
+// applier returns the Applier changes are made with
+func (r *Reconciler) applier() Applier {
+	var applier Applier = &ClientsetApplier{Clientset: r.Clientset}
+	if r.Applier != nil {
+		applier = r.Applier
+	}
+
+	if ApplyRateLimiter != nil {
+		return &RateLimitedApplier{Applier: applier, Limiter: ApplyRateLimiter}
+	}
+	return applier
+}
+
+// requester returns the Requester named by the annotations of the RBAC
+// Definition being reconciled when CheckRequesterAccess is set
+func (r *Reconciler) requester() (*Requester, error) {
+	if !CheckRequesterAccess {
+		return nil, nil
+	}
+
+	user := strings.TrimSpace(r.rbacDef.Annotations[RequesterAnnotation])
+	if user == "" {
+		return nil, fmt.Errorf("RBAC Definition %v is missing the %v annotation required to check requester access", r.rbacDef.Name, RequesterAnnotation)
+	}
+
+	requester := &Requester{User: user}
+	for _, group := range strings.Split(r.rbacDef.Annotations[RequesterGroupsAnnotation], ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			requester.Groups = append(requester.Groups, group)
+		}
+	}
+
+	return requester, nil
+}
+
+// newParser returns a Parser configured to generate resources owned by
+// the RBAC Definition being reconciled
+func (r *Reconciler) newParser() Parser {
+	return Parser{
+		Clientset:                       r.Clientset,
+		NamespaceLister:                 r.NamespaceLister,
+		GroupMembershipLister:           r.GroupMembershipLister,
+		HTTPClient:                      r.HTTPClient,
+		TeamNamespaceLister:             r.TeamNamespaceLister,
+		NodeNamespaceResolver:           r.NodeNamespaceResolver,
+		ResourcePresenceResolver:        r.ResourcePresenceResolver,
+		AllowSystemGroups:               AllowSystemGroups,
+		AllowSystemUsers:                AllowSystemUsers,
+		ProtectedNamespaces:             ProtectedNamespaces,
+		UseGenerateName:                 UseGenerateName,
+		CompactServiceAccountNames:      CompactServiceAccountNames,
+		MaxNameLengths:                  MaxNameLengths,
+		NameSeparator:                   NameSeparator,
+		QualifyClusterRoleBindingNames:  QualifyClusterRoleBindingNames,
+		SortSubjects:                    SortSubjects,
+		CollapseNamespaceBindings:       CollapseNamespaceBindings,
+		ExpandNamespaceGlobs:            ExpandNamespaceGlobs,
+		CreateTokenSecret:               CreateTokenSecret,
+		RequireServiceAccountNamespaces: RequireServiceAccountNamespaces,
+		SkipTerminatingNamespaces:       SkipTerminatingNamespaces,
+		WarnOnEmpty:                     WarnOnEmpty,
+		FailOnEmptyBinding:              FailOnEmptyBinding,
+		ResolveAggregatedClusterRoles:   ResolveAggregatedClusterRoles,
+		SourceRevision:                  SourceRevision,
+		RoleLabelRules:                  RoleLabelRules,
+		InheritDefinitionLabels:         InheritDefinitionLabels,
+		Values:                          Values,
+		SchemaMigrations:                SchemaMigrations,
+		DisallowedSubjectKinds:          DisallowedSubjectKinds,
+		SubjectNamePatterns:             SubjectNamePatterns,
+		GroupNameMap:                    GroupNameMap,
+		RoleAliases:                     RoleAliases,
+		ValidateRoleAliases:             ValidateRoleAliases,
+		EnabledResourceTypes:            EnabledResourceTypes,
+		PropagateNamespaceLabels:        PropagateNamespaceLabels,
+		DefaultUserAPIGroup:             DefaultUserAPIGroup,
+		DefaultGroupAPIGroup:            DefaultGroupAPIGroup,
+		MaxSubjectsPerBinding:           MaxSubjectsPerBinding,
+		HealthRegistry:                  r.HealthRegistry,
+		BackoffRegistry:                 r.BackoffRegistry,
+		ResolverMetrics:                 r.ResolverMetrics,
+		ownerRefs:                       r.ownerRefs,
+	}
+}
+
 func (r *Reconciler) reconcileServiceAccounts(requested *[]v1.ServiceAccount) error {
 	existing, err := r.Clientset.CoreV1().ServiceAccounts("").List(ListOptions)
 	if err != nil {
 		return err
 	}
 
+	frozen := frozenServiceAccounts(existing.Items)
 	matchingServiceAccounts := []v1.ServiceAccount{}
 	serviceAccountsToCreate := []v1.ServiceAccount{}
 
 	for _, requestedSA := range *requested {
+		if r.skipDryRun(ResourceTypeServiceAccount, &requestedSA.ObjectMeta) {
+			continue
+		}
+
 		alreadyExists := false
 		for _, existingSA := range existing.Items {
-			if saMatches(&existingSA, &requestedSA) {
+			if saIdentityMatches(&existingSA, &requestedSA) {
 				alreadyExists = true
 				matchingServiceAccounts = append(matchingServiceAccounts, existingSA)
 				break
@@ -111,55 +329,233 @@ func (r *Reconciler) reconcileServiceAccounts(requested *[]v1.ServiceAccount) er
 		}
 
 		if !alreadyExists {
+			if frozen[requestedSA.Namespace+"/"+requestedSA.Name] {
+				logrus.Infof("Not updating Service Account %v, it is frozen", requestedSA.Name)
+				continue
+			}
 			serviceAccountsToCreate = append(serviceAccountsToCreate, requestedSA)
 		} else {
 			logrus.Debugf("Service Account already exists %v", requestedSA.Name)
+			r.reconcileServiceAccountMeta(&matchingServiceAccounts[len(matchingServiceAccounts)-1], &requestedSA)
+			r.reconcileServiceAccountSecrets(&matchingServiceAccounts[len(matchingServiceAccounts)-1], &requestedSA)
+			updated := matchingServiceAccounts[len(matchingServiceAccounts)-1].DeepCopy()
+			r.updateStaleOwnerRefs(ResourceTypeServiceAccount, updated, &updated.ObjectMeta)
 		}
 	}
 
-	for _, existingSA := range existing.Items {
-		if reflect.DeepEqual(existingSA.OwnerReferences, r.ownerRefs) {
-			matchingRequest := false
-			for _, matchingSA := range matchingServiceAccounts {
-				if saMatches(&existingSA, &matchingSA) {
-					matchingRequest = true
-					break
-				}
-			}
+	orphanedServiceAccounts, err := r.orphanedServiceAccounts(existing.Items, matchingServiceAccounts)
+	if err != nil {
+		return err
+	}
 
-			if !matchingRequest {
-				logrus.Infof("Deleting Service Account %v", existingSA.Name)
-				err := r.Clientset.CoreV1().ServiceAccounts(existingSA.Namespace).Delete(existingSA.Name, &metav1.DeleteOptions{})
-				if err != nil {
-					logrus.Infof("Error deleting Service Account: %v", err)
-				}
-			} else {
-				logrus.Debugf("Matches requested Service Account %v", existingSA.Name)
-			}
+	for _, orphanedSA := range orphanedServiceAccounts {
+		if r.skipProtectedNamespace(ResourceTypeServiceAccount, &orphanedSA.ObjectMeta) {
+			continue
 		}
+
+		r.apply("delete", ResourceTypeServiceAccount, &orphanedSA.ObjectMeta, func() error {
+			logrus.Infof("Deleting Service Account %v", orphanedSA.Name)
+			return r.applier().Delete(context.TODO(), []runtime.Object{&orphanedSA})
+		})
 	}
 
 	for _, serviceAccountToCreate := range serviceAccountsToCreate {
-		logrus.Infof("Creating Service Account: %v", serviceAccountToCreate.Name)
-		_, err := r.Clientset.CoreV1().ServiceAccounts(serviceAccountToCreate.ObjectMeta.Namespace).Create(&serviceAccountToCreate)
-		if err != nil {
-			logrus.Errorf("Error creating Service Account: %v", err)
+		r.apply("create", ResourceTypeServiceAccount, &serviceAccountToCreate.ObjectMeta, func() error {
+			logrus.Infof("Creating Service Account: %v", serviceAccountToCreate.Name)
+			return r.applier().Apply(context.TODO(), []runtime.Object{&serviceAccountToCreate})
+		})
+	}
+
+	return nil
+}
+
+// reconcileTokenSecrets creates requested token Secrets that don't exist yet.
+// Token Secrets are never updated, and are only deleted by PruneStale or by
+// the token controller along with their Service Account.
+func (r *Reconciler) reconcileTokenSecrets(requested *[]v1.Secret) error {
+	if len(*requested) < 1 {
+		return nil
+	}
+
+	existing, err := r.Clientset.CoreV1().Secrets("").List(ListOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, requestedSecret := range *requested {
+		if r.skipDryRun(ResourceTypeSecret, &requestedSecret.ObjectMeta) {
+			continue
+		}
+
+		alreadyExists := false
+		for _, existingSecret := range existing.Items {
+			if existingSecret.Namespace == requestedSecret.Namespace && existingSecret.Name == requestedSecret.Name {
+				alreadyExists = true
+				break
+			}
+		}
+
+		if alreadyExists {
+			logrus.Debugf("Token Secret already exists %v", requestedSecret.Name)
+			continue
 		}
+
+		r.apply("create", ResourceTypeSecret, &requestedSecret.ObjectMeta, func() error {
+			logrus.Infof("Creating Token Secret: %v", requestedSecret.Name)
+			return r.applier().Apply(context.TODO(), []runtime.Object{&requestedSecret})
+		})
 	}
 
 	return nil
 }
 
+// orphanedServiceAccounts returns the existing Service Accounts owned by the
+// RBAC Definition being reconciled that are no longer requested by any of its
+// bindings. Service Accounts that are still subjects of bindings owned by
+// another RBAC Definition are kept.
+func (r *Reconciler) orphanedServiceAccounts(existing []v1.ServiceAccount, matching []v1.ServiceAccount) ([]v1.ServiceAccount, error) {
+	candidates := []v1.ServiceAccount{}
+
+	for _, existingSA := range existing {
+		if !reflect.DeepEqual(existingSA.OwnerReferences, r.ownerRefs) {
+			continue
+		}
+
+		matchingRequest := false
+		for _, matchingSA := range matching {
+			if saIdentityMatches(&existingSA, &matchingSA) {
+				matchingRequest = true
+				break
+			}
+		}
+
+		if matchingRequest {
+			logrus.Debugf("Matches requested Service Account %v", existingSA.Name)
+		} else {
+			candidates = append(candidates, existingSA)
+		}
+	}
+
+	if len(candidates) < 1 {
+		return candidates, nil
+	}
+
+	referenced, err := r.subjectsBoundByOthers()
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned := []v1.ServiceAccount{}
+	for _, candidate := range candidates {
+		if hasSubject(referenced, rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      candidate.Name,
+			Namespace: candidate.Namespace,
+		}) {
+			logrus.Infof("Keeping Service Account %v, still referenced by another RBAC Definition", candidate.Name)
+			continue
+		}
+
+		orphaned = append(orphaned, candidate)
+	}
+
+	return orphaned, nil
+}
+
+// subjectsBoundByOthers returns the subjects of bindings managed by RBAC
+// Manager that are owned by RBAC Definitions other than the one being
+// reconciled
+func (r *Reconciler) subjectsBoundByOthers() ([]rbacv1.Subject, error) {
+	subjects := []rbacv1.Subject{}
+
+	crbs, err := r.Clientset.RbacV1().ClusterRoleBindings().List(ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, crb := range crbs.Items {
+		if !reflect.DeepEqual(crb.OwnerReferences, r.ownerRefs) {
+			subjects = append(subjects, crb.Subjects...)
+		}
+	}
+
+	rbs, err := r.Clientset.RbacV1().RoleBindings("").List(ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rb := range rbs.Items {
+		if !reflect.DeepEqual(rb.OwnerReferences, r.ownerRefs) {
+			subjects = append(subjects, rb.Subjects...)
+		}
+	}
+
+	return subjects, nil
+}
+
+// reconcileServiceAccountSecrets adds any requested secret references that
+// are missing from an existing Service Account, secrets populated by
+// Kubernetes are preserved
+// reconcileServiceAccountMeta updates the labels and managed annotations of an
+// existing Service Account in place when they differ from the requested ones.
+// existingSA is updated to match so that later updates keep the changes.
+func (r *Reconciler) reconcileServiceAccountMeta(existingSA *v1.ServiceAccount, requestedSA *v1.ServiceAccount) {
+	if managedMetaMatches(&existingSA.ObjectMeta, &requestedSA.ObjectMeta) {
+		return
+	}
+
+	existingSA.ObjectMeta = withManagedMeta(&existingSA.ObjectMeta, &requestedSA.ObjectMeta)
+	existingSA.ResourceVersion = ""
+
+	r.apply("update", ResourceTypeServiceAccount, &existingSA.ObjectMeta, func() error {
+		logrus.Infof("Updating labels and annotations of Service Account: %v", existingSA.Name)
+		return r.applier().Apply(context.TODO(), []runtime.Object{existingSA.DeepCopy()})
+	})
+}
+
+func (r *Reconciler) reconcileServiceAccountSecrets(existingSA *v1.ServiceAccount, requestedSA *v1.ServiceAccount) {
+	missing := []v1.ObjectReference{}
+	for _, requestedSecret := range requestedSA.Secrets {
+		found := false
+		for _, existingSecret := range existingSA.Secrets {
+			if existingSecret.Name == requestedSecret.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, requestedSecret)
+		}
+	}
+
+	if len(missing) < 1 {
+		return
+	}
+
+	r.apply("update", ResourceTypeServiceAccount, &existingSA.ObjectMeta, func() error {
+		logrus.Infof("Updating secrets for Service Account: %v", existingSA.Name)
+		updatedSA := existingSA.DeepCopy()
+		updatedSA.Secrets = append(updatedSA.Secrets, missing...)
+		return r.applier().Apply(context.TODO(), []runtime.Object{updatedSA})
+	})
+}
+
 func (r *Reconciler) reconcileClusterRoleBindings(requested *[]rbacv1.ClusterRoleBinding) error {
 	existing, err := r.Clientset.RbacV1().ClusterRoleBindings().List(ListOptions)
 	if err != nil {
 		return err
 	}
 
+	frozen := frozenClusterRoleBindings(existing.Items)
 	matchingClusterRoleBindings := []rbacv1.ClusterRoleBinding{}
 	clusterRoleBindingsToCreate := []rbacv1.ClusterRoleBinding{}
 
 	for _, requestedCRB := range *requested {
+		if r.skipDryRun(ResourceTypeClusterRoleBinding, &requestedCRB.ObjectMeta) {
+			continue
+		}
+
 		alreadyExists := false
 		for _, existingCRB := range existing.Items {
 			if crbMatches(&existingCRB, &requestedCRB) {
@@ -170,9 +566,15 @@ func (r *Reconciler) reconcileClusterRoleBindings(requested *[]rbacv1.ClusterRol
 		}
 
 		if !alreadyExists {
+			if frozen[requestedCRB.Namespace+"/"+requestedCRB.Name] {
+				logrus.Infof("Not updating Cluster Role Binding %v, it is frozen", requestedCRB.Name)
+				continue
+			}
 			clusterRoleBindingsToCreate = append(clusterRoleBindingsToCreate, requestedCRB)
 		} else {
 			logrus.Debugf("Cluster Role Binding already exists %v", requestedCRB.Name)
+			updated := matchingClusterRoleBindings[len(matchingClusterRoleBindings)-1].DeepCopy()
+			r.updateStaleOwnerRefs(ResourceTypeClusterRoleBinding, updated, &updated.ObjectMeta)
 		}
 	}
 
@@ -187,11 +589,10 @@ func (r *Reconciler) reconcileClusterRoleBindings(requested *[]rbacv1.ClusterRol
 			}
 
 			if !matchingRequest {
-				logrus.Infof("Deleting Cluster Role Binding: %v", existingCRB.Name)
-				err := r.Clientset.RbacV1().ClusterRoleBindings().Delete(existingCRB.Name, &metav1.DeleteOptions{})
-				if err != nil {
-					logrus.Errorf("Error deleting Cluster Role Binding: %v", err)
-				}
+				r.apply("delete", ResourceTypeClusterRoleBinding, &existingCRB.ObjectMeta, func() error {
+					logrus.Infof("Deleting Cluster Role Binding: %v", existingCRB.Name)
+					return r.applier().Delete(context.TODO(), []runtime.Object{&existingCRB})
+				})
 			} else {
 				logrus.Debugf("Matches requested Cluster Role Binding: %v", existingCRB.Name)
 			}
@@ -199,11 +600,10 @@ func (r *Reconciler) reconcileClusterRoleBindings(requested *[]rbacv1.ClusterRol
 	}
 
 	for _, clusterRoleBindingToCreate := range clusterRoleBindingsToCreate {
-		logrus.Infof("Creating Cluster Role Binding: %v", clusterRoleBindingToCreate.Name)
-		_, err := r.Clientset.RbacV1().ClusterRoleBindings().Create(&clusterRoleBindingToCreate)
-		if err != nil {
-			logrus.Errorf("Error creating Cluster Role Binding: %v", err)
-		}
+		r.apply("create", ResourceTypeClusterRoleBinding, &clusterRoleBindingToCreate.ObjectMeta, func() error {
+			logrus.Infof("Creating Cluster Role Binding: %v", clusterRoleBindingToCreate.Name)
+			return r.applier().Apply(context.TODO(), []runtime.Object{&clusterRoleBindingToCreate})
+		})
 	}
 
 	return nil
@@ -215,10 +615,15 @@ func (r *Reconciler) reconcileRoleBindings(requested *[]rbacv1.RoleBinding) erro
 		return err
 	}
 
+	frozen := frozenRoleBindings(existing.Items)
 	matchingRoleBindings := []rbacv1.RoleBinding{}
 	roleBindingsToCreate := []rbacv1.RoleBinding{}
 
 	for _, requestedRB := range *requested {
+		if r.skipDryRun(ResourceTypeRoleBinding, &requestedRB.ObjectMeta) {
+			continue
+		}
+
 		alreadyExists := false
 		for _, existingRB := range existing.Items {
 			if rbMatches(&existingRB, &requestedRB) {
@@ -229,9 +634,15 @@ func (r *Reconciler) reconcileRoleBindings(requested *[]rbacv1.RoleBinding) erro
 		}
 
 		if !alreadyExists {
+			if frozen[requestedRB.Namespace+"/"+requestedRB.Name] {
+				logrus.Infof("Not updating Role Binding %v, it is frozen", requestedRB.Name)
+				continue
+			}
 			roleBindingsToCreate = append(roleBindingsToCreate, requestedRB)
 		} else {
 			logrus.Debugf("Role Binding already exists %v", requestedRB.Name)
+			updated := matchingRoleBindings[len(matchingRoleBindings)-1].DeepCopy()
+			r.updateStaleOwnerRefs(ResourceTypeRoleBinding, updated, &updated.ObjectMeta)
 		}
 	}
 
@@ -246,11 +657,14 @@ func (r *Reconciler) reconcileRoleBindings(requested *[]rbacv1.RoleBinding) erro
 			}
 
 			if !matchingRequest {
-				logrus.Infof("Deleting Role Binding %v", existingRB.Name)
-				err := r.Clientset.RbacV1().RoleBindings(existingRB.Namespace).Delete(existingRB.Name, &metav1.DeleteOptions{})
-				if err != nil {
-					logrus.Infof("Error deleting Role Binding: %v", err)
+				if r.skipProtectedNamespace(ResourceTypeRoleBinding, &existingRB.ObjectMeta) {
+					continue
 				}
+
+				r.apply("delete", ResourceTypeRoleBinding, &existingRB.ObjectMeta, func() error {
+					logrus.Infof("Deleting Role Binding %v", existingRB.Name)
+					return r.applier().Delete(context.TODO(), []runtime.Object{&existingRB})
+				})
 			} else {
 				logrus.Debugf("Matches requested Role Binding %v", existingRB.Name)
 			}
@@ -258,16 +672,172 @@ func (r *Reconciler) reconcileRoleBindings(requested *[]rbacv1.RoleBinding) erro
 	}
 
 	for _, roleBindingToCreate := range roleBindingsToCreate {
-		logrus.Infof("Creating Role Binding: %v", roleBindingToCreate.Name)
-		_, err := r.Clientset.RbacV1().RoleBindings(roleBindingToCreate.ObjectMeta.Namespace).Create(&roleBindingToCreate)
-		if err != nil {
-			logrus.Errorf("Error creating Role Binding: %v", err)
-		}
+		r.apply("create", ResourceTypeRoleBinding, &roleBindingToCreate.ObjectMeta, func() error {
+			logrus.Infof("Creating Role Binding: %v", roleBindingToCreate.Name)
+			return r.applier().Apply(context.TODO(), []runtime.Object{&roleBindingToCreate})
+		})
 	}
 
 	return nil
 }
 
+// apply makes a single change to a resource unless running in shadow mode.
+// Failures are collected rather than returned so that the remaining changes
+// are still attempted.
+func (r *Reconciler) apply(action string, resourceType string, meta *metav1.ObjectMeta, change func() error) {
+	if action != "create" && r.skipFrozen(action, resourceType, meta) {
+		return
+	}
+
+	if r.skipInShadowMode(action, resourceType, meta) {
+		return
+	}
+
+	r.attempted++
+
+	err := change()
+	if err != nil {
+		logrus.Errorf("Error trying to %v %v %v/%v: %v", action, resourceType, meta.Namespace, meta.Name, err)
+		r.failures = append(r.failures, ApplyFailure{
+			Action:       action,
+			ResourceType: resourceType,
+			Namespace:    meta.Namespace,
+			Name:         meta.Name,
+			Err:          err,
+		})
+	}
+}
+
+// updateStaleOwnerRefs points the owner references of an existing resource at
+// the RBAC Definition being reconciled when they refer to a previous instance
+// of it, so that the resource is still deleted along with the RBAC Definition
+func (r *Reconciler) updateStaleOwnerRefs(resourceType string, existing runtime.Object, meta *metav1.ObjectMeta) {
+	if !ownerRefsStale(&meta.OwnerReferences, &r.ownerRefs) {
+		return
+	}
+
+	meta.OwnerReferences = r.ownerRefs
+	meta.ResourceVersion = ""
+
+	r.apply("update", resourceType, meta, func() error {
+		logrus.Infof("Updating stale owner references of %v %v", resourceType, meta.Name)
+		return r.applier().Apply(context.TODO(), []runtime.Object{existing})
+	})
+}
+
+func (r *Reconciler) resetApplyResults() {
+	r.attempted = 0
+	r.failures = nil
+}
+
+// applyResults records an event summarizing the changes applied, returning
+// an ApplyError if any of them failed
+func (r *Reconciler) applyResults() error {
+	if len(r.failures) < 1 {
+		if r.attempted > 0 && r.Recorder != nil {
+			r.Recorder.Eventf(r.rbacDef, v1.EventTypeNormal, "Applied", "Applied %v changes", r.attempted)
+		}
+		return nil
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Eventf(r.rbacDef, v1.EventTypeWarning, "ApplyFailed", "Failed to apply %v of %v changes",
+			len(r.failures), r.attempted)
+	}
+
+	return &ApplyError{Attempted: r.attempted, Failures: r.failures}
+}
+
+// skipProtectedNamespace logs a warning and returns true if a resource that
+// is no longer requested is in a protected namespace and should be kept
+func (r *Reconciler) skipProtectedNamespace(resourceType string, meta *metav1.ObjectMeta) bool {
+	if !r.protectedNamespaces[meta.Namespace] {
+		return false
+	}
+
+	logrus.Warnf("Not deleting %v %v, %v is a protected namespace", resourceType, meta.Name, meta.Namespace)
+	return true
+}
+
+// skipFrozen logs and returns true if an existing resource has the
+// FreezeAnnotation, so that it is neither updated nor deleted
+func (r *Reconciler) skipFrozen(action string, resourceType string, meta *metav1.ObjectMeta) bool {
+	if meta.Annotations[FreezeAnnotation] != "true" {
+		return false
+	}
+
+	logrus.Infof("Skipping %v of frozen %v %v", action, resourceType, meta.Name)
+	return true
+}
+
+// frozenServiceAccounts returns the namespace/name of each frozen Service Account
+func frozenServiceAccounts(sas []v1.ServiceAccount) map[string]bool {
+	frozen := map[string]bool{}
+	for _, sa := range sas {
+		if sa.Annotations[FreezeAnnotation] == "true" {
+			frozen[sa.Namespace+"/"+sa.Name] = true
+		}
+	}
+	return frozen
+}
+
+// frozenClusterRoleBindings returns the namespace/name of each frozen Cluster
+// Role Binding, the namespace is always empty
+func frozenClusterRoleBindings(crbs []rbacv1.ClusterRoleBinding) map[string]bool {
+	frozen := map[string]bool{}
+	for _, crb := range crbs {
+		if crb.Annotations[FreezeAnnotation] == "true" {
+			frozen[crb.Namespace+"/"+crb.Name] = true
+		}
+	}
+	return frozen
+}
+
+// frozenRoleBindings returns the namespace/name of each frozen Role Binding
+func frozenRoleBindings(rbs []rbacv1.RoleBinding) map[string]bool {
+	frozen := map[string]bool{}
+	for _, rb := range rbs {
+		if rb.Annotations[FreezeAnnotation] == "true" {
+			frozen[rb.Namespace+"/"+rb.Name] = true
+		}
+	}
+	return frozen
+}
+
+// skipInShadowMode logs and records the change that would be made to a
+// resource, returning true if the change should be skipped
+func (r *Reconciler) skipInShadowMode(action string, resourceType string, meta *metav1.ObjectMeta) bool {
+	if !r.ShadowMode {
+		return false
+	}
+
+	logrus.Infof("Shadow mode, would %v %v: %v/%v", action, resourceType, meta.Namespace, meta.Name)
+
+	if r.Recorder != nil && r.rbacDef != nil {
+		r.Recorder.Eventf(r.rbacDef, v1.EventTypeNormal, "ShadowMode", "Would %v %v %v/%v",
+			action, resourceType, meta.Namespace, meta.Name)
+	}
+
+	return true
+}
+
+// skipDryRun logs and records a requested resource generated for an RBAC
+// Binding with dryRun set, returning true if it should not be applied
+func (r *Reconciler) skipDryRun(resourceType string, meta *metav1.ObjectMeta) bool {
+	if meta.Annotations[DryRunAnnotation] != "true" {
+		return false
+	}
+
+	logrus.Infof("Dry run, not applying %v: %v/%v", resourceType, meta.Namespace, meta.Name)
+
+	if r.Recorder != nil && r.rbacDef != nil {
+		r.Recorder.Eventf(r.rbacDef, v1.EventTypeNormal, "DryRun", "Would apply %v %v/%v",
+			resourceType, meta.Namespace, meta.Name)
+	}
+
+	return true
+}
+
 func rbacDefOwnerRefs(rbacDef *rbacmanagerv1beta1.RBACDefinition) []metav1.OwnerReference {
 	return []metav1.OwnerReference{
 		*metav1.NewControllerRef(rbacDef, schema.GroupVersionKind{
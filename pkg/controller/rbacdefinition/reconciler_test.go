@@ -15,14 +15,32 @@
 package rbacdefinition
 
 import (
-	"github.com/stretchr/testify/assert"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestReconcileRbacDefEmpty(t *testing.T) {
@@ -302,6 +320,1499 @@ func TestReconcileNamespaceChanges(t *testing.T) {
 	testEmptyExample(t, client, rbacDef.Name)
 }
 
+func TestReconcileServiceAccountSecrets(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "secrets-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		Secrets: []string{"ci-bot-token"},
+	}}
+
+	r := Reconciler{Clientset: client}
+	r.Reconcile(&rbacDef)
+	expectServiceAccountSecrets(t, client, "bots", "ci-bot", []string{"ci-bot-token"})
+
+	// simulate Kubernetes populating a token secret
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci-bot", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: "ci-bot-token-abcde"})
+	_, err = client.CoreV1().ServiceAccounts("bots").Update(sa)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rbacDef.RBACBindings[0].Secrets = []string{"ci-bot-token", "ci-bot-pull"}
+	r.Reconcile(&rbacDef)
+	expectServiceAccountSecrets(t, client, "bots", "ci-bot", []string{"ci-bot-token", "ci-bot-token-abcde", "ci-bot-pull"})
+
+	testEmptyExample(t, client, rbacDef.Name)
+}
+
+func expectServiceAccountSecrets(t *testing.T, client *fake.Clientset, namespace string, name string, expected []string) {
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := []string{}
+	for _, secret := range sa.Secrets {
+		actual = append(actual, secret.Name)
+	}
+
+	assert.ElementsMatch(t, expected, actual, "Expected secrets to match")
+}
+
+func TestReconcileShadowMode(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "shadow-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci-bot",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	// existing resources that would be deleted
+	r := Reconciler{Clientset: client}
+	r.Reconcile(&rbacDef)
+	client.ClearActions()
+
+	rbacDef.RBACBindings[0].Subjects[0].Name = "other-bot"
+	rbacDef.RBACBindings[0].ClusterRoleBindings[0].ClusterRole = "admin"
+	rbacDef.RBACBindings[0].RoleBindings[0].ClusterRole = "admin"
+
+	recorder := record.NewFakeRecorder(10)
+	r = Reconciler{Clientset: client, ShadowMode: true, Recorder: recorder}
+	err := r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	for _, action := range client.Actions() {
+		switch action.GetVerb() {
+		case "create", "update", "patch", "delete":
+			t.Fatalf("Unexpected %v of %v in shadow mode", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	// 3 creates and 3 deletes would have been made
+	assert.Len(t, recorder.Events, 6)
+
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shadow-example-ci-bot-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+	}})
+}
+
+func TestReconcileApplyFailures(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		rb := action.(k8stesting.CreateAction).GetObject().(*rbacv1.RoleBinding)
+		if rb.Namespace == "broken" {
+			return true, nil, errors.New("create failed")
+		}
+		return false, nil, nil
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "apply-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "broken",
+			ClusterRole: "edit",
+		}, {
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	recorder := record.NewFakeRecorder(10)
+	r := Reconciler{Clientset: client, Recorder: recorder}
+	err := r.Reconcile(&rbacDef)
+	if assert.True(t, IsApplyError(err), "Expected apply error, got %v", err) {
+		applyErr := err.(*ApplyError)
+		assert.Equal(t, 3, applyErr.Attempted)
+		assert.Len(t, applyErr.Failures, 1)
+		assert.Equal(t, "broken", applyErr.Failures[0].Namespace)
+		assert.Equal(t, "apply-example-devs-edit", applyErr.Failures[0].Name)
+	}
+
+	// changes after the failure are still applied
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "apply-example-devs-edit",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}})
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "apply-example-devs-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}})
+
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Equal(t, "Warning ApplyFailed Failed to apply 1 of 3 changes", <-recorder.Events)
+	}
+
+	result := handleReconcileError(&rbacDef, err, recorder)
+	assert.True(t, result.Requeue)
+
+	// only the failed change is retried once the failure is resolved
+	client.ReactionChain = client.ReactionChain[1:]
+	client.ClearActions()
+
+	err = r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	creates := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" {
+			creates++
+		}
+	}
+	assert.Equal(t, 1, creates)
+
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Equal(t, "Normal Applied Applied 1 changes", <-recorder.Events)
+	}
+}
+
+func TestReconcileOrphanedServiceAccounts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	newDefinition := func(name string, subjectNames ...string) rbacmanagerv1beta1.RBACDefinition {
+		rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+		rbacDef.Name = name
+		subjects := []rbacv1.Subject{}
+		for _, subjectName := range subjectNames {
+			subjects = append(subjects, rbacv1.Subject{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      subjectName,
+				Namespace: "bots",
+			})
+		}
+		rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "bots",
+			Subjects: subjects,
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				Namespace:   "bots",
+				ClusterRole: "edit",
+			}},
+		}}
+		return rbacDef
+	}
+
+	// ci-bot is created by the first definition and also used by the second
+	first := newDefinition("first", "ci-bot", "deploy-bot")
+	second := newDefinition("second", "ci-bot")
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&first))
+	assert.NoError(t, r.Reconcile(&second))
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy-bot", Namespace: "bots"},
+	}})
+
+	// removing both subjects from the first definition only orphans
+	// deploy-bot, ci-bot is still bound by the second definition
+	first = newDefinition("first", "other-bot")
+	assert.NoError(t, r.Reconcile(&first))
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "bots"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "other-bot", Namespace: "bots"},
+	}})
+
+	// once the second definition no longer binds it, ci-bot is orphaned
+	second = newDefinition("second", "other-bot")
+	assert.NoError(t, r.Reconcile(&second))
+	assert.NoError(t, r.Reconcile(&first))
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-bot", Namespace: "bots"},
+	}})
+}
+
+func TestReconcileProtectedNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "protected-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "bots",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "system-bot",
+			Namespace: "kube-system",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "kube-system",
+			ClusterRole: "view",
+		}, {
+			Namespace:   "bots",
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	ProtectedNamespaces = map[string]bool{"kube-system": true}
+	defer func() { ProtectedNamespaces = map[string]bool{} }()
+
+	// removing every binding only cleans up resources outside of the
+	// protected namespace
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "system-bot",
+			Namespace: "kube-system",
+		},
+	}})
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "protected-example-bots-view",
+			Namespace: "kube-system",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "system-bot",
+			Namespace: "kube-system",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+	}})
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "dry-run-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}, {
+		Name:   "staged",
+		DryRun: true,
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "admin",
+		}},
+	}}
+
+	recorder := record.NewFakeRecorder(10)
+	r := Reconciler{Clientset: client, Recorder: recorder}
+
+	// dry run resources are still parsed
+	p := r.newParser()
+	assert.NoError(t, p.Parse(rbacDef))
+	assert.Len(t, p.parsedServiceAccounts, 1)
+	assert.Len(t, p.parsedClusterRoleBindings, 1)
+	assert.Len(t, p.parsedRoleBindings, 2)
+
+	for _, rb := range p.parsedRoleBindings {
+		expected := ""
+		if rb.Name == "dry-run-example-staged-admin" {
+			expected = "true"
+		}
+		assert.Equal(t, expected, rb.Annotations[DryRunAnnotation], "Expected dry run annotation for %v", rb.Name)
+	}
+
+	// but only resources without dry run are applied
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	expectServiceAccounts(t, client, []corev1.ServiceAccount{})
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{})
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dry-run-example-devs-edit",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}})
+
+	// 3 dry run resources and the applied event
+	assert.Len(t, recorder.Events, 4)
+}
+
+func TestReconcileStaleOwnerReferences(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.UID = "original-uid"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	// the RBAC Definition is recreated with a new UID while the resources
+	// it generated are left behind
+	rbacDef.UID = "recreated-uid"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, crb.OwnerReferences, 1)
+	assert.EqualValues(t, "recreated-uid", crb.OwnerReferences[0].UID)
+
+	rb, err := client.RbacV1().RoleBindings("bots").Get("rbac-config-ci-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, rb.OwnerReferences, 1)
+	assert.EqualValues(t, "recreated-uid", rb.OwnerReferences[0].UID)
+
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci-bot", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, sa.OwnerReferences, 1)
+	assert.EqualValues(t, "recreated-uid", sa.OwnerReferences[0].UID)
+
+	// corrected resources are cleaned up by the current RBAC Definition
+	rbacDef.RBACBindings[0].ClusterRoleBindings = nil
+	rbacDef.RBACBindings[0].RoleBindings[0].ClusterRole = "view"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{})
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbac-config-ci-view", Namespace: "bots"},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		Subjects:   rbacDef.RBACBindings[0].Subjects,
+	}})
+}
+
+func TestReconcileFrozen(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	rb.Annotations = map[string]string{FreezeAnnotation: "true"}
+	_, err = client.RbacV1().RoleBindings("web").Update(rb)
+	assert.NoError(t, err)
+
+	// the frozen Role Binding keeps its original subjects while the Cluster
+	// Role Binding is updated
+	rbacDef.RBACBindings[0].Subjects[0].Name = "sue"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err = client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "joe", rb.Subjects[0].Name)
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "sue", crb.Subjects[0].Name)
+
+	// and isn't deleted once it is no longer requested
+	rbacDef.RBACBindings[0].RoleBindings = nil
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	_, err = client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestReconcileTokenSecrets(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "bots",
+			ClusterRole: "edit",
+		}},
+	}}
+
+	CreateTokenSecret = true
+	defer func() { CreateTokenSecret = false }()
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	secret, err := client.CoreV1().Secrets("bots").Get("ci-bot-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, corev1.SecretTypeServiceAccountToken, secret.Type)
+	assert.Equal(t, "ci-bot", secret.Annotations[corev1.ServiceAccountNameKey])
+	assert.Equal(t, r.ownerRefs, secret.OwnerReferences)
+
+	// the token filled in by the token controller is left alone
+	secret.Data = map[string][]byte{"token": []byte("abc")}
+	_, err = client.CoreV1().Secrets("bots").Update(secret)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	secret, err = client.CoreV1().Secrets("bots").Get("ci-bot-token", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), secret.Data["token"])
+}
+
+func TestReconcileSelectorMatches(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createNamespace(t, client, "web", map[string]string{"team": "dev"})
+	createNamespace(t, client, "api", map[string]string{"team": "dev"})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+			},
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	assert.Equal(t, []rbacmanagerv1beta1.SelectorMatch{
+		{RBACBinding: "devs", Role: "edit", Selector: "team=dev", Namespaces: 2},
+	}, rbacDef.Status.SelectorMatches)
+
+	// the count drops when a namespace no longer matches
+	assert.NoError(t, client.CoreV1().Namespaces().Delete("api", &metav1.DeleteOptions{}))
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	assert.Equal(t, int32(1), rbacDef.Status.SelectorMatches[0].Namespaces)
+}
+
+func TestReconcileTruncatedNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = strings.Repeat("a", 250)
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	recorder := record.NewFakeRecorder(10)
+	r := Reconciler{Clientset: client, Recorder: recorder}
+	err := r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	original := rbacDef.Name + "-devs-view"
+	truncated := truncateName(original, maxNameLength)
+	assert.Len(t, truncated, maxNameLength)
+
+	crbs, err := client.RbacV1().ClusterRoleBindings().List(ListOptions)
+	assert.NoError(t, err)
+	if assert.Len(t, crbs.Items, 1) {
+		assert.Equal(t, truncated, crbs.Items[0].Name)
+	}
+
+	// the truncation warning is followed by the applied changes
+	if assert.Len(t, recorder.Events, 2) {
+		assert.Equal(t, fmt.Sprintf("Warning NameTruncated Truncated generated name %v to %v", original, truncated), <-recorder.Events)
+	}
+
+	// names within the limit are left alone
+	assert.Equal(t, "rbac-config-devs-view", truncateName("rbac-config-devs-view", maxNameLength))
+}
+
+func TestReconcileManagedMetaChanges(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:       "ci",
+		ApplyOrder: 1,
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbacDef.RBACBindings[0].ApplyOrder = 2
+	client.ClearActions()
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-ci-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", rb.Annotations[ApplyOrderAnnotation])
+
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", sa.Annotations[ApplyOrderAnnotation])
+
+	// Service Accounts are updated in place rather than recreated
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "serviceaccounts" {
+			assert.NotEqual(t, "delete", action.GetVerb())
+		}
+	}
+
+	// nothing changes once the resources match
+	client.ClearActions()
+	assert.NoError(t, r.Reconcile(&rbacDef))
+	for _, action := range client.Actions() {
+		assert.Equal(t, "list", action.GetVerb())
+	}
+}
+
+func TestReconcileSubjectDisplayNameChanges(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		SubjectDisplayNames: map[string]string{"jsmith": "Jane Smith"},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "jsmith",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbacDef.RBACBindings[0].SubjectDisplayNames["jsmith"] = "Jane Doe"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", crb.Annotations[subjectDisplayAnnotation("jsmith")])
+
+	// removed display names are removed from existing resources
+	rbacDef.RBACBindings[0].SubjectDisplayNames = nil
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err = client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, crb.Annotations, subjectDisplayAnnotation("jsmith"))
+}
+
+func TestReconcileSourceRevision(t *testing.T) {
+	SourceRevision = "3f2c1e9"
+	defer func() { SourceRevision = "" }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	// a new revision is stamped on existing resources
+	SourceRevision = "8a7b6c5"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "8a7b6c5", crb.Annotations[SourceRevisionAnnotation])
+
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "8a7b6c5", sa.Annotations[SourceRevisionAnnotation])
+}
+
+func TestReconcileDefaultSubjectAPIGroups(t *testing.T) {
+	DefaultUserAPIGroup = "users.example.com"
+	DefaultGroupAPIGroup = "groups.example.com"
+	defer func() {
+		DefaultUserAPIGroup = ""
+		DefaultGroupAPIGroup = ""
+	}()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+			SubjectOverrides: map[string][]rbacv1.Subject{
+				"web": {{
+					Kind: rbacv1.GroupKind,
+					Name: "web-team",
+				}},
+			},
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind:     rbacv1.UserKind,
+		APIGroup: "users.example.com",
+		Name:     "joe",
+	}, {
+		Kind:     rbacv1.GroupKind,
+		APIGroup: "groups.example.com",
+		Name:     "web-team",
+	}}, rb.Subjects)
+}
+
+func TestReconcileMaxSubjectsPerBinding(t *testing.T) {
+	MaxSubjectsPerBinding = 2
+	defer func() { MaxSubjectsPerBinding = 0 }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "sue",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	// subject overrides count towards the limit
+	rbacDef.RBACBindings[0].RoleBindings[0].SubjectOverrides = map[string][]rbacv1.Subject{
+		"web": {{
+			Kind: rbacv1.UserKind,
+			Name: "kay",
+		}},
+	}
+	assert.EqualError(t, r.Reconcile(&rbacDef), "Binding rbac-config-devs-edit has 3 subjects, exceeding the limit of 2")
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, rb.Subjects, 2)
+}
+
+func TestReconcileGroupMembersConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "groups", Namespace: "rbac-manager"},
+		Data:       map[string]string{"platform": "joe, sue\nkay\n"},
+	})
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "platform",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "platform",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "external",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{
+		Clientset:             client,
+		GroupMembershipLister: &ConfigMapGroupMembershipLister{Clientset: client, Namespace: "rbac-manager", Name: "groups"},
+	}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-platform-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "kay",
+	}, {
+		Kind: rbacv1.GroupKind,
+		Name: "external",
+	}}, crb.Subjects)
+
+	// a missing ConfigMap fails the reconcile instead of granting the group
+	r.GroupMembershipLister = &ConfigMapGroupMembershipLister{Clientset: client, Namespace: "rbac-manager", Name: "missing"}
+	assert.Error(t, r.Reconcile(&rbacDef))
+}
+
+func TestReconcileCheckRequesterAccess(t *testing.T) {
+	CheckRequesterAccess = true
+	defer func() { CheckRequesterAccess = false }()
+
+	client := fake.NewSimpleClientset()
+	reviews := []authorizationv1.SubjectAccessReviewSpec{}
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, review.Spec)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Namespace == "web"
+		return true, review, nil
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespaces:  []string{"web", "kube-system"},
+		}},
+	}}
+
+	// RBAC Definitions must name their requester
+	r := Reconciler{Clientset: client}
+	assert.EqualError(t, r.Reconcile(&rbacDef), "RBAC Definition rbac-config is missing the rbac-manager/requester annotation required to check requester access")
+
+	rbacDef.Annotations = map[string]string{
+		RequesterAnnotation:       "joe",
+		RequesterGroupsAnnotation: "team-leads, oncall",
+	}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbList, err := client.RbacV1().RoleBindings("").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, rbList.Items, 1) {
+		assert.Equal(t, "web", rbList.Items[0].Namespace)
+	}
+
+	if assert.Len(t, reviews, 2) {
+		assert.Equal(t, "joe", reviews[0].User)
+		assert.Equal(t, []string{"team-leads", "oncall"}, reviews[0].Groups)
+	}
+}
+
+func TestReconcileValuesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac-manager-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "values.yaml")
+	err = ioutil.WriteFile(path, []byte("environment: prod\ndeployer:\n  name: ci\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Values, err = LoadValuesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Values = nil }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "deployers",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "{{ .Values.deployer.name }}",
+			Namespace: "{{ .Values.environment }}-bots",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "{{ .Values.environment }}-web",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	_, err = client.CoreV1().ServiceAccounts("prod-bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	rb, err := client.RbacV1().RoleBindings("prod-web").Get("rbac-config-deployers-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", rb.Subjects[0].Name)
+
+	_, err = LoadValuesFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestReconcileSchemaMigrations(t *testing.T) {
+	SchemaMigrations["v0"] = func(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+		rbacDef.RBACBindings[0].ClusterRoleBindings[0].ClusterRole = "view"
+		return nil
+	}
+	defer delete(SchemaMigrations, "v0")
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.SchemaVersion = "v0"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "read-only"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "view", crb.RoleRef.Name)
+
+	rbacDef.SchemaVersion = "v2"
+	assert.EqualError(t, r.Reconcile(&rbacDef), "Unsupported schema version v2 for RBAC Definition rbac-config, expected v1")
+}
+
+func TestReconcileDisallowedSubjectKinds(t *testing.T) {
+	DisallowedSubjectKinds = map[string]bool{rbacv1.UserKind: true}
+	defer func() { DisallowedSubjectKinds = map[string]bool{} }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbacDef.RBACBindings[0].Subjects = append(rbacDef.RBACBindings[0].Subjects, rbacv1.Subject{Kind: "user", Name: "joe"})
+	assert.EqualError(t, r.Reconcile(&rbacDef), "Disallowed subject kinds: User joe")
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, crb.Subjects, 1)
+}
+
+func TestReconcileSubjectNamePatterns(t *testing.T) {
+	SubjectNamePatterns = map[string]*regexp.Regexp{rbacv1.UserKind: regexp.MustCompile(`^[a-z]+\.[a-z]+$`)}
+	defer func() { SubjectNamePatterns = nil }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, Name: "joe.smith"},
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbacDef.RBACBindings[0].Subjects[0].Name = "jsmith"
+	assert.EqualError(t, r.Reconcile(&rbacDef), "Invalid subject names: User jsmith does not match ^[a-z]+\\.[a-z]+$")
+}
+
+func TestReconcileGroupNameMapFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac-manager-groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "groups.yaml")
+	err = ioutil.WriteFile(path, []byte("\"cn=platform,ou=groups\": platform\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	GroupNameMap, err = LoadGroupNameMapFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { GroupNameMap = nil }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "platform",
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.GroupKind, Name: "cn=platform,ou=groups"},
+			{Kind: rbacv1.GroupKind, Name: "devs"},
+		},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-platform-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, Name: "platform"},
+		{Kind: rbacv1.GroupKind, Name: "devs"},
+	}, crb.Subjects)
+
+	err = ioutil.WriteFile(path, []byte("platform: [a, b]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadGroupNameMapFile(path)
+	assert.Error(t, err)
+}
+
+func TestReconcileRoleAliases(t *testing.T) {
+	RoleAliases = map[string]string{"reader": "view"}
+	ValidateRoleAliases = true
+	defer func() {
+		RoleAliases = nil
+		ValidateRoleAliases = false
+	}()
+
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}})
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "reader"}},
+		RoleBindings:        []rbacmanagerv1beta1.RoleBinding{{ClusterRole: "edit", Namespace: "web"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-devs-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "view", crb.RoleRef.Name)
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "edit", rb.RoleRef.Name)
+
+	rbacDef.RBACBindings[0].RoleBindings[0].ClusterRole = "wrtier"
+	assert.EqualError(t, r.Reconcile(&rbacDef), "Unknown role alias or Cluster Role wrtier")
+}
+
+func TestReconcileEnabledResourceTypes(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "ci",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+		RoleBindings:        []rbacmanagerv1beta1.RoleBinding{{ClusterRole: "edit", Namespace: "web"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	// disabled types are neither generated nor deleted
+	EnabledResourceTypes = map[string]bool{ResourceTypeRoleBinding: true}
+	defer func() { EnabledResourceTypes = nil }()
+
+	rbacDef.RBACBindings[0].RoleBindings = append(rbacDef.RBACBindings[0].RoleBindings, rbacmanagerv1beta1.RoleBinding{ClusterRole: "edit", Namespace: "api"})
+	rbacDef.RBACBindings[0].ClusterRoleBindings[0].ClusterRole = "admin"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	_, err := client.RbacV1().RoleBindings("api").Get("rbac-config-ci-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	_, err = client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-admin", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	_, err = client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestReconcilePropagateNamespaceLabels(t *testing.T) {
+	PropagateNamespaceLabels = []string{"team", "cost-center"}
+	defer func() { PropagateNamespaceLabels = nil }()
+
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "bots", Labels: map[string]string{"team": "platform", "tier": "prod"}},
+	})
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "ci",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "bots"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{LabelKey: LabelValue, "team": "platform"}, sa.Labels)
+
+	// Service Accounts are relabeled when namespace labels change
+	ns, err := client.CoreV1().Namespaces().Get("bots", metav1.GetOptions{})
+	assert.NoError(t, err)
+	ns.Labels["team"] = "infra"
+	_, err = client.CoreV1().Namespaces().Update(ns)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	sa, err = client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "infra", sa.Labels["team"])
+}
+
+func TestReconcileSubjectsURLTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "roster",
+		SubjectsFromURL:     server.URL,
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	r := Reconciler{Clientset: client, HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}}
+	err := r.Reconcile(&rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Error fetching subjects from "+server.URL)
+	}
+}
+
+func TestReconcileRoleLabelRules(t *testing.T) {
+	RoleLabelRules = []RoleLabelRule{{
+		Pattern: regexp.MustCompile("admin"),
+		Labels:  map[string]string{"sensitivity": "high"},
+	}}
+	defer func() { RoleLabelRules = nil }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "admin",
+			Namespace:   "web",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-admin", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "high", rb.Labels["sensitivity"])
+
+	// changed rules relabel existing bindings
+	RoleLabelRules[0].Labels = map[string]string{"sensitivity": "critical"}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err = client.RbacV1().RoleBindings("web").Get("rbac-config-devs-admin", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "critical", rb.Labels["sensitivity"])
+}
+
+func TestReconcileInheritDefinitionLabels(t *testing.T) {
+	InheritDefinitionLabels = []string{"team"}
+	defer func() { InheritDefinitionLabels = nil }()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.Labels = map[string]string{"team": "web", "env": "prod"}
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ci",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci",
+			Namespace: "bots",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err := client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{LabelKey: LabelValue, "team": "web"}, crb.Labels)
+
+	// relabeling the RBAC Definition relabels existing resources
+	rbacDef.Labels["team"] = "api"
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	crb, err = client.RbacV1().ClusterRoleBindings().Get("rbac-config-ci-view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "api", crb.Labels["team"])
+
+	sa, err := client.CoreV1().ServiceAccounts("bots").Get("ci", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "api", sa.Labels["team"])
+}
+
+func TestReconcileSpecHashChanges(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "devs",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err := client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	desired := rb.Annotations[SpecHashAnnotation]
+	assert.Equal(t, SpecHash(rb), desired)
+
+	// a stored hash that differs from the desired hash is resynced
+	rb.Annotations[SpecHashAnnotation] = "stale"
+	_, err = client.RbacV1().RoleBindings("web").Update(rb)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rb, err = client.RbacV1().RoleBindings("web").Get("rbac-config-devs-edit", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, desired, rb.Annotations[SpecHashAnnotation])
+}
+
+func TestReconcileTeamNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createNamespace(t, client, "web-prod", map[string]string{"team": "web"})
+	createNamespace(t, client, "web-dev", map[string]string{"team": "web"})
+	createNamespace(t, client, "api", map[string]string{"team": "api"})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "web-team",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "web-devs",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Team:        "web",
+		}},
+	}}
+
+	// team bindings are rejected when team namespaces aren't configured
+	r := Reconciler{Clientset: client}
+	err := r.Reconcile(&rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--team-namespace-label")
+	}
+
+	r = Reconciler{
+		Clientset: client,
+		TeamNamespaceLister: &LabelTeamNamespaceLister{
+			NamespaceLister: &ClientsetNamespaceLister{Clientset: client},
+			Label:           "team",
+		},
+	}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbs, err := client.RbacV1().RoleBindings("").List(ListOptions)
+	assert.NoError(t, err)
+	namespaces := []string{}
+	for _, rb := range rbs.Items {
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.ElementsMatch(t, []string{"web-prod", "web-dev"}, namespaces)
+}
+
+func TestReconcileNodeNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "gpu-1", Labels: map[string]string{"pool": "gpu"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "general-1", Labels: map[string]string{"pool": "general"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "trainer", Namespace: "training"}, Spec: corev1.PodSpec{NodeName: "gpu-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: "inference"}, Spec: corev1.PodSpec{NodeName: "gpu-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "web"}, Spec: corev1.PodSpec{NodeName: "general-1"}},
+	)
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "ml",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "ml-engineers",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:  "view",
+			NodeSelector: map[string]string{"pool": "gpu"},
+		}},
+	}}
+
+	// node selectors are rejected when node namespaces aren't enabled
+	r := Reconciler{Clientset: client}
+	err := r.Reconcile(&rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--resolve-node-namespaces")
+	}
+
+	r = Reconciler{
+		Clientset:             client,
+		NodeNamespaceResolver: &ClientsetNodeNamespaceResolver{Clientset: client},
+	}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbs, err := client.RbacV1().RoleBindings("").List(ListOptions)
+	assert.NoError(t, err)
+	namespaces := []string{}
+	for _, rb := range rbs.Items {
+		namespaces = append(namespaces, rb.Namespace)
+	}
+	assert.ElementsMatch(t, []string{"training", "inference"}, namespaces)
+}
+
+func TestReconcileRequireResource(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createNamespace(t, client, "web", map[string]string{"team": "dev"})
+	createNamespace(t, client, "api", map[string]string{"team": "dev"})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "dbas",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: "dbas",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+			},
+			RequireResource: &rbacmanagerv1beta1.ResourceRequirement{
+				APIGroup:      "apps",
+				Kind:          "Deployment",
+				LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			},
+		}},
+	}}
+
+	// required resources are rejected when presence checks aren't enabled
+	r := Reconciler{Clientset: client}
+	err := r.Reconcile(&rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--resolve-resource-presence")
+	}
+
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	db := unstructured.Unstructured{}
+	db.SetNamespace("api")
+	db.SetName("postgres")
+	db.SetLabels(map[string]string{"app": "db"})
+	frontend := unstructured.Unstructured{}
+	frontend.SetNamespace("web")
+	frontend.SetName("frontend")
+	frontend.SetLabels(map[string]string{"app": "frontend"})
+
+	r = Reconciler{
+		Clientset: client,
+		ResourcePresenceResolver: &DynamicResourcePresenceResolver{
+			Client: fakeResourceClient{resources: map[schema.GroupVersionResource][]unstructured.Unstructured{
+				deployments: {db, frontend},
+			}},
+			Mapper: mapper,
+		},
+	}
+	assert.NoError(t, r.Reconcile(&rbacDef))
+
+	rbs, err := client.RbacV1().RoleBindings("").List(ListOptions)
+	assert.NoError(t, err)
+	if assert.Len(t, rbs.Items, 1) {
+		assert.Equal(t, "api", rbs.Items[0].Namespace)
+	}
+}
+
 func newReconcileTest(t *testing.T, client *fake.Clientset, rbacDef rbacmanagerv1beta1.RBACDefinition, expectedRb []rbacv1.RoleBinding, expectedCrb []rbacv1.ClusterRoleBinding, expectedSa []corev1.ServiceAccount) {
 	r := Reconciler{Clientset: client}
 	r.Reconcile(&rbacDef)
@@ -0,0 +1,95 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// RedundancyFinding describes a subject granted the same role by more than one
+// generated binding. Namespace is empty for grants by Cluster Role Bindings.
+type RedundancyFinding struct {
+	Subject   rbacv1.Subject
+	RoleRef   rbacv1.RoleRef
+	Namespace string
+	Bindings  []string
+}
+
+// RedundancyReport lists subjects granted the same role more than once by the
+// bindings generated for an RBAC Definition, which often points to access
+// granted by accident. A Role Binding to a Cluster Role the subject is already
+// granted by a Cluster Role Binding is reported in the Role Binding's
+// namespace. Nothing is reported when the RBAC Definition can't be parsed.
+func (p *Parser) RedundancyReport(rbacDef rbacmanagerv1beta1.RBACDefinition) []RedundancyFinding {
+	err := p.Parse(rbacDef)
+	if err != nil {
+		logrus.Errorf("Error parsing RBAC Definition %v for redundancy report: %v", rbacDef.Name, err)
+		return nil
+	}
+
+	findings := []RedundancyFinding{}
+	index := map[string]int{}
+
+	grant := func(subject rbacv1.Subject, roleRef rbacv1.RoleRef, namespace string, binding string) {
+		key := fmt.Sprintf("%v/%v/%v/%v/%v/%v/%v", subject.Kind, subject.Namespace, subject.Name, roleRef.Kind, roleRef.Name, roleRef.APIGroup, namespace)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(findings)
+			findings = append(findings, RedundancyFinding{
+				Subject:   subject,
+				RoleRef:   roleRef,
+				Namespace: namespace,
+			})
+			i = len(findings) - 1
+		}
+		findings[i].Bindings = appendUnique(findings[i].Bindings, binding)
+	}
+
+	clusterGrants := map[string][]string{}
+	for _, crb := range p.parsedClusterRoleBindings {
+		for _, subject := range crb.Subjects {
+			grant(subject, crb.RoleRef, "", crb.Name)
+			key := fmt.Sprintf("%v/%v/%v/%v", subject.Kind, subject.Namespace, subject.Name, crb.RoleRef.Name)
+			if crb.RoleRef.Kind == "ClusterRole" {
+				clusterGrants[key] = append(clusterGrants[key], crb.Name)
+			}
+		}
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		for _, subject := range rb.Subjects {
+			if rb.RoleRef.Kind == "ClusterRole" {
+				key := fmt.Sprintf("%v/%v/%v/%v", subject.Kind, subject.Namespace, subject.Name, rb.RoleRef.Name)
+				for _, crbName := range clusterGrants[key] {
+					grant(subject, rb.RoleRef, rb.Namespace, crbName)
+				}
+			}
+			grant(subject, rb.RoleRef, rb.Namespace, rb.Name)
+		}
+	}
+
+	redundant := []RedundancyFinding{}
+	for _, finding := range findings {
+		if len(finding.Bindings) > 1 {
+			redundant = append(redundant, finding)
+		}
+	}
+
+	return redundant
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRedundancyReport(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	joe := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "joe"}
+	sue := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "sue"}
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{joe, sue},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespaces:  []string{"web", "api"},
+		}},
+	}, {
+		Name:     "web-oncall",
+		Subjects: []rbacv1.Subject{joe},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			Namespace:   "web",
+		}, {
+			ClusterRole: "view",
+			Namespace:   "api",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	findings := p.RedundancyReport(rbacDef)
+
+	assert.Equal(t, []RedundancyFinding{{
+		Subject:   joe,
+		RoleRef:   rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+		Namespace: "web",
+		Bindings:  []string{"rbac-config-devs-edit", "rbac-config-web-oncall-edit"},
+	}, {
+		Subject:   joe,
+		RoleRef:   rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		Namespace: "api",
+		Bindings:  []string{"rbac-config-devs-view", "rbac-config-web-oncall-view"},
+	}}, findings)
+
+	rbacDef.RBACBindings[1].RoleBindings = nil
+	p = Parser{Clientset: client}
+	assert.Empty(t, p.RedundancyReport(rbacDef))
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DangerousClusterRoles are Cluster Roles that are always flagged as a privilege escalation risk
+var DangerousClusterRoles = []string{"cluster-admin"}
+
+// RiskFinding describes a generated binding that may allow privilege escalation
+type RiskFinding struct {
+	BindingKind string
+	BindingName string
+	Namespace   string
+	RoleRef     rbacv1.RoleRef
+	Reason      string
+}
+
+// RiskReport flags bindings generated for an RBAC Definition that reference
+// dangerous Cluster Roles or roles granting wildcard verbs or resources.
+// Role rules are only inspected when they can be retrieved with the Clientset.
+func (p *Parser) RiskReport(rbacDef rbacmanagerv1beta1.RBACDefinition) ([]RiskFinding, error) {
+	err := p.Parse(rbacDef)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []RiskFinding{}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		reason, err := p.roleRisk(crb.RoleRef, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if reason != "" {
+			findings = append(findings, RiskFinding{
+				BindingKind: ResourceTypeClusterRoleBinding,
+				BindingName: crb.Name,
+				RoleRef:     crb.RoleRef,
+				Reason:      reason,
+			})
+		}
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		reason, err := p.roleRisk(rb.RoleRef, rb.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason != "" {
+			findings = append(findings, RiskFinding{
+				BindingKind: ResourceTypeRoleBinding,
+				BindingName: rb.Name,
+				Namespace:   rb.Namespace,
+				RoleRef:     rb.RoleRef,
+				Reason:      reason,
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// roleRisk returns the reason a referenced role is risky, or an empty string
+func (p *Parser) roleRisk(roleRef rbacv1.RoleRef, namespace string) (string, error) {
+	if roleRef.APIGroup != "" && roleRef.APIGroup != rbacv1.GroupName {
+		return "", nil
+	}
+
+	if roleRef.Kind == "ClusterRole" {
+		for _, dangerous := range DangerousClusterRoles {
+			if roleRef.Name == dangerous {
+				return fmt.Sprintf("References dangerous ClusterRole %v", roleRef.Name), nil
+			}
+		}
+	}
+
+	if p.Clientset == nil {
+		return "", nil
+	}
+
+	var rules []rbacv1.PolicyRule
+
+	if roleRef.Kind == "ClusterRole" {
+		clusterRole, err := p.Clientset.RbacV1().ClusterRoles().Get(roleRef.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		rules = clusterRole.Rules
+	} else if roleRef.Kind == "Role" {
+		role, err := p.Clientset.RbacV1().Roles(namespace).Get(roleRef.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		rules = role.Rules
+	}
+
+	for _, rule := range rules {
+		if containsWildcard(rule.Verbs) {
+			return fmt.Sprintf("%v %v grants wildcard verbs", roleRef.Kind, roleRef.Name), nil
+		}
+
+		if containsWildcard(rule.Resources) {
+			return fmt.Sprintf("%v %v grants wildcard resources", roleRef.Kind, roleRef.Name), nil
+		}
+	}
+
+	return "", nil
+}
+
+func containsWildcard(values []string) bool {
+	for _, value := range values {
+		if value == rbacv1.VerbAll {
+			return true
+		}
+	}
+	return false
+}
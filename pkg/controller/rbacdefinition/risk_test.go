@@ -0,0 +1,132 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRiskReport(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	createClusterRole(t, client, "everything", []rbacv1.PolicyRule{{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"*"},
+	}})
+	createClusterRole(t, client, "all-resources", []rbacv1.PolicyRule{{
+		APIGroups: []string{"apps"},
+		Resources: []string{"*"},
+		Verbs:     []string{"get"},
+	}})
+	createClusterRole(t, client, "pod-reader", []rbacv1.PolicyRule{{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get", "list", "watch"},
+	}})
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "cluster-admin",
+		}, {
+			ClusterRole: "pod-reader",
+		}, {
+			ClusterRole: "all-resources",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace:   "web",
+			ClusterRole: "everything",
+		}, {
+			Namespace:   "web",
+			ClusterRole: "missing",
+		}},
+	}}
+
+	p := Parser{Clientset: client}
+	findings, err := p.RiskReport(rbacDef)
+	if err != nil {
+		t.Fatalf("Error generating risk report: %v", err)
+	}
+
+	assert.Len(t, findings, 3)
+
+	flagged := map[string]RiskFinding{}
+	for _, finding := range findings {
+		flagged[finding.RoleRef.Name] = finding
+	}
+
+	assert.Contains(t, flagged, "cluster-admin")
+	assert.Contains(t, flagged, "all-resources")
+	assert.Contains(t, flagged, "everything")
+	assert.NotContains(t, flagged, "pod-reader")
+	assert.Equal(t, ResourceTypeRoleBinding, flagged["everything"].BindingKind)
+	assert.Equal(t, "web", flagged["everything"].Namespace)
+	assert.Equal(t, "rbac-config-devs-cluster-admin", flagged["cluster-admin"].BindingName)
+}
+
+func TestRiskReportReusedParser(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "admins",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "cluster-admin",
+		}},
+	}}
+
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+
+	_, err := p.AuditReport(rbacDef)
+	if err != nil {
+		t.Fatalf("Error generating audit report: %v", err)
+	}
+
+	findings, err := p.RiskReport(rbacDef)
+	if err != nil {
+		t.Fatalf("Error generating risk report: %v", err)
+	}
+
+	assert.Len(t, findings, 1)
+
+	findings, err = p.RiskReport(rbacDef)
+	assert.NoError(t, err)
+	assert.Len(t, findings, 1)
+}
+
+func createClusterRole(t *testing.T, client *fake.Clientset, name string, rules []rbacv1.PolicyRule) {
+	_, err := client.RbacV1().ClusterRoles().Create(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	})
+
+	if err != nil {
+		t.Fatalf("Error creating cluster role %v", err)
+	}
+}
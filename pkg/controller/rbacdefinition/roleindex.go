@@ -0,0 +1,162 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"sort"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RoleKey identifies a Cluster Role, or a Role in a namespace
+type RoleKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// RoleIndex maps the roles referenced by generated bindings to the RBAC
+// Definitions that generated them, so that changes to a role can requeue the
+// RBAC Definitions that depend on it. It is safe for concurrent use.
+type RoleIndex struct {
+	mutex       sync.RWMutex
+	definitions map[RoleKey]map[string]bool
+	roles       map[string][]RoleKey
+}
+
+// Roles is the index the controllers record referenced roles to
+var Roles = NewRoleIndex()
+
+// NewRoleIndex returns an empty RoleIndex
+func NewRoleIndex() *RoleIndex {
+	return &RoleIndex{
+		definitions: map[RoleKey]map[string]bool{},
+		roles:       map[string][]RoleKey{},
+	}
+}
+
+// Record replaces the roles referenced by an RBAC Definition
+func (i *RoleIndex) Record(name string, roles []RoleKey) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(name)
+
+	for _, role := range roles {
+		if i.definitions[role] == nil {
+			i.definitions[role] = map[string]bool{}
+		}
+		i.definitions[role][name] = true
+	}
+	i.roles[name] = roles
+}
+
+// Remove forgets an RBAC Definition, used once it has been deleted
+func (i *RoleIndex) Remove(name string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(name)
+}
+
+func (i *RoleIndex) remove(name string) {
+	for _, role := range i.roles[name] {
+		delete(i.definitions[role], name)
+		if len(i.definitions[role]) < 1 {
+			delete(i.definitions, role)
+		}
+	}
+	delete(i.roles, name)
+}
+
+// Definitions returns the sorted names of the RBAC Definitions that
+// reference a role
+func (i *RoleIndex) Definitions(role RoleKey) []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	names := []string{}
+	for name := range i.definitions[role] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Requests returns a mapper from events for roles of a kind to requests for
+// the RBAC Definitions that reference them
+func (i *RoleIndex) Requests(kind string) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		role := RoleKey{Kind: kind, Name: obj.Meta.GetName()}
+		if kind == "Role" {
+			role.Namespace = obj.Meta.GetNamespace()
+		}
+
+		requests := []reconcile.Request{}
+		for _, name := range i.Definitions(role) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		}
+
+		return requests
+	}
+}
+
+// ReferencedRoles returns the Cluster Roles and Roles referenced by the
+// bindings generated by the last call to Parse, along with the Roles checked
+// for Role Bindings that require them, whether or not they were present. Role
+// refs to custom role kinds outside of the RBAC API group are left out.
+func (p *Parser) ReferencedRoles() []RoleKey {
+	seen := map[RoleKey]bool{}
+	roles := []RoleKey{}
+	add := func(roleRef rbacv1.RoleRef, namespace string) {
+		if roleRef.APIGroup != "" && roleRef.APIGroup != rbacv1.GroupName {
+			return
+		}
+
+		role := RoleKey{Kind: roleRef.Kind, Name: roleRef.Name}
+		if roleRef.Kind == "Role" {
+			role.Namespace = namespace
+		} else if roleRef.Kind != "ClusterRole" {
+			return
+		}
+
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	for _, crb := range p.parsedClusterRoleBindings {
+		add(crb.RoleRef, "")
+	}
+
+	for _, rb := range p.parsedRoleBindings {
+		add(rb.RoleRef, rb.Namespace)
+	}
+
+	for _, role := range p.checkedRoles {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRoleIndex(t *testing.T) {
+	index := NewRoleIndex()
+	view := RoleKey{Kind: "ClusterRole", Name: "view"}
+	edit := RoleKey{Kind: "ClusterRole", Name: "edit"}
+
+	index.Record("web", []RoleKey{view, edit})
+	index.Record("api", []RoleKey{view})
+	assert.Equal(t, []string{"api", "web"}, index.Definitions(view))
+	assert.Equal(t, []string{"web"}, index.Definitions(edit))
+
+	// recording an RBAC Definition again replaces the roles it references
+	index.Record("web", []RoleKey{view})
+	assert.Equal(t, []string{}, index.Definitions(edit))
+
+	index.Remove("api")
+	assert.Equal(t, []string{"web"}, index.Definitions(view))
+}
+
+func TestReconcileRoleIndex(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	index := NewRoleIndex()
+
+	rbacDefs := []rbacmanagerv1beta1.RBACDefinition{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		RBACBindings: []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "web-devs"}},
+			ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+				ClusterRole: "view",
+			}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				Role:      "deployer",
+				Namespace: "web",
+			}},
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "api"},
+		RBACBindings: []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "api-devs"}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				ClusterRole: "view",
+				Namespace:   "api",
+			}, {
+				Role:      "deployer",
+				Namespace: "api",
+			}},
+		}},
+	}}
+
+	for i := range rbacDefs {
+		r := Reconciler{Clientset: client, RoleIndex: index}
+		err := r.Reconcile(&rbacDefs[i])
+		assert.NoError(t, err)
+	}
+
+	requests := func(kind string, namespace string, name string) []reconcile.Request {
+		meta := &metav1.ObjectMeta{Name: name, Namespace: namespace}
+		return index.Requests(kind)(handler.MapObject{Meta: meta})
+	}
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}
+	}
+
+	assert.Equal(t, []reconcile.Request{request("api"), request("web")}, requests("ClusterRole", "", "view"))
+	assert.Equal(t, []reconcile.Request{request("web")}, requests("Role", "web", "deployer"))
+	assert.Equal(t, []reconcile.Request{request("api")}, requests("Role", "api", "deployer"))
+
+	// roles that aren't referenced don't requeue anything
+	assert.Equal(t, []reconcile.Request{}, requests("ClusterRole", "", "admin"))
+	assert.Equal(t, []reconcile.Request{}, requests("Role", "db", "deployer"))
+
+	// a Role sharing the name of a referenced Cluster Role is unrelated
+	assert.Equal(t, []reconcile.Request{}, requests("Role", "web", "view"))
+}
+
+func TestReconcileRoleIndexMissingRole(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	index := NewRoleIndex()
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		RBACBindings: []rbacmanagerv1beta1.RBACBinding{{
+			Name:     "devs",
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "web-devs"}},
+			RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+				Role:               "deployer",
+				Namespace:          "web",
+				RequireRolePresent: true,
+			}},
+		}},
+	}
+
+	r := Reconciler{Clientset: client, RoleIndex: index}
+	err := r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	rbList, err := client.RbacV1().RoleBindings("web").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, rbList.Items, 0)
+
+	// creating the missing Role requeues the RBAC Definition
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "web"}}
+	_, err = client.RbacV1().Roles("web").Create(role)
+	assert.NoError(t, err)
+
+	requests := index.Requests("Role")(handler.MapObject{Meta: &role.ObjectMeta})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "web"}}}, requests)
+
+	err = r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	rbList, err = client.RbacV1().RoleBindings("web").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, rbList.Items, 1)
+	assert.Equal(t, []string{"web"}, index.Definitions(RoleKey{Kind: "Role", Namespace: "web", Name: "deployer"}))
+}
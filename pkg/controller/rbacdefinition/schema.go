@@ -0,0 +1,57 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+)
+
+// CurrentSchemaVersion is the RBAC Definition schema version understood by
+// the parser, RBAC Definitions without a schema version are assumed to use it
+const CurrentSchemaVersion = "v1"
+
+// SchemaMigration converts an RBAC Definition written for an older schema
+// version to the current one
+type SchemaMigration func(rbacDef *rbacmanagerv1beta1.RBACDefinition) error
+
+// SchemaMigrations are the migrations used by the controllers, keyed by the
+// schema version they convert from. Embedders register migrations here
+// before starting the manager.
+var SchemaMigrations = map[string]SchemaMigration{}
+
+// migrateSchema returns an RBAC Definition in the current schema version,
+// applying the registered migration for its version if needed. The RBAC
+// Definition is copied first so that migrations can't change the original.
+func (p *Parser) migrateSchema(rbacDef rbacmanagerv1beta1.RBACDefinition) (rbacmanagerv1beta1.RBACDefinition, error) {
+	if rbacDef.SchemaVersion == "" || rbacDef.SchemaVersion == CurrentSchemaVersion {
+		return rbacDef, nil
+	}
+
+	migration, ok := p.SchemaMigrations[rbacDef.SchemaVersion]
+	if !ok {
+		return rbacDef, fmt.Errorf("Unsupported schema version %v for RBAC Definition %v, expected %v", rbacDef.SchemaVersion, rbacDef.Name, CurrentSchemaVersion)
+	}
+
+	migrated := rbacDef.DeepCopy()
+	err := migration(migrated)
+	if err != nil {
+		return rbacDef, fmt.Errorf("Error migrating RBAC Definition %v from schema version %v: %v", rbacDef.Name, rbacDef.SchemaVersion, err)
+	}
+	migrated.SchemaVersion = CurrentSchemaVersion
+
+	return *migrated, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSchemaVersion(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "view"}},
+	}}
+
+	for _, version := range []string{"", CurrentSchemaVersion} {
+		rbacDef.SchemaVersion = version
+		p := Parser{Clientset: fake.NewSimpleClientset()}
+		assert.NoError(t, p.Parse(rbacDef), "Expected schema version %q to be supported", version)
+		assert.Len(t, p.parsedClusterRoleBindings, 1)
+	}
+
+	rbacDef.SchemaVersion = "v2"
+	p := Parser{Clientset: fake.NewSimpleClientset()}
+	assert.EqualError(t, p.Parse(rbacDef), "Unsupported schema version v2 for RBAC Definition rbac-config, expected v1")
+	assert.Empty(t, p.parsedClusterRoleBindings)
+
+	// definitions without bindings are still checked
+	empty := rbacmanagerv1beta1.RBACDefinition{SchemaVersion: "v2"}
+	assert.Error(t, p.Parse(empty))
+}
+
+func TestParseSchemaMigration(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.SchemaVersion = "v0"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:                "devs",
+		Subjects:            []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "joe"}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{ClusterRole: "read-only"}},
+	}}
+
+	// v0 called the view Cluster Role read-only
+	p := Parser{
+		Clientset: fake.NewSimpleClientset(),
+		SchemaMigrations: map[string]SchemaMigration{
+			"v0": func(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+				for i := range rbacDef.RBACBindings {
+					for j := range rbacDef.RBACBindings[i].ClusterRoleBindings {
+						crb := &rbacDef.RBACBindings[i].ClusterRoleBindings[j]
+						if crb.ClusterRole == "read-only" {
+							crb.ClusterRole = "view"
+						}
+					}
+				}
+				return nil
+			},
+			"v0.1": func(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+				return errors.New("No longer supported")
+			},
+		},
+	}
+
+	assert.NoError(t, p.Parse(rbacDef))
+	if assert.Len(t, p.parsedClusterRoleBindings, 1) {
+		assert.Equal(t, "view", p.parsedClusterRoleBindings[0].RoleRef.Name)
+	}
+
+	// the original RBAC Definition is left unchanged
+	assert.Equal(t, "read-only", rbacDef.RBACBindings[0].ClusterRoleBindings[0].ClusterRole)
+
+	rbacDef.SchemaVersion = "v0.1"
+	assert.EqualError(t, p.Parse(rbacDef), "Error migrating RBAC Definition rbac-config from schema version v0.1: No longer supported")
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SelectorIndex maps the namespace label keys selected on by RBAC
+// Definitions to the RBAC Definitions selecting on them, along with a
+// generation token for each namespace computed from the values of those
+// keys. Comparing tokens detects label transitions, so that only the RBAC
+// Definitions selecting on a changed label need to be reconciled. RBAC
+// Definitions that also select on namespace annotations, owners, or fields
+// are reconciled whenever any of those change. It is safe for concurrent use.
+type SelectorIndex struct {
+	mutex       sync.RWMutex
+	definitions map[string]map[string]bool
+	keys        map[string][]string
+	metadata    map[string]bool
+	namespaces  map[string]namespaceGeneration
+	rollbacks   map[string]*namespaceGeneration
+}
+
+// namespaceGeneration is the last seen generation token of a namespace, the
+// values of the indexed label keys it was computed from, and a token for the
+// namespace metadata other than labels that RBAC Definitions can select on
+type namespaceGeneration struct {
+	token    string
+	values   map[string]string
+	metadata string
+}
+
+// Selectors is the index the controllers record selector keys to
+var Selectors = NewSelectorIndex()
+
+// NewSelectorIndex returns an empty SelectorIndex
+func NewSelectorIndex() *SelectorIndex {
+	return &SelectorIndex{
+		definitions: map[string]map[string]bool{},
+		keys:        map[string][]string{},
+		metadata:    map[string]bool{},
+		namespaces:  map[string]namespaceGeneration{},
+		rollbacks:   map[string]*namespaceGeneration{},
+	}
+}
+
+// Record replaces the label keys selected on by an RBAC Definition, and
+// whether it selects on namespace annotations, owners, or fields
+func (i *SelectorIndex) Record(name string, keys []string, selectsMetadata bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(name)
+
+	for _, key := range keys {
+		if i.definitions[key] == nil {
+			i.definitions[key] = map[string]bool{}
+		}
+		i.definitions[key][name] = true
+	}
+	i.keys[name] = keys
+
+	if selectsMetadata {
+		i.metadata[name] = true
+	}
+}
+
+// Remove forgets an RBAC Definition, used once it has been deleted
+func (i *SelectorIndex) Remove(name string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(name)
+}
+
+func (i *SelectorIndex) remove(name string) {
+	for _, key := range i.keys[name] {
+		delete(i.definitions[key], name)
+		if len(i.definitions[key]) < 1 {
+			delete(i.definitions, key)
+		}
+	}
+	delete(i.keys, name)
+	delete(i.metadata, name)
+}
+
+// Definitions returns the sorted names of the RBAC Definitions that select on
+// a label key
+func (i *SelectorIndex) Definitions(key string) []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	names := []string{}
+	for name := range i.definitions[key] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GenerationToken returns a token for a namespace's labels that only changes
+// when the value of an indexed label key changes
+func (i *SelectorIndex) GenerationToken(namespaceLabels map[string]string) string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.generation(namespaceLabels).token
+}
+
+func (i *SelectorIndex) generation(namespaceLabels map[string]string) namespaceGeneration {
+	keys := []string{}
+	values := map[string]string{}
+	for key := range i.definitions {
+		if value, ok := namespaceLabels[key]; ok {
+			keys = append(keys, key)
+			values[key] = value
+		}
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%v=%v\n", key, values[key])
+	}
+
+	return namespaceGeneration{token: fmt.Sprintf("%016x", hash.Sum64()), values: values}
+}
+
+// metadataToken returns a token for the annotations, owner references, and
+// phase of a namespace, the metadata used by annotationExpression,
+// namespaceOwner, and namespaceFieldSelector
+func metadataToken(namespace *v1.Namespace) string {
+	keys := []string{}
+	for key := range namespace.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "annotation %v=%v\n", key, namespace.Annotations[key])
+	}
+	for _, ownerRef := range namespace.OwnerReferences {
+		fmt.Fprintf(hash, "owner %v/%v/%v/%v\n", ownerRef.APIVersion, ownerRef.Kind, ownerRef.Name, ownerRef.UID)
+	}
+	fmt.Fprintf(hash, "phase %v\n", namespace.Status.Phase)
+
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// Transition records the labels and metadata of a namespace and returns the
+// sorted names of the RBAC Definitions affected by a change to them since the
+// last call. Namespaces seen for the first time affect every indexed RBAC
+// Definition, since they may also be matched by catch-all Role Bindings,
+// exclude selectors, or namespace patterns. The transition is undone by
+// Rollback if reconciling the affected RBAC Definitions fails.
+func (i *SelectorIndex) Transition(namespace *v1.Namespace) []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	current := i.generation(namespace.Labels)
+	current.metadata = metadataToken(namespace)
+	previous, seen := i.namespaces[namespace.Name]
+	i.namespaces[namespace.Name] = current
+
+	i.rollbacks[namespace.Name] = nil
+	if seen {
+		i.rollbacks[namespace.Name] = &previous
+	}
+
+	affected := map[string]bool{}
+	if !seen {
+		for name := range i.keys {
+			affected[name] = true
+		}
+	} else if current.token != previous.token {
+		for key, names := range i.definitions {
+			currentValue, inCurrent := current.values[key]
+			previousValue, inPrevious := previous.values[key]
+			if inCurrent == inPrevious && currentValue == previousValue {
+				continue
+			}
+
+			for name := range names {
+				affected[name] = true
+			}
+		}
+	}
+
+	if seen && current.metadata != previous.metadata {
+		for name := range i.metadata {
+			affected[name] = true
+		}
+	}
+
+	names := []string{}
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Rollback restores the generation of a namespace from before the last call
+// to Transition, so that the same RBAC Definitions are affected again when
+// the namespace is retried
+func (i *SelectorIndex) Rollback(namespace string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	previous, ok := i.rollbacks[namespace]
+	if !ok {
+		return
+	}
+	delete(i.rollbacks, namespace)
+
+	if previous == nil {
+		delete(i.namespaces, namespace)
+	} else {
+		i.namespaces[namespace] = *previous
+	}
+}
+
+// ForgetNamespace removes the generation token of a deleted namespace
+func (i *SelectorIndex) ForgetNamespace(namespace string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	delete(i.namespaces, namespace)
+	delete(i.rollbacks, namespace)
+}
+
+// SelectorKeys returns the sorted namespace label keys an RBAC Definition
+// selects on with namespace selectors, exclude selectors, and per label value
+// Role Bindings. Unlike ReferencedRoles this only depends on the RBAC
+// Definition, not on the last call to Parse.
+func (p *Parser) SelectorKeys(rbacDef *rbacmanagerv1beta1.RBACDefinition) []string {
+	seen := map[string]bool{}
+	add := func(key string) {
+		if key != "" {
+			seen[key] = true
+		}
+	}
+
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		for _, rb := range rbacBinding.RoleBindings {
+			for _, selector := range []*metav1.LabelSelector{&rb.NamespaceSelector, &rb.NamespaceExcludeSelector} {
+				for key := range selector.MatchLabels {
+					add(key)
+				}
+				for _, requirement := range selector.MatchExpressions {
+					add(requirement.Key)
+				}
+			}
+
+			if rb.PerLabelValue != nil {
+				add(rb.PerLabelValue.Label)
+			}
+		}
+	}
+
+	keys := []string{}
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// SelectsOnNamespaceMetadata returns true if an RBAC Definition selects
+// namespaces by annotations, owners, or fields, which SelectorKeys can't
+// index
+func (p *Parser) SelectsOnNamespaceMetadata(rbacDef *rbacmanagerv1beta1.RBACDefinition) bool {
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		for _, rb := range rbacBinding.RoleBindings {
+			if len(rb.AnnotationExpression) > 0 || rb.NamespaceOwner != nil || rb.NamespaceFieldSelector != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
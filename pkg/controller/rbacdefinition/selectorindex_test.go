@@ -0,0 +1,171 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectorKeys(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name:     "devs",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole: "edit",
+			NamespaceSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "dev"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "tier",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{"web", "api"},
+				}},
+			},
+			NamespaceExcludeSelector: metav1.LabelSelector{MatchLabels: map[string]string{"frozen": "true"}},
+		}, {
+			PerLabelValue: &rbacmanagerv1beta1.PerLabelValue{
+				Label:        "access",
+				ClusterRoles: map[string]string{"read": "view"},
+			},
+		}, {
+			ClusterRole: "view",
+			Namespace:   "shared",
+		}},
+	}, {
+		Name:     "ops",
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "ops"}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			ClusterRole:       "admin",
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}},
+		}},
+	}}
+
+	p := Parser{}
+	assert.Equal(t, []string{"access", "frozen", "team", "tier"}, p.SelectorKeys(&rbacDef))
+
+	// explicit namespaces don't select on labels
+	rbacDef.RBACBindings = rbacDef.RBACBindings[:1]
+	rbacDef.RBACBindings[0].RoleBindings = rbacDef.RBACBindings[0].RoleBindings[2:]
+	assert.Equal(t, []string{}, p.SelectorKeys(&rbacDef))
+	assert.False(t, p.SelectsOnNamespaceMetadata(&rbacDef))
+
+	rbacDef.RBACBindings[0].RoleBindings[0].AnnotationExpression = []rbacmanagerv1beta1.AnnotationRequirement{{
+		Key:      "owner",
+		Operator: "Exists",
+	}}
+	assert.True(t, p.SelectsOnNamespaceMetadata(&rbacDef))
+}
+
+func TestSelectorIndex(t *testing.T) {
+	index := NewSelectorIndex()
+	index.Record("devs", []string{"team", "tier"}, false)
+	index.Record("ops", []string{"team"}, false)
+	index.Record("static", []string{}, false)
+	assert.Equal(t, []string{"devs", "ops"}, index.Definitions("team"))
+	assert.Equal(t, []string{"devs"}, index.Definitions("tier"))
+
+	// only indexed label keys change the generation token
+	token := index.GenerationToken(map[string]string{"team": "dev"})
+	assert.Equal(t, token, index.GenerationToken(map[string]string{"team": "dev", "owner": "joe"}))
+	assert.NotEqual(t, token, index.GenerationToken(map[string]string{"team": "ops"}))
+	assert.NotEqual(t, token, index.GenerationToken(map[string]string{"team": "dev", "tier": "web"}))
+
+	// a namespace seen for the first time affects every RBAC Definition
+	assert.Equal(t, []string{"devs", "ops", "static"}, index.Transition(namespaceWithLabels("web", map[string]string{"owner": "joe"})))
+
+	// unindexed labels aren't a transition
+	assert.Equal(t, []string{}, index.Transition(namespaceWithLabels("web", map[string]string{"owner": "sue"})))
+
+	assert.Equal(t, []string{"devs"}, index.Transition(namespaceWithLabels("web", map[string]string{"owner": "sue", "tier": "web"})))
+	assert.Equal(t, []string{"devs", "ops"}, index.Transition(namespaceWithLabels("web", map[string]string{"team": "dev", "tier": "web"})))
+	assert.Equal(t, []string{}, index.Transition(namespaceWithLabels("web", map[string]string{"team": "dev", "tier": "web"})))
+
+	// removing a label is a transition too
+	assert.Equal(t, []string{"devs", "ops"}, index.Transition(namespaceWithLabels("web", map[string]string{"tier": "web"})))
+
+	// a label set to an empty value is different from no label
+	assert.Equal(t, []string{"devs", "ops"}, index.Transition(namespaceWithLabels("web", map[string]string{"team": "", "tier": "web"})))
+
+	index.Remove("devs")
+	assert.Equal(t, []string{}, index.Definitions("tier"))
+	assert.Equal(t, []string{"ops"}, index.Transition(namespaceWithLabels("web", map[string]string{"team": "ops"})))
+
+	index.ForgetNamespace("web")
+	assert.Equal(t, []string{"ops", "static"}, index.Transition(namespaceWithLabels("web", map[string]string{"team": "ops"})))
+}
+
+func TestSelectorIndexMetadata(t *testing.T) {
+	index := NewSelectorIndex()
+	index.Record("devs", []string{"team"}, false)
+	index.Record("annotated", []string{"team"}, true)
+
+	namespace := namespaceWithLabels("web", map[string]string{"team": "dev"})
+	assert.Equal(t, []string{"annotated", "devs"}, index.Transition(namespace))
+
+	// an annotation change only affects RBAC Definitions selecting on
+	// namespace metadata
+	namespace.Annotations = map[string]string{"owner": "joe"}
+	assert.Equal(t, []string{"annotated"}, index.Transition(namespace))
+	assert.Equal(t, []string{}, index.Transition(namespace))
+
+	namespace.OwnerReferences = []metav1.OwnerReference{{Kind: "Project", Name: "web", UID: "1234"}}
+	assert.Equal(t, []string{"annotated"}, index.Transition(namespace))
+
+	namespace.Status.Phase = corev1.NamespaceTerminating
+	assert.Equal(t, []string{"annotated"}, index.Transition(namespace))
+
+	namespace.Labels = map[string]string{"team": "ops"}
+	assert.Equal(t, []string{"annotated", "devs"}, index.Transition(namespace))
+
+	index.Remove("annotated")
+	namespace.Annotations = nil
+	assert.Equal(t, []string{}, index.Transition(namespace))
+}
+
+func TestSelectorIndexRollback(t *testing.T) {
+	index := NewSelectorIndex()
+	index.Record("devs", []string{"team"}, false)
+	index.Record("ops", []string{"tier"}, false)
+
+	// a namespace seen for the first time is unseen again once rolled back
+	namespace := namespaceWithLabels("web", map[string]string{"team": "dev"})
+	assert.Equal(t, []string{"devs", "ops"}, index.Transition(namespace))
+	index.Rollback("web")
+	assert.Equal(t, []string{"devs", "ops"}, index.Transition(namespace))
+
+	// a failed transition is retried until it succeeds
+	namespace.Labels = map[string]string{"team": "ops"}
+	assert.Equal(t, []string{"devs"}, index.Transition(namespace))
+	index.Rollback("web")
+	assert.Equal(t, []string{"devs"}, index.Transition(namespace))
+	assert.Equal(t, []string{}, index.Transition(namespace))
+
+	// only the last transition is rolled back, and only once
+	index.Rollback("web")
+	index.Rollback("web")
+	assert.Equal(t, []string{}, index.Transition(namespace))
+}
+
+func namespaceWithLabels(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+
+	logrus "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ServerValidator asks the API server to validate an object without
+// persisting it, so that objects admission webhooks would reject are caught
+// before any changes are applied
+type ServerValidator interface {
+	Validate(obj runtime.Object) error
+}
+
+// ClientsetServerValidator is the default ServerValidator, it validates
+// objects with dryRun=All create requests, falling back to dry run updates
+// for objects that already exist
+type ClientsetServerValidator struct {
+	Clientset kubernetes.Interface
+}
+
+// Validate makes a dry run request for a Cluster Role Binding, Role Binding,
+// Service Account, or Secret
+func (v *ClientsetServerValidator) Validate(obj runtime.Object) error {
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		return dryRunCreate(v.Clientset.RbacV1().RESTClient(), "clusterrolebindings", "", o.Name, o)
+	case *rbacv1.RoleBinding:
+		return dryRunCreate(v.Clientset.RbacV1().RESTClient(), "rolebindings", o.Namespace, o.Name, o)
+	case *v1.ServiceAccount:
+		return dryRunCreate(v.Clientset.CoreV1().RESTClient(), "serviceaccounts", o.Namespace, o.Name, o)
+	case *v1.Secret:
+		return dryRunCreate(v.Clientset.CoreV1().RESTClient(), "secrets", o.Namespace, o.Name, o)
+	default:
+		return fmt.Errorf("Unable to validate %T, only Cluster Role Bindings, Role Bindings, Service Accounts, and Secrets are supported", obj)
+	}
+}
+
+func dryRunCreate(client rest.Interface, resource string, namespace string, name string, obj runtime.Object) error {
+	err := client.Post().
+		Namespace(namespace).
+		Resource(resource).
+		Param("dryRun", "All").
+		Body(obj).
+		Do().
+		Error()
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return client.Put().
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		Param("dryRun", "All").
+		Body(obj).
+		Do().
+		Error()
+}
+
+// validateOnServer validates each resource generated by the Parser with the
+// ServerValidator, aggregating the resources the API server rejected
+func (r *Reconciler) validateOnServer(p *Parser) error {
+	validator := r.ServerValidator
+	if validator == nil {
+		validator = &ClientsetServerValidator{Clientset: r.Clientset}
+	}
+
+	validated := 0
+	failures := []ApplyFailure{}
+	validate := func(resourceType string, obj runtime.Object, namespace string, name string) {
+		validated++
+		err := validator.Validate(obj)
+		if err != nil {
+			logrus.Errorf("Error validating %v %v/%v: %v", resourceType, namespace, name, err)
+			failures = append(failures, ApplyFailure{
+				Action:       "validate",
+				ResourceType: resourceType,
+				Namespace:    namespace,
+				Name:         name,
+				Err:          err,
+			})
+		}
+	}
+
+	for i := range p.parsedServiceAccounts {
+		sa := &p.parsedServiceAccounts[i]
+		validate(ResourceTypeServiceAccount, sa, sa.Namespace, sa.Name)
+	}
+
+	for i := range p.parsedSecrets {
+		secret := &p.parsedSecrets[i]
+		validate(ResourceTypeSecret, secret, secret.Namespace, secret.Name)
+	}
+
+	for i := range p.parsedClusterRoleBindings {
+		crb := &p.parsedClusterRoleBindings[i]
+		validate(ResourceTypeClusterRoleBinding, crb, crb.Namespace, crb.Name)
+	}
+
+	for i := range p.parsedRoleBindings {
+		rb := &p.parsedRoleBindings[i]
+		validate(ResourceTypeRoleBinding, rb, rb.Namespace, rb.Name)
+	}
+
+	if len(failures) > 0 {
+		return &ServerValidationError{Validated: validated, Failures: failures}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeServerValidator sends dry run requests through the reactors of a fake
+// clientset, since fake clientsets don't support dry run requests. A plain
+// ActionImpl is used so that the object tracker never persists them.
+type fakeServerValidator struct {
+	client *fake.Clientset
+}
+
+func (v *fakeServerValidator) Validate(obj runtime.Object) error {
+	action := k8stesting.ActionImpl{Verb: "dryrun"}
+
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		action.Resource = rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings")
+	case *rbacv1.RoleBinding:
+		action.Resource = rbacv1.SchemeGroupVersion.WithResource("rolebindings")
+		action.Namespace = o.Namespace
+	}
+
+	_, err := v.client.Invokes(action, nil)
+	return err
+}
+
+func TestReconcileServerValidate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("dryrun", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		switch action.GetNamespace() {
+		case "restricted":
+			return true, nil, errors.New("admission webhook denied the request")
+		case "secure":
+			return true, nil, errors.New("namespace is locked")
+		}
+		return false, nil, nil
+	})
+
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "validate-example"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "devs",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespaces:  []string{"restricted", "web", "secure"},
+			ClusterRole: "edit",
+		}},
+	}}
+
+	r := Reconciler{Clientset: client, ServerValidate: true, ServerValidator: &fakeServerValidator{client: client}}
+	err := r.Reconcile(&rbacDef)
+	assert.True(t, IsServerValidationError(err))
+	assert.EqualError(t, err, "Server rejected 2 of 4 resources: "+
+		"RoleBinding restricted/validate-example-devs-edit: admission webhook denied the request, "+
+		"RoleBinding secure/validate-example-devs-edit: namespace is locked")
+
+	// nothing is applied when any resource is rejected
+	expectClusterRoleBindings(t, client, []rbacv1.ClusterRoleBinding{})
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{})
+
+	rbacDef.RBACBindings[0].RoleBindings[0].Namespaces = []string{"web"}
+	err = r.Reconcile(&rbacDef)
+	assert.NoError(t, err)
+
+	expectRoleBindings(t, client, []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "validate-example-devs-edit",
+			Namespace: "web",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+	}})
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	logrus "github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GroupMembershipLister lists the members of groups defined outside of the
+// identity provider, such as in a Group custom resource
+type GroupMembershipLister interface {
+	// ListGroupMembers returns the user names that belong to a group, found
+	// is false when the lister doesn't know about the group
+	ListGroupMembers(group string) (members []string, found bool, err error)
+}
+
+// ConfigMapGroupMembershipLister lists group members from a ConfigMap with a
+// key for each group, holding the user names of its members separated by
+// commas or newlines
+type ConfigMapGroupMembershipLister struct {
+	Clientset kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+// ListGroupMembers returns the members of a group listed in the ConfigMap
+func (l *ConfigMapGroupMembershipLister) ListGroupMembers(group string) ([]string, bool, error) {
+	configMap, err := l.Clientset.CoreV1().ConfigMaps(l.Namespace).Get(l.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("Error reading group members from ConfigMap %v/%v: %v", l.Namespace, l.Name, err)
+	}
+
+	data, ok := configMap.Data[group]
+	if !ok {
+		return nil, false, nil
+	}
+
+	members := []string{}
+	for _, member := range strings.FieldsFunc(data, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if member = strings.TrimSpace(member); member != "" {
+			members = append(members, member)
+		}
+	}
+
+	return members, true, nil
+}
+
+// LoadGroupNameMapFile reads a GroupNameMap from a YAML or JSON file mapping
+// external group names to the group names used in the cluster
+func LoadGroupNameMapFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading group name map from %v: %v", path, err)
+	}
+
+	groupNameMap := map[string]string{}
+	err = yaml.Unmarshal(data, &groupNameMap)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding group name map from %v: %v", path, err)
+	}
+
+	return groupNameMap, nil
+}
+
+// SubjectsURLTimeout is the timeout used when fetching subjects, it sets the
+// timeout of the HTTPClient built by NewReconciler and is used when a Parser
+// has no HTTPClient
+var SubjectsURLTimeout = 10 * time.Second
+
+// fetchSubjects retrieves a JSON list of subjects from an external endpoint
+func (p *Parser) fetchSubjects(url string) ([]rbacv1.Subject, error) {
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: SubjectsURLTimeout}
+	}
+
+	logrus.Debugf("Fetching subjects from %v", url)
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching subjects from %v: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching subjects from %v: unexpected status %v", url, resp.Status)
+	}
+
+	subjects := []rbacv1.Subject{}
+	err = json.NewDecoder(resp.Body).Decode(&subjects)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding subjects from %v: %v", url, err)
+	}
+
+	return subjects, nil
+}
+
+// csvSubjectColumns are the columns of CSV subjects, an optional header row
+// with these names is skipped
+var csvSubjectColumns = []string{"kind", "name", "namespace"}
+
+// ParseCSVSubjects reads subjects from CSV with kind, name, and namespace
+// columns. The namespace column can be omitted for subjects other than
+// Service Accounts.
+func ParseCSVSubjects(r io.Reader) ([]rbacv1.Subject, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CSV subjects: %v", err)
+	}
+
+	subjects := []rbacv1.Subject{}
+	for i, record := range records {
+		if i == 0 && isCSVSubjectHeader(record) {
+			continue
+		}
+
+		if len(record) < 2 || len(record) > len(csvSubjectColumns) {
+			return nil, fmt.Errorf("Invalid CSV subject on line %v, expected kind,name,namespace columns", i+1)
+		}
+
+		subject := rbacv1.Subject{
+			Kind: strings.TrimSpace(record[0]),
+			Name: strings.TrimSpace(record[1]),
+		}
+		if len(record) == 3 {
+			subject.Namespace = strings.TrimSpace(record[2])
+		}
+
+		if subject.Kind == "" || subject.Name == "" {
+			return nil, fmt.Errorf("Invalid CSV subject on line %v, kind and name required", i+1)
+		}
+
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, nil
+}
+
+func isCSVSubjectHeader(record []string) bool {
+	if len(record) < 2 {
+		return false
+	}
+
+	for i, column := range record {
+		if i >= len(csvSubjectColumns) || !strings.EqualFold(strings.TrimSpace(column), csvSubjectColumns[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// configMapSubjects reads CSV subjects from a ConfigMap key
+func (p *Parser) configMapSubjects(ref *rbacmanagerv1beta1.ConfigMapSubjects) ([]rbacv1.Subject, error) {
+	logrus.Debugf("Reading subjects from ConfigMap %v/%v", ref.Namespace, ref.Name)
+
+	configMap, err := p.Clientset.CoreV1().ConfigMaps(ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading subjects from ConfigMap %v/%v: %v", ref.Namespace, ref.Name, err)
+	}
+
+	data, ok := configMap.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %v/%v does not have key %v", ref.Namespace, ref.Name, ref.Key)
+	}
+
+	subjects, err := ParseCSVSubjects(strings.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading subjects from ConfigMap %v/%v: %v", ref.Namespace, ref.Name, err)
+	}
+
+	return subjects, nil
+}
+
+// expandGroups replaces Group subjects known to the GroupMembershipLister
+// with a User subject for each member
+func (p *Parser) expandGroups(subjects []rbacv1.Subject) ([]rbacv1.Subject, error) {
+	if p.GroupMembershipLister == nil {
+		return subjects, nil
+	}
+
+	expanded := []rbacv1.Subject{}
+	for _, subject := range subjects {
+		if subject.Kind != rbacv1.GroupKind {
+			expanded = appendUniqueSubject(expanded, subject)
+			continue
+		}
+
+		members, found, err := p.GroupMembershipLister.ListGroupMembers(subject.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing members of group %v: %v", subject.Name, err)
+		}
+
+		if !found {
+			expanded = appendUniqueSubject(expanded, subject)
+			continue
+		}
+
+		logrus.Debugf("Expanding group %v into %v members", subject.Name, len(members))
+		for _, member := range members {
+			expanded = appendUniqueSubject(expanded, rbacv1.Subject{
+				Kind: rbacv1.UserKind,
+				Name: member,
+			})
+		}
+	}
+
+	return expanded, nil
+}
+
+func appendUniqueSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) []rbacv1.Subject {
+	if hasSubject(subjects, subject) {
+		return subjects
+	}
+	return append(subjects, subject)
+}
@@ -0,0 +1,271 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSubjectsFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"kind": "User", "name": "sue"}, {"kind": "ServiceAccount", "name": "ci-bot", "namespace": "bots"}]`)
+	}))
+	defer server.Close()
+
+	client := fake.NewSimpleClientset()
+	rbacDef := subjectsFromURLDefinition(server.URL)
+
+	p := Parser{Clientset: client, HTTPClient: server.Client()}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expectParsedCRB(t, p, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-roster-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "sue",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+	}})
+
+	expectParsedSA(t, p, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ci-bot",
+			Namespace: "bots",
+		},
+	}})
+
+	// the original definition is left untouched
+	assert.Len(t, rbacDef.RBACBindings[0].Subjects, 1)
+}
+
+func TestParseSubjectsFromURLErrors(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `not json`)
+	}))
+	defer invalid.Close()
+
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer slow.Close()
+	defer close(done)
+
+	client := fake.NewSimpleClientset()
+
+	for _, url := range []string{unavailable.URL, invalid.URL, slow.URL} {
+		p := Parser{Clientset: client, HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}}
+		err := p.Parse(subjectsFromURLDefinition(url))
+		assert.Error(t, err, "Expected error fetching subjects from %v", url)
+		assert.Len(t, p.parsedClusterRoleBindings, 0)
+	}
+}
+
+func TestParseCSVSubjects(t *testing.T) {
+	csv := `kind,name,namespace
+User,joe@example.com,
+Group, web-admins
+ServiceAccount,ci-bot,bots
+`
+
+	subjects, err := ParseCSVSubjects(strings.NewReader(csv))
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, Name: "joe@example.com"},
+		{Kind: rbacv1.GroupKind, Name: "web-admins"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "bots"},
+	}, subjects)
+
+	// the header row is optional
+	subjects, err = ParseCSVSubjects(strings.NewReader("User,sue\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "sue"}}, subjects)
+
+	_, err = ParseCSVSubjects(strings.NewReader("kind,name,namespace\nUser\n"))
+	assert.EqualError(t, err, "Invalid CSV subject on line 2, expected kind,name,namespace columns")
+
+	_, err = ParseCSVSubjects(strings.NewReader("User,,\n"))
+	assert.EqualError(t, err, "Invalid CSV subject on line 1, kind and name required")
+}
+
+func TestParseSubjectsFromConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "roster", Namespace: "rbac-manager"},
+		Data: map[string]string{
+			"subjects.csv": "kind,name,namespace\nUser,sue\nServiceAccount,ci-bot,bots\n",
+		},
+	})
+
+	rbacDef := subjectsFromURLDefinition("")
+	rbacDef.RBACBindings[0].SubjectsFromConfigMap = &rbacmanagerv1beta1.ConfigMapSubjects{
+		Namespace: "rbac-manager",
+		Name:      "roster",
+		Key:       "subjects.csv",
+	}
+
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	expectParsedCRB(t, p, []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rbac-config-roster-view",
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.UserKind,
+			Name: "sue",
+		}, {
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}},
+	}})
+
+	expectParsedSA(t, p, []corev1.ServiceAccount{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ci-bot",
+			Namespace: "bots",
+		},
+	}})
+
+	rbacDef.RBACBindings[0].SubjectsFromConfigMap.Key = "missing"
+	p = Parser{Clientset: client}
+	assert.EqualError(t, p.Parse(rbacDef), "ConfigMap rbac-manager/roster does not have key missing")
+
+	rbacDef.RBACBindings[0].SubjectsFromConfigMap.Name = "missing"
+	p = Parser{Clientset: client}
+	assert.Error(t, p.Parse(rbacDef))
+}
+
+func subjectsFromURLDefinition(url string) rbacmanagerv1beta1.RBACDefinition {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "roster",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}},
+		SubjectsFromURL: url,
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	return rbacDef
+}
+
+type fakeGroupMembershipLister map[string][]string
+
+func (l fakeGroupMembershipLister) ListGroupMembers(group string) ([]string, bool, error) {
+	members, found := l[group]
+	return members, found, nil
+}
+
+func TestParseGroupMembershipLister(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "platform",
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.UserKind,
+			Name: "joe",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "platform",
+		}, {
+			Kind: rbacv1.GroupKind,
+			Name: "external",
+		}},
+		ClusterRoleBindings: []rbacmanagerv1beta1.ClusterRoleBinding{{
+			ClusterRole: "view",
+		}},
+	}}
+
+	// without a lister groups pass through unchanged
+	p := Parser{Clientset: client}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+	assert.Equal(t, rbacDef.RBACBindings[0].Subjects, p.parsedClusterRoleBindings[0].Subjects)
+
+	p = Parser{
+		Clientset:             client,
+		GroupMembershipLister: fakeGroupMembershipLister{"platform": {"joe", "sue", "kay"}},
+	}
+	err = p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	assert.Equal(t, []rbacv1.Subject{{
+		Kind: rbacv1.UserKind,
+		Name: "joe",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "sue",
+	}, {
+		Kind: rbacv1.UserKind,
+		Name: "kay",
+	}, {
+		Kind: rbacv1.GroupKind,
+		Name: "external",
+	}}, p.parsedClusterRoleBindings[0].Subjects)
+}
@@ -0,0 +1,283 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ParseFuzzable decodes an RBAC Definition from JSON and validates it
+// without making any requests to the Kubernetes API
+func ParseFuzzable(data []byte) error {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	err := json.Unmarshal(data, &rbacDef)
+	if err != nil {
+		return err
+	}
+
+	p := Parser{}
+	return p.ValidateStatic(&rbacDef)
+}
+
+// ValidateStatic checks an RBAC Definition for errors that can be found
+// without making any requests to the Kubernetes API
+func (p *Parser) ValidateStatic(rbacDef *rbacmanagerv1beta1.RBACDefinition) error {
+	err := checkDuplicateBindingNames(rbacDef)
+	if err != nil {
+		return err
+	}
+
+	err = p.checkNameSeparator(rbacDef)
+	if err != nil {
+		return err
+	}
+
+	for _, rbacBinding := range rbacDef.RBACBindings {
+		namePrefix := p.rdNamePrefix(rbacDef, &rbacBinding)
+
+		if len(rbacBinding.Subjects) < 1 && rbacBinding.SubjectsFromURL == "" && rbacBinding.SubjectsFromConfigMap == nil {
+			return errors.New("No subjects specified for RBAC Binding: " + namePrefix)
+		}
+
+		if ref := rbacBinding.SubjectsFromConfigMap; ref != nil && (ref.Namespace == "" || ref.Name == "" || ref.Key == "") {
+			return errors.New("Invalid subjectsFromConfigMap, namespace, name, and key required for RBAC Binding: " + namePrefix)
+		}
+
+		_, err = bindingAnnotations(&rbacBinding)
+		if err != nil {
+			return err
+		}
+
+		subjects, err := normalizeSubjects(rbacBinding.Subjects)
+		if err != nil {
+			return err
+		}
+
+		if !p.AllowSystemGroups {
+			err = checkSystemGroups(p.mapGroupNames(subjects))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !p.AllowSystemUsers {
+			err = checkSystemUsers(subjects)
+			if err != nil {
+				return err
+			}
+		}
+
+		if rbacBinding.CreateServiceAccounts == nil || *rbacBinding.CreateServiceAccounts {
+			err = p.checkServiceAccountNames(subjects)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, requestedCRB := range rbacBinding.ClusterRoleBindings {
+			if len(clusterRoles(requestedCRB)) < 1 {
+				return errors.New("Invalid cluster role binding, clusterRole or clusterRoles required")
+			}
+		}
+
+		for _, requestedRB := range rbacBinding.RoleBindings {
+			err = validateRoleBinding(requestedRB)
+			if err != nil {
+				return err
+			}
+
+			if requestedRB.Team != "" && p.TeamNamespaceLister == nil {
+				return fmt.Errorf("Invalid role binding, team %v requires team namespaces to be configured with --team-namespace-label", requestedRB.Team)
+			}
+
+			if len(requestedRB.NodeSelector) > 0 && p.NodeNamespaceResolver == nil {
+				return fmt.Errorf("Invalid role binding, node selector %v requires node namespaces to be enabled with --resolve-node-namespaces", labels.Set(requestedRB.NodeSelector))
+			}
+
+			if requestedRB.RequireResource != nil && p.ResourcePresenceResolver == nil {
+				return fmt.Errorf("Invalid role binding, required %v requires resource presence checks to be enabled with --resolve-resource-presence", requestedRB.RequireResource.Kind)
+			}
+
+			err = p.normalizeRoleBindingNamespaces(&requestedRB)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePerLabelValue validates a Role Binding with perLabelValue set along
+// with each of the Role Bindings it expands to
+func validatePerLabelValue(rb rbacmanagerv1beta1.RoleBinding) error {
+	if rb.PerLabelValue.Label == "" {
+		return errors.New("Invalid role binding, perLabelValue requires a label")
+	}
+
+	if len(rb.PerLabelValue.ClusterRoles) < 1 {
+		return errors.New("Invalid role binding, perLabelValue requires clusterRoles")
+	}
+
+	if rb.ClusterRole != "" || rb.Role != "" {
+		return errors.New("Invalid role binding, perLabelValue can not be combined with role or clusterRole")
+	}
+
+	if rb.Namespace != "" || len(rb.Namespaces) > 0 || rb.Team != "" || len(rb.NodeSelector) > 0 || rb.CatchAll {
+		return errors.New("Invalid role binding, perLabelValue can not be combined with namespace, namespaces, team, nodeSelector, or catchAll")
+	}
+
+	for value, clusterRole := range rb.PerLabelValue.ClusterRoles {
+		if clusterRole == "" {
+			return fmt.Errorf("Invalid role binding, perLabelValue requires a clusterRole for %v", value)
+		}
+	}
+
+	for _, expanded := range expandRoleBindings([]rbacmanagerv1beta1.RoleBinding{rb}) {
+		err := validateRoleBinding(expanded)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateRoleBinding returns an error if a requested Role Binding does not
+// specify a valid combination of role and namespace fields
+func validateRoleBinding(rb rbacmanagerv1beta1.RoleBinding) error {
+	if rb.PerLabelValue != nil {
+		return validatePerLabelValue(rb)
+	}
+
+	if rb.ClusterRole == "" && rb.Role == "" {
+		return errors.New("Invalid role binding, role or clusterRole required")
+	}
+
+	// A Role only exists within a single namespace, so it can't be
+	// referenced by bindings fanned out across a namespace selector unless
+	// its name is templated per namespace
+	if rb.ClusterRole == "" && usesNamespaceSelector(rb) && !isRoleTemplate(rb.Role) {
+		return errors.New("Invalid role binding, role can not be combined with a namespace selector, use clusterRole instead")
+	}
+
+	if rb.Both && rb.ClusterRole == "" {
+		return errors.New("Invalid role binding, both requires clusterRole")
+	}
+
+	if rb.RequireRolePresent && rb.ClusterRole != "" {
+		return errors.New("Invalid role binding, requireRolePresent requires role")
+	}
+
+	if !usesNamespaceSelector(rb) && rb.Namespace == "" && len(rb.Namespaces) == 0 && rb.Team == "" && len(rb.NodeSelector) == 0 {
+		return errors.New("Invalid role binding, namespace or namespace selector required")
+	}
+
+	if rb.CatchAll && (rb.Namespace != "" || len(rb.Namespaces) > 0 || rb.Team != "" || len(rb.NodeSelector) > 0) {
+		return errors.New("Invalid role binding, catchAll can not be combined with a namespace")
+	}
+
+	if rb.ClusterRole == "" {
+		_, err := resolveRoleName(rb.Role, rb.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := validateAnnotationExpression(rb)
+	if err != nil {
+		return err
+	}
+
+	if hasExcludeSelector(rb) {
+		_, err := metav1.LabelSelectorAsSelector(&rb.NamespaceExcludeSelector)
+		if err != nil {
+			return fmt.Errorf("Invalid role binding, namespaceExcludeSelector: %v", err)
+		}
+	}
+
+	if rb.NamespaceFieldSelector != "" {
+		if !usesNamespaceSelector(rb) {
+			return errors.New("Invalid role binding, namespaceFieldSelector requires a namespace selector")
+		}
+
+		_, err := fields.ParseSelector(rb.NamespaceFieldSelector)
+		if err != nil {
+			return fmt.Errorf("Invalid role binding, namespaceFieldSelector %v: %v", rb.NamespaceFieldSelector, err)
+		}
+	}
+
+	if rb.NamespaceOwner != nil {
+		if !usesNamespaceSelector(rb) {
+			return errors.New("Invalid role binding, namespaceOwner requires a namespace selector")
+		}
+
+		if rb.NamespaceOwner.Kind == "" && rb.NamespaceOwner.UID == "" {
+			return errors.New("Invalid role binding, namespaceOwner requires a kind or uid")
+		}
+	}
+
+	if rb.RequireResource != nil {
+		if rb.RequireResource.Kind == "" {
+			return errors.New("Invalid role binding, requireResource requires a kind")
+		}
+
+		_, err := metav1.LabelSelectorAsSelector(&rb.RequireResource.LabelSelector)
+		if err != nil {
+			return fmt.Errorf("Invalid role binding, requireResource labelSelector: %v", err)
+		}
+	}
+
+	for _, overrides := range rb.SubjectOverrides {
+		_, err := normalizeSubjects(overrides)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAnnotationExpression returns an error if the annotation expression
+// of a requested Role Binding can't be evaluated
+func validateAnnotationExpression(rb rbacmanagerv1beta1.RoleBinding) error {
+	if len(rb.AnnotationExpression) > 0 && !usesNamespaceSelector(rb) {
+		return errors.New("Invalid role binding, annotationExpression requires a namespace selector")
+	}
+
+	for _, requirement := range rb.AnnotationExpression {
+		switch requirement.Operator {
+		case AnnotationOperatorIn, AnnotationOperatorNotIn:
+			if len(requirement.Values) < 1 {
+				return fmt.Errorf("Invalid annotation expression, %v requires values for %v", requirement.Operator, requirement.Key)
+			}
+		case AnnotationOperatorExists:
+			if len(requirement.Values) > 0 {
+				return fmt.Errorf("Invalid annotation expression, %v can not have values for %v", requirement.Operator, requirement.Key)
+			}
+		default:
+			return fmt.Errorf("Invalid annotation expression, unknown operator %v for %v", requirement.Operator, requirement.Key)
+		}
+	}
+
+	return nil
+}
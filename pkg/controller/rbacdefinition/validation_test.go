@@ -0,0 +1,68 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validationSeeds = []string{
+	`{}`,
+	`{"rbacBindings": null}`,
+	`{"metadata": {"name": "rbac-config"}, "rbacBindings": [{"name": "ci-bot", "subjects": [{"kind": "ServiceAccount", "name": "ci-bot", "namespace": "bots"}], "clusterRoleBindings": [{"clusterRole": "edit"}]}]}`,
+	`{"rbacBindings": [{"name": "devs", "subjects": [{"kind": "Group", "name": "devs"}], "roleBindings": [{"clusterRole": "view", "namespaceSelector": {"matchLabels": {"team": "dev"}}}]}]}`,
+	`{"rbacBindings": [{"name": "devs", "subjects": [{"kind": "User", "name": "jane"}], "roleBindings": [{"role": "reader", "namespaceSelector": {"matchLabels": {}}}]}]}`,
+	`{"rbacBindings": [{"name": "", "subjects": [], "roleBindings": [{}]}, {"name": "other"}]}`,
+	`{"rbacBindings": [{"name": "x", "subjects": [{}], "roleBindings": [{"both": true, "namespaceSelector": {"matchExpressions": [{}]}}]}]}`,
+}
+
+func TestValidateStaticSeeds(t *testing.T) {
+	expectedErrors := []string{
+		"",
+		"",
+		"",
+		"",
+		"Invalid role binding, role can not be combined with a namespace selector, use clusterRole instead",
+		"No subjects specified for RBAC Binding: -",
+		"Invalid subject kind  for ",
+	}
+
+	for i, seed := range validationSeeds {
+		err := ParseFuzzable([]byte(seed))
+		if expectedErrors[i] == "" {
+			assert.Nil(t, err, seed)
+		} else if assert.NotNil(t, err, seed) {
+			assert.Equal(t, expectedErrors[i], err.Error(), seed)
+		}
+	}
+}
+
+func TestValidateStaticSystemGroups(t *testing.T) {
+	seed := []byte(`{"rbacBindings": [{"name": "all", "subjects": [{"kind": "Group", "name": "system:authenticated"}], "clusterRoleBindings": [{"clusterRole": "view"}]}]}`)
+
+	assert.NotNil(t, ParseFuzzable(seed))
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range validationSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseFuzzable(data)
+	})
+}
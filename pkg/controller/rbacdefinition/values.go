@@ -0,0 +1,121 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+)
+
+// LoadValuesFile reads the Values referenced by RBAC Definitions from a YAML
+// or JSON file, like a Helm values file
+func LoadValuesFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading values from %v: %v", path, err)
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding values from %v: %v", path, err)
+	}
+
+	return values, nil
+}
+
+// valuesData is passed to values templates. Namespace renders back to the
+// placeholder resolved by role templates, so that a Role name can reference
+// both values and the namespace of each Role Binding.
+type valuesData struct {
+	Values    map[string]interface{}
+	Namespace string
+}
+
+// isValuesTemplate returns true if a field references Values
+func isValuesTemplate(field string) bool {
+	return strings.Contains(field, "{{") && strings.Contains(field, ".Values")
+}
+
+// resolveValues returns a copy of an RBAC Definition with the {{ .Values.x }}
+// placeholders in subject names and namespaces, role binding namespaces, and
+// role names replaced with Values, like a Helm chart would. Values that are
+// referenced but not set result in an error.
+func (p *Parser) resolveValues(rbacDef rbacmanagerv1beta1.RBACDefinition) (rbacmanagerv1beta1.RBACDefinition, error) {
+	resolved := rbacDef.DeepCopy()
+
+	var err error
+	resolve := func(field *string) {
+		if err != nil || !isValuesTemplate(*field) {
+			return
+		}
+		*field, err = p.renderValues(*field)
+	}
+
+	for i := range resolved.RBACBindings {
+		rbacBinding := &resolved.RBACBindings[i]
+
+		for j := range rbacBinding.Subjects {
+			resolve(&rbacBinding.Subjects[j].Name)
+			resolve(&rbacBinding.Subjects[j].Namespace)
+		}
+
+		for j := range rbacBinding.ClusterRoleBindings {
+			crb := &rbacBinding.ClusterRoleBindings[j]
+			resolve(&crb.ClusterRole)
+			for k := range crb.ClusterRoles {
+				resolve(&crb.ClusterRoles[k])
+			}
+		}
+
+		for j := range rbacBinding.RoleBindings {
+			rb := &rbacBinding.RoleBindings[j]
+			resolve(&rb.ClusterRole)
+			resolve(&rb.Role)
+			resolve(&rb.Namespace)
+			for k := range rb.Namespaces {
+				resolve(&rb.Namespaces[k])
+			}
+		}
+	}
+
+	if err != nil {
+		return rbacDef, err
+	}
+
+	return *resolved, nil
+}
+
+// renderValues renders a field containing values templates
+func (p *Parser) renderValues(field string) (string, error) {
+	tmpl, err := template.New("values").Option("missingkey=error").Parse(field)
+	if err != nil {
+		return "", fmt.Errorf("Invalid values template %v: %v", field, err)
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, valuesData{Values: p.Values, Namespace: "{{.Namespace}}"})
+	if err != nil {
+		return "", fmt.Errorf("Error resolving values template %v: %v", field, err)
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 ReactiveOps
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacdefinition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacmanagerv1beta1 "github.com/reactiveops/rbac-manager/pkg/apis/rbacmanager/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseValues(t *testing.T) {
+	rbacDef := rbacmanagerv1beta1.RBACDefinition{}
+	rbacDef.Name = "rbac-config"
+	rbacDef.RBACBindings = []rbacmanagerv1beta1.RBACBinding{{
+		Name: "deployers",
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "{{ .Values.deployer.name }}",
+			Namespace: "{{ .Values.deployer.namespace }}",
+		}},
+		RoleBindings: []rbacmanagerv1beta1.RoleBinding{{
+			Namespace: "{{ .Values.environment }}-web",
+			Role:      "{{ .Values.role }}",
+		}, {
+			Namespaces: []string{"{{ .Values.environment }}-api"},
+			Role:       "{{ .Values.role }}-{{.Namespace}}",
+		}},
+	}}
+
+	p := Parser{
+		Clientset: fake.NewSimpleClientset(),
+		Values: map[string]interface{}{
+			"deployer": map[string]interface{}{
+				"name":      "ci-bot",
+				"namespace": "bots",
+			},
+			"environment": "staging",
+			"role":        "deployer",
+		},
+	}
+	err := p.Parse(rbacDef)
+	if err != nil {
+		t.Fatalf("Error parsing RBAC Definition: %v", err)
+	}
+
+	if assert.Len(t, p.parsedServiceAccounts, 1) {
+		assert.Equal(t, "ci-bot", p.parsedServiceAccounts[0].Name)
+		assert.Equal(t, "bots", p.parsedServiceAccounts[0].Namespace)
+	}
+
+	if assert.Len(t, p.parsedRoleBindings, 2) {
+		assert.Equal(t, "staging-web", p.parsedRoleBindings[0].Namespace)
+		assert.Equal(t, "deployer", p.parsedRoleBindings[0].RoleRef.Name)
+		assert.Equal(t, []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "ci-bot",
+			Namespace: "bots",
+		}}, p.parsedRoleBindings[0].Subjects)
+
+		// role templates are still resolved for each namespace
+		assert.Equal(t, "staging-api", p.parsedRoleBindings[1].Namespace)
+		assert.Equal(t, "deployer-staging-api", p.parsedRoleBindings[1].RoleRef.Name)
+	}
+
+	// the RBAC Definition itself is unchanged
+	assert.Equal(t, "{{ .Values.deployer.name }}", rbacDef.RBACBindings[0].Subjects[0].Name)
+
+	p = Parser{Clientset: fake.NewSimpleClientset(), Values: map[string]interface{}{"environment": "staging"}}
+	err = p.Parse(rbacDef)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Error resolving values template {{ .Values.deployer.name }}")
+	}
+}